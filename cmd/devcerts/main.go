@@ -0,0 +1,181 @@
+// cmd/devcerts/main.go
+//
+// Generates a local CA, a server certificate for localhost and a client certificate for a device,
+// for trying /api/v1/auth/device/mtls without a real PKI:
+//
+//	go run ./cmd/devcerts -device sensor-001 -out certs
+//
+// then configure the API with:
+//
+//	SERVER_TLS_CERT_FILE=certs/server.pem
+//	SERVER_TLS_KEY_FILE=certs/server-key.pem
+//	SERVER_TLS_CLIENT_CA_FILE=certs/ca.pem
+//
+// pin certs/<device>.pem to the device and call the endpoint with
+//
+//	curl --cacert certs/ca.pem --cert certs/<device>.pem --key certs/<device>-key.pem \
+//	     -X POST https://localhost:8080/api/v1/auth/device/mtls
+//
+// An existing ca.pem and ca-key.pem in the output directory are reused.
+// Never use these certificates anywhere but a development machine.
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func main() {
+	deviceID := flag.String("device", "", "device ID to issue a client certificate for (required)")
+	outDir := flag.String("out", "certs", "output directory")
+	useSAN := flag.Bool("san", false, "put the device ID in a DNS subject alternative name as well as the common name")
+	days := flag.Int("days", 365, "validity of the issued certificates in days")
+	flag.Parse()
+
+	if *deviceID == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := os.MkdirAll(*outDir, 0o700); err != nil {
+		log.Fatalf("Failed to create %s: %v", *outDir, err)
+	}
+
+	caCert, caKey, err := loadOrCreateCA(*outDir)
+	if err != nil {
+		log.Fatalf("Failed to prepare CA: %v", err)
+	}
+
+	validity := time.Duration(*days) * 24 * time.Hour
+
+	server := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if err := issue(*outDir, "server", server, validity, caCert, caKey); err != nil {
+		log.Fatalf("Failed to issue server certificate: %v", err)
+	}
+
+	client := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: *deviceID},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if *useSAN {
+		client.DNSNames = []string{*deviceID}
+	}
+	if err := issue(*outDir, *deviceID, client, validity, caCert, caKey); err != nil {
+		log.Fatalf("Failed to issue device certificate: %v", err)
+	}
+
+	log.Printf("Wrote ca.pem, server.pem and %s.pem with their keys to %s", *deviceID, *outDir)
+}
+
+// loadOrCreateCA reuses the CA in dir or creates a new self-signed one
+func loadOrCreateCA(dir string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPath, keyPath := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem")
+
+	certPEM, certErr := os.ReadFile(certPath)
+	keyPEM, keyErr := os.ReadFile(keyPath)
+	if certErr == nil && keyErr == nil {
+		certBlock, _ := pem.Decode(certPEM)
+		keyBlock, _ := pem.Decode(keyPEM)
+		if certBlock == nil || keyBlock == nil {
+			return nil, nil, errors.New("existing CA files are not PEM")
+		}
+		cert, err := x509.ParseCertificate(certBlock.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cert, key, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber(),
+		Subject:               pkix.Name{CommonName: "Dashboard Development CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := writePEM(certPath, "CERTIFICATE", der); err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writePEM(keyPath, "EC PRIVATE KEY", keyDER); err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// issue signs template with the CA and writes <name>.pem and <name>-key.pem
+func issue(dir, name string, template *x509.Certificate, validity time.Duration, caCert *x509.Certificate, caKey *ecdsa.PrivateKey) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	template.SerialNumber = serialNumber()
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(validity)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := writePEM(filepath.Join(dir, name+".pem"), "CERTIFICATE", der); err != nil {
+		return err
+	}
+	return writePEM(filepath.Join(dir, name+"-key.pem"), "EC PRIVATE KEY", keyDER)
+}
+
+func writePEM(path, blockType string, der []byte) error {
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600)
+}
+
+func serialNumber() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		log.Fatalf("Failed to generate serial number: %v", err)
+	}
+	return serial
+}
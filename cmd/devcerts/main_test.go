@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadOrCreateCAReusesExistingCA(t *testing.T) {
+	dir := t.TempDir()
+
+	first, _, err := loadOrCreateCA(dir)
+	if err != nil {
+		t.Fatalf("loadOrCreateCA: %v", err)
+	}
+	if !first.IsCA {
+		t.Fatal("expected a CA certificate")
+	}
+
+	second, _, err := loadOrCreateCA(dir)
+	if err != nil {
+		t.Fatalf("loadOrCreateCA: %v", err)
+	}
+	if !second.Equal(first) {
+		t.Fatal("expected the existing CA to be reused")
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "ca-key.pem"))
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected the CA key to be private, got %v", info.Mode().Perm())
+	}
+}
+
+func TestIssueDeviceCertificate(t *testing.T) {
+	dir := t.TempDir()
+	caCert, caKey, err := loadOrCreateCA(dir)
+	if err != nil {
+		t.Fatalf("loadOrCreateCA: %v", err)
+	}
+
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "sensor-001"},
+		DNSNames:    []string{"sensor-001"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if err := issue(dir, "sensor-001", template, 24*time.Hour, caCert, caKey); err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+
+	pair, err := tls.LoadX509KeyPair(filepath.Join(dir, "sensor-001.pem"), filepath.Join(dir, "sensor-001-key.pem"))
+	if err != nil {
+		t.Fatalf("LoadX509KeyPair: %v", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		t.Fatalf("expected the device certificate to chain to the CA for client auth: %v", err)
+	}
+	if cert.Subject.CommonName != "sensor-001" || len(cert.DNSNames) != 1 || cert.DNSNames[0] != "sensor-001" {
+		t.Fatalf("unexpected identity: CN=%q SAN=%v", cert.Subject.CommonName, cert.DNSNames)
+	}
+
+	// A certificate from another development CA must not verify
+	otherCA, _, err := loadOrCreateCA(t.TempDir())
+	if err != nil {
+		t.Fatalf("loadOrCreateCA: %v", err)
+	}
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(otherCA)
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:     otherRoots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err == nil {
+		t.Fatal("expected the certificate to be rejected by an unrelated CA")
+	}
+}
@@ -11,16 +11,88 @@ import (
 )
 
 type SecurityConfig struct {
-	MinPasswordLength int
+	MinPasswordLength         int
+	TOTPIssuer                string // Issuer shown in authenticator apps
+	TwoFactorChallengeMinutes int    // Lifetime of the challenge token between the password and code steps
+	PasswordResetMinutes      int    // Lifetime of an emailed password reset token
+	PasswordResetPerEmailHour int    // Reset links sent to the same account per hour
+	PasswordResetPerMin       int    // Password reset requests allowed per IP per minute
+
+	RequireEmailVerification  bool // Reject UserLogin until the email has been verified
+	EmailVerificationHours    int  // Lifetime of an email verification link
+	VerificationResendMinutes int  // Minimum time between verification emails to the same address
+	VerificationResendPerMin  int  // Resend requests allowed per IP per minute
+
+	LoginFreeAttempts      int // Failed logins allowed before the progressive delay starts
+	LoginDelayBaseSeconds  int // Delay after the first delayed attempt, doubled for each further failure
+	LoginDelayMaxSeconds   int // Upper bound for the progressive delay
+	LoginLockThreshold     int // Failed logins that lock the account temporarily
+	LoginLockMinutes       int // Duration of a temporary lock
+	LoginAttemptWindowMins int // Failures older than this are forgotten
+
+	PersonalTokenMaxDays int // Longest lifetime of a personal access token, 0 allows tokens that never expire
+	DeviceKeyGraceHours  int // How long the old device API key keeps working after a rotation
+
+	DeviceSignatureSkewSeconds int    // Largest accepted difference between a signed device request's timestamp and server time
+	DeviceCertIdentity         string // Part of a client certificate holding the device ID: "cn" or "san"
+
+	DeviceCodeMinutes         int // Lifetime of an OAuth device authorization request
+	DeviceCodeIntervalSeconds int // Minimum time between token polls of a device authorization client
+	DeviceCodePerMin          int // Device authorization requests allowed per IP per minute
+
+	ImpersonationMinutes int // Lifetime of the access token an admin receives when impersonating a user
+
+	MagicLinkMinutes         int // Lifetime of an emailed login link
+	MagicLinkPerEmailPerHour int // Login links sent to the same account per hour
+	MagicLinkPerMin          int // Login link requests allowed per IP per minute
 }
 
 // Configuration contains all app configuration
 type Configuration struct {
+	App       AppConfig
 	Database  DatabaseConfig
 	Server    ServerConfig
 	JWT       JWTConfig
 	RateLimit RateLimitConfig
 	Security  SecurityConfig
+	Mail      MailConfig
+	OIDC      OIDCConfig
+}
+
+// AppConfig contains general application settings
+type AppConfig struct {
+	Name        string
+	FrontendURL string // Base URL used to build links sent by email
+	// PublicCacheSeconds is the max-age sent with public API responses
+	PublicCacheSeconds int
+	// ArticleSchedulerSeconds is how often scheduled articles are published and archived; 0 disables the scheduler
+	ArticleSchedulerSeconds int
+}
+
+// MailConfig contains outgoing mail configuration
+type MailConfig struct {
+	Driver       string // smtp, log or file
+	From         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	FileDir      string // Directory used by the file driver
+}
+
+// OIDCConfig contains the OpenID Connect providers users can sign in with
+type OIDCConfig struct {
+	Providers    map[string]OIDCProviderConfig // Keyed by the :provider path parameter
+	StateMinutes int                           // Lifetime of a pending authorization request
+}
+
+// OIDCProviderConfig contains the settings of a single OpenID Connect provider
+type OIDCProviderConfig struct {
+	Issuer       string // Discovery is loaded from {Issuer}/.well-known/openid-configuration
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string // Must be registered with the provider
+	Scopes       []string
 }
 
 // DatabaseConfig contains database related configuration
@@ -43,12 +115,23 @@ type ServerConfig struct {
 	ReadTimeout    time.Duration
 	WriteTimeout   time.Duration
 	TrustedProxies []string // เพิ่มส่วนนี้
+
+	// TLS is served directly when both files are set.
+	// TLSClientCAFile additionally lets devices present client certificates signed by these CAs.
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
 }
 
 // JWTConfig contains JWT related configuration
 type JWTConfig struct {
 	Secret        string
 	ExpiryMinutes int
+	Algorithm     string // HS256, RS256 or EdDSA
+	KeyDir        string // Directory of PEM keys used for RS256/EdDSA signing and verification
+	SigningKeyID  string // kid of the key used for signing, defaults to the last key by name
+	// HS256 tokens without a kid are still accepted under RS256/EdDSA until this time; zero disables them
+	LegacyHS256Until time.Time
 }
 
 // RateLimitConfig สำหรับการตั้งค่าการจำกัดอัตราการเข้าถึง
@@ -70,9 +153,54 @@ func Init() error {
 	}
 
 	Config.Security = SecurityConfig{
-		MinPasswordLength: getEnvAsInt("SECURITY_MIN_PASSWORD_LENGTH", 12),
+		MinPasswordLength:          getEnvAsInt("SECURITY_MIN_PASSWORD_LENGTH", 12),
+		TOTPIssuer:                 getEnv("SECURITY_TOTP_ISSUER", "Dashboard"),
+		TwoFactorChallengeMinutes:  getEnvAsInt("SECURITY_2FA_CHALLENGE_MINUTES", 5),
+		PasswordResetMinutes:       getEnvAsInt("SECURITY_PASSWORD_RESET_MINUTES", 30),
+		PasswordResetPerEmailHour:  getEnvAsInt("SECURITY_PASSWORD_RESET_PER_EMAIL_PER_HOUR", 5),
+		PasswordResetPerMin:        getEnvAsInt("SECURITY_PASSWORD_RESET_PER_MINUTE", 5),
+		RequireEmailVerification:   getEnvAsBool("SECURITY_REQUIRE_EMAIL_VERIFICATION", false),
+		EmailVerificationHours:     getEnvAsInt("SECURITY_EMAIL_VERIFICATION_HOURS", 24),
+		VerificationResendMinutes:  getEnvAsInt("SECURITY_VERIFICATION_RESEND_MINUTES", 5),
+		VerificationResendPerMin:   getEnvAsInt("SECURITY_VERIFICATION_RESEND_PER_MINUTE", 3),
+		LoginFreeAttempts:          getEnvAsInt("SECURITY_LOGIN_FREE_ATTEMPTS", 3),
+		LoginDelayBaseSeconds:      getEnvAsInt("SECURITY_LOGIN_DELAY_BASE_SECONDS", 1),
+		LoginDelayMaxSeconds:       getEnvAsInt("SECURITY_LOGIN_DELAY_MAX_SECONDS", 60),
+		LoginLockThreshold:         getEnvAsInt("SECURITY_LOGIN_LOCK_THRESHOLD", 10),
+		LoginLockMinutes:           getEnvAsInt("SECURITY_LOGIN_LOCK_MINUTES", 15),
+		LoginAttemptWindowMins:     getEnvAsInt("SECURITY_LOGIN_ATTEMPT_WINDOW_MINUTES", 60),
+		PersonalTokenMaxDays:       getEnvAsInt("SECURITY_PAT_MAX_DAYS", 365),
+		DeviceKeyGraceHours:        getEnvAsInt("SECURITY_DEVICE_KEY_GRACE_HOURS", 72),
+		DeviceSignatureSkewSeconds: getEnvAsInt("SECURITY_DEVICE_SIGNATURE_SKEW_SECONDS", 300),
+		DeviceCertIdentity:         strings.ToLower(getEnv("SECURITY_DEVICE_CERT_IDENTITY", "cn")),
+		DeviceCodeMinutes:          getEnvAsInt("SECURITY_DEVICE_CODE_MINUTES", 10),
+		DeviceCodeIntervalSeconds:  getEnvAsInt("SECURITY_DEVICE_CODE_INTERVAL_SECONDS", 5),
+		DeviceCodePerMin:           getEnvAsInt("SECURITY_DEVICE_CODE_PER_MINUTE", 10),
+		ImpersonationMinutes:       getEnvAsInt("SECURITY_IMPERSONATION_MINUTES", 15),
+		MagicLinkMinutes:           getEnvAsInt("SECURITY_MAGIC_LINK_MINUTES", 15),
+		MagicLinkPerEmailPerHour:   getEnvAsInt("SECURITY_MAGIC_LINK_PER_EMAIL_PER_HOUR", 5),
+		MagicLinkPerMin:            getEnvAsInt("SECURITY_MAGIC_LINK_PER_MINUTE", 5),
+	}
+
+	Config.App = AppConfig{
+		Name:                    getEnv("APP_NAME", "Dashboard"),
+		FrontendURL:             strings.TrimRight(getEnv("APP_FRONTEND_URL", "http://localhost:3000"), "/"),
+		PublicCacheSeconds:      getEnvAsInt("APP_PUBLIC_CACHE_SECONDS", 60),
+		ArticleSchedulerSeconds: getEnvAsInt("APP_ARTICLE_SCHEDULER_SECONDS", 30),
+	}
+
+	Config.Mail = MailConfig{
+		Driver:       strings.ToLower(getEnv("MAIL_DRIVER", "log")),
+		From:         getEnv("MAIL_FROM", "no-reply@example.com"),
+		SMTPHost:     getEnv("MAIL_SMTP_HOST", "localhost"),
+		SMTPPort:     getEnvAsInt("MAIL_SMTP_PORT", 587),
+		SMTPUsername: getEnv("MAIL_SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("MAIL_SMTP_PASSWORD", ""),
+		FileDir:      getEnv("MAIL_FILE_DIR", "mail"),
 	}
 
+	Config.OIDC = loadOIDCConfig()
+
 	// Initialize database config
 	Config.Database = DatabaseConfig{
 		User:         getEnv("DB_USER", "postgres"),
@@ -105,16 +233,28 @@ func Init() error {
 		ReadTimeout:    time.Duration(getEnvAsInt("SERVER_READ_TIMEOUT", 10)) * time.Second,
 		WriteTimeout:   time.Duration(getEnvAsInt("SERVER_WRITE_TIMEOUT", 10)) * time.Second,
 		TrustedProxies: trustedProxies, // เพิ่มส่วนนี้
+
+		TLSCertFile:     getEnv("SERVER_TLS_CERT_FILE", ""),
+		TLSKeyFile:      getEnv("SERVER_TLS_KEY_FILE", ""),
+		TLSClientCAFile: getEnv("SERVER_TLS_CLIENT_CA_FILE", ""),
 	}
 
 	// Initialize JWT config
 	Config.JWT = JWTConfig{
 		Secret:        getEnv("JWT_SECRET", ""),
 		ExpiryMinutes: getEnvAsInt("JWT_EXPIRY_MINUTES", 1440), // Default 24 hours
+		Algorithm:     strings.ToUpper(getEnv("JWT_ALGORITHM", "HS256")),
+		KeyDir:        getEnv("JWT_KEY_DIR", "keys"),
+		SigningKeyID:  getEnv("JWT_SIGNING_KEY_ID", ""),
+
+		LegacyHS256Until: getEnvAsTime("JWT_LEGACY_HS256_UNTIL"),
+	}
+	if Config.JWT.Algorithm == "EDDSA" {
+		Config.JWT.Algorithm = "EdDSA"
 	}
 
 	// Validate critical configuration
-	if Config.JWT.Secret == "" {
+	if Config.JWT.Algorithm == "HS256" && Config.JWT.Secret == "" {
 		return fmt.Errorf("JWT_SECRET environment variable is required")
 	}
 
@@ -140,6 +280,40 @@ func Init() error {
 	return nil
 }
 
+// loadOIDCConfig reads the providers listed in OIDC_PROVIDERS, e.g. OIDC_PROVIDERS=google,corp
+// reads OIDC_GOOGLE_ISSUER, OIDC_GOOGLE_CLIENT_ID, ... and OIDC_CORP_ISSUER, ...
+func loadOIDCConfig() OIDCConfig {
+	cfg := OIDCConfig{
+		Providers:    make(map[string]OIDCProviderConfig),
+		StateMinutes: getEnvAsInt("OIDC_STATE_MINUTES", 10),
+	}
+
+	for _, name := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := OIDCProviderConfig{
+			Issuer:       strings.TrimRight(getEnv(prefix+"ISSUER", ""), "/"),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", fmt.Sprintf("%s/auth/oidc/%s/callback", Config.App.FrontendURL, name)),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+		}
+
+		if provider.Issuer == "" || provider.ClientID == "" {
+			log.Printf("WARNING: OIDC provider %q is missing %sISSUER or %sCLIENT_ID and will be ignored", name, prefix, prefix)
+			continue
+		}
+
+		cfg.Providers[name] = provider
+	}
+
+	return cfg
+}
+
 // getEnv retrieves environment variable with fallback value
 func getEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
@@ -148,6 +322,21 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// getEnvAsTime retrieves an RFC 3339 environment variable as a time, or the zero time if it is unset or invalid
+func getEnvAsTime(key string) time.Time {
+	valueStr := strings.TrimSpace(getEnv(key, ""))
+	if valueStr == "" {
+		return time.Time{}
+	}
+
+	value, err := time.Parse(time.RFC3339, valueStr)
+	if err != nil {
+		log.Printf("WARNING: Environment variable %s is not a valid RFC 3339 time. Ignoring it", key)
+		return time.Time{}
+	}
+	return value
+}
+
 // getEnvAsInt retrieves environment variable as integer with fallback
 func getEnvAsInt(key string, fallback int) int {
 	valueStr := getEnv(key, "")
@@ -164,6 +353,22 @@ func getEnvAsInt(key string, fallback int) int {
 	return value
 }
 
+// getEnvAsBool retrieves environment variable as boolean with fallback
+func getEnvAsBool(key string, fallback bool) bool {
+	valueStr := strings.ToLower(strings.TrimSpace(getEnv(key, "")))
+	switch valueStr {
+	case "":
+		return fallback
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	default:
+		log.Printf("WARNING: Environment variable %s is not a valid boolean. Using default value %t", key, fallback)
+		return fallback
+	}
+}
+
 // GetDSN returns database connection string
 func GetDSN() string {
 	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=%s",
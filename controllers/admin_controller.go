@@ -0,0 +1,168 @@
+// controllers/admin_controller.go
+package controllers
+
+import (
+	"dashboard-starter/models"
+	"dashboard-starter/services"
+	"dashboard-starter/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListAdmins handles the request to list admins with pagination and search
+func ListAdmins(c *gin.Context) {
+	// Convert query parameters to PaginationParams
+	var params utils.PaginationParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		// Use default values
+		params = utils.NewPaginationParams()
+	}
+
+	adminService := services.NewAdminService()
+	admins, pagination, err := adminService.GetAdmins(params)
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to retrieve admins: "+err.Error())
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, admins, pagination)
+}
+
+// CreateAdmin handles the request to create a new admin account
+func CreateAdmin(c *gin.Context) {
+	var input models.AdminInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	// ตรวจสอบความแข็งแรงของรหัสผ่าน
+	isStrong, passwordMsg := utils.IsStrongPassword(input.Password)
+	if !isStrong {
+		RespondWithError(c, http.StatusBadRequest, "Password not strong enough: "+passwordMsg)
+		return
+	}
+
+	adminID, _ := c.Get("admin_id")
+
+	adminService := services.NewAdminService()
+	admin, err := adminService.CreateAdmin(&input, adminID.(uint))
+	if err != nil {
+		respondAdminError(c, "Failed to create admin: ", err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusCreated, admin)
+}
+
+// GetAdmin handles the request to get an admin by ID
+func GetAdmin(c *gin.Context) {
+	adminService := services.NewAdminService()
+	admin, err := adminService.GetByID(c.Param("id"))
+	if err != nil {
+		RespondWithError(c, http.StatusNotFound, "Admin not found")
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, admin)
+}
+
+// UpdateAdmin handles the request to update an admin account
+func UpdateAdmin(c *gin.Context) {
+	var input models.AdminUpdateInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	adminService := services.NewAdminService()
+	admin, err := adminService.UpdateAdmin(c.Param("id"), &input)
+	if err != nil {
+		respondAdminError(c, "Failed to update admin: ", err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, admin)
+}
+
+// DeleteAdmin handles the request to delete an admin account
+func DeleteAdmin(c *gin.Context) {
+	adminID, _ := c.Get("admin_id")
+
+	adminService := services.NewAdminService()
+	if err := adminService.DeleteAdmin(c.Param("id"), adminID.(uint)); err != nil {
+		respondAdminError(c, "Failed to delete admin: ", err)
+		return
+	}
+
+	utils.Info("Admin %v deleted admin %s", adminID, c.Param("id"))
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "Admin deleted successfully"})
+}
+
+// DisableAdmin handles the request to disable an admin account and sign it out everywhere
+func DisableAdmin(c *gin.Context) {
+	setAdminDisabled(c, true)
+}
+
+// EnableAdmin handles the request to enable a disabled admin account
+func EnableAdmin(c *gin.Context) {
+	setAdminDisabled(c, false)
+}
+
+// setAdminDisabled disables or enables the admin in the :id path parameter
+func setAdminDisabled(c *gin.Context, disabled bool) {
+	adminID, _ := c.Get("admin_id")
+
+	adminService := services.NewAdminService()
+	admin, err := adminService.SetAdminDisabled(c.Param("id"), disabled, adminID.(uint))
+	if err != nil {
+		respondAdminError(c, "Failed to update admin: ", err)
+		return
+	}
+
+	utils.Info("Admin %v set disabled=%t for admin %d", adminID, disabled, admin.ID)
+	RespondWithSuccess(c, http.StatusOK, admin)
+}
+
+// ChangePassword handles the admin's own password change
+func ChangePassword(c *gin.Context) {
+	adminID, _ := c.Get("admin_id")
+
+	var input models.ChangePasswordInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	// ตรวจสอบความแข็งแรงของรหัสผ่านใหม่
+	isStrong, passwordMsg := utils.IsStrongPassword(input.NewPassword)
+	if !isStrong {
+		RespondWithError(c, http.StatusBadRequest, "New password not strong enough: "+passwordMsg)
+		return
+	}
+
+	adminService := services.NewAdminService()
+	if err := adminService.ChangeAdminPassword(adminID.(uint), input.CurrentPassword, input.NewPassword); err != nil {
+		respondAdminError(c, "Failed to update password: ", err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "Password updated successfully. Please login again"})
+}
+
+// respondAdminError maps admin service errors to HTTP responses
+func respondAdminError(c *gin.Context, prefix string, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, services.ErrRoleNotFound):
+		RespondWithError(c, http.StatusNotFound, prefix+err.Error())
+	case errors.Is(err, services.ErrAdminEmailExists):
+		RespondWithError(c, http.StatusConflict, prefix+err.Error())
+	case errors.Is(err, services.ErrLastActiveAdmin), errors.Is(err, services.ErrSelfAction),
+		errors.Is(err, services.ErrRoleEscalation):
+		RespondWithError(c, http.StatusForbidden, prefix+err.Error())
+	case errors.Is(err, services.ErrIncorrectPassword), errors.Is(err, services.ErrInvalidID):
+		RespondWithError(c, http.StatusBadRequest, prefix+err.Error())
+	default:
+		RespondWithError(c, http.StatusInternalServerError, prefix+err.Error())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"dashboard-starter/models"
 	"dashboard-starter/services"
 	"dashboard-starter/utils"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -68,7 +69,12 @@ func CreateArticle(c *gin.Context) {
 	article, err := articleService.CreateArticle(&input, adminID.(uint))
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidArticleSchedule) || errors.Is(err, services.ErrUnknownTaxonomy) {
+			statusCode = http.StatusBadRequest
+		}
+
+		c.JSON(statusCode, Response{
 			Success: false,
 			Error:   "Failed to create article: " + err.Error(),
 		})
@@ -90,9 +96,13 @@ func ListArticles(c *gin.Context) {
 		params = utils.NewPaginationParams()
 	}
 
+	// Optional tag and category filters, given by slug
+	var filter models.ArticleFilter
+	_ = c.ShouldBindQuery(&filter)
+
 	// Call service
 	articleService := services.NewArticleService()
-	articles, pagination, err := articleService.GetArticles(params)
+	articles, pagination, err := articleService.GetArticles(params, filter)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
@@ -166,6 +176,8 @@ func UpdateArticle(c *gin.Context) {
 			statusCode = http.StatusNotFound
 		} else if err.Error() == "you don't have permission to update this article" {
 			statusCode = http.StatusForbidden
+		} else if errors.Is(err, services.ErrInvalidArticleSchedule) || errors.Is(err, services.ErrUnknownTaxonomy) {
+			statusCode = http.StatusBadRequest
 		}
 
 		c.JSON(statusCode, Response{
@@ -220,9 +232,21 @@ func PublishArticle(c *gin.Context) {
 
 	id := c.Param("id")
 
+	// The body is optional; without one the article is published immediately
+	var input models.PublishArticleInput
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Success: false,
+				Error:   "Invalid input: " + err.Error(),
+			})
+			return
+		}
+	}
+
 	// Publish article
 	articleService := services.NewArticleService()
-	article, err := articleService.PublishArticle(id, adminID.(uint))
+	article, err := articleService.PublishArticle(id, &input, adminID.(uint))
 
 	if err != nil {
 		statusCode := http.StatusInternalServerError
@@ -230,6 +254,8 @@ func PublishArticle(c *gin.Context) {
 			statusCode = http.StatusNotFound
 		} else if err.Error() == "you don't have permission to publish this article" {
 			statusCode = http.StatusForbidden
+		} else if errors.Is(err, services.ErrInvalidArticleSchedule) {
+			statusCode = http.StatusBadRequest
 		}
 
 		c.JSON(statusCode, Response{
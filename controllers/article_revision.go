@@ -0,0 +1,91 @@
+// controllers/article_revision.go
+package controllers
+
+import (
+	"dashboard-starter/services"
+	"dashboard-starter/utils"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ArticleRevisionDiffQuery selects the two revisions to compare
+type ArticleRevisionDiffQuery struct {
+	From int `form:"from" binding:"required,min=1"`
+	To   int `form:"to" binding:"required,min=1"`
+}
+
+// ListArticleRevisions lists the revisions of an article, newest first
+func ListArticleRevisions(c *gin.Context) {
+	var params utils.PaginationParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		params = utils.NewPaginationParams()
+	}
+
+	articleService := services.NewArticleService()
+	revisions, pagination, err := articleService.ListArticleRevisions(c.Param("id"), params)
+	if err != nil {
+		respondArticleRevisionError(c, err, "Failed to retrieve revisions")
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, revisions, pagination)
+}
+
+// DiffArticleRevisions shows a line-based diff between two revisions of an article
+func DiffArticleRevisions(c *gin.Context) {
+	var query ArticleRevisionDiffQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		RespondWithError(c, http.StatusBadRequest, "Query parameters from and to must be revision numbers")
+		return
+	}
+
+	articleService := services.NewArticleService()
+	diff, err := articleService.DiffArticleRevisions(c.Param("id"), query.From, query.To)
+	if err != nil {
+		respondArticleRevisionError(c, err, "Failed to compare revisions")
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, diff)
+}
+
+// RestoreArticleRevision restores an old revision of an article as its newest revision
+func RestoreArticleRevision(c *gin.Context) {
+	adminID, _ := c.Get("admin_id")
+
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil || revision < 1 {
+		RespondWithError(c, http.StatusBadRequest, "Invalid revision number")
+		return
+	}
+
+	articleService := services.NewArticleService()
+	article, err := articleService.RestoreArticleRevision(c.Param("id"), revision, adminID.(uint))
+	if err != nil {
+		respondArticleRevisionError(c, err, "Failed to restore revision")
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, article)
+}
+
+// respondArticleRevisionError maps article revision errors to HTTP responses
+func respondArticleRevisionError(c *gin.Context, err error, message string) {
+	switch {
+	case err.Error() == "invalid ID format":
+		RespondWithError(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		RespondWithError(c, http.StatusNotFound, "Article not found")
+	case errors.Is(err, services.ErrArticleRevisionNotFound):
+		RespondWithError(c, http.StatusNotFound, "Revision not found")
+	case err.Error() == "you don't have permission to update this article":
+		RespondWithError(c, http.StatusForbidden, err.Error())
+	default:
+		utils.Error("%s: %v", message, err)
+		RespondWithError(c, http.StatusInternalServerError, message)
+	}
+}
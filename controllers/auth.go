@@ -5,6 +5,7 @@ import (
 	"dashboard-starter/models"
 	"dashboard-starter/services"
 	"dashboard-starter/utils"
+	"errors"
 	"net/http"
 	"time"
 
@@ -22,11 +23,14 @@ type LoginInput struct {
 
 // LoginResponse represents the login response
 type LoginResponse struct {
-	Token        string    `json:"token"`
-	RefreshToken string    `json:"refresh_token"`
-	ExpiresAt    time.Time `json:"expires_at"`
-	UserID       uint      `json:"user_id"`
-	UserType     string    `json:"user_type"`
+	Token         string    `json:"token"`
+	RefreshToken  string    `json:"refresh_token"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	UserID        uint      `json:"user_id"`
+	UserType      string    `json:"user_type"`
+	RecoveryCodes []string  `json:"recovery_codes,omitempty"` // Only returned when 2FA setup completes during login
+
+	KeyRotation *models.DeviceKeyRotation `json:"key_rotation,omitempty"` // Only returned to devices that used a key being rotated out
 }
 
 // Login handles admin authentication
@@ -51,9 +55,16 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// Back off repeated failures for this email before checking credentials
+	if rejectThrottledLogin(c, "admin", input.Email) {
+		return
+	}
+
 	// Find admin by email
 	var admin models.Admin
 	if err := db.DB.Where("email = ?", input.Email).First(&admin).Error; err != nil {
+		recordLoginFailure("admin", input.Email)
+
 		// Use a generic error message to prevent user enumeration
 		c.JSON(http.StatusUnauthorized, Response{
 			Success: false,
@@ -64,6 +75,8 @@ func Login(c *gin.Context) {
 
 	// Compare password
 	if err := bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(input.Password)); err != nil {
+		recordLoginFailure("admin", input.Email)
+
 		// Use a generic error message to prevent user enumeration
 		c.JSON(http.StatusUnauthorized, Response{
 			Success: false,
@@ -72,52 +85,23 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Update token version inside transaction
-	err := db.Transaction(func(tx *gorm.DB) error {
-		admin.TokenVersion += 1
-		admin.LastLogin = time.Now()
-		return tx.Save(&admin).Error
-	})
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
+	// Checked after the password so disabled accounts are not revealed to guessers
+	if admin.DisabledAt != nil {
+		c.JSON(http.StatusForbidden, Response{
 			Success: false,
-			Error:   "Authentication failed: " + err.Error(),
+			Error:   "Account is disabled",
 		})
 		return
 	}
 
-	// Generate JWT token
-	token, exp, err := utils.GenerateToken(admin.ID, "admin", admin.TokenVersion)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to generate token: " + err.Error(),
-		})
+	// Continue with a second factor if the admin has 2FA enabled or it is mandatory
+	if beginTwoFactorLogin(c, admin.ID, "admin", admin.Email) {
 		return
 	}
 
-	// Generate refresh token
-	refreshTokenObj, err := services.CreateRefreshToken(admin.ID, "admin")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to generate refresh token: " + err.Error(),
-		})
-		return
-	}
-
-	// Return token
-	c.JSON(http.StatusOK, Response{
-		Success: true,
-		Data: LoginResponse{
-			Token:        token,
-			RefreshToken: refreshTokenObj.Token,
-			ExpiresAt:    exp,
-			UserID:       admin.ID,
-			UserType:     "admin",
-		},
-	})
+	// Only a completed login clears the failure counter, a correct password alone does not
+	resetLoginFailures("admin", input.Email)
+	completeLogin(c, admin.ID, "admin", nil)
 }
 
 // Logout handles admin logout
@@ -140,6 +124,12 @@ func Logout(c *gin.Context) {
 		return tx.Save(&admin).Error
 	})
 
+	// End the session the access token belongs to, so its refresh token cannot start it again.
+	// Personal access tokens are not tied to a login session and stay valid.
+	if sessionID, ok := c.Get("session_id"); ok && err == nil {
+		err = services.RevokeRefreshTokenFamily(sessionID.(string))
+	}
+
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Success: false,
@@ -172,12 +162,20 @@ func RefreshToken(c *gin.Context) {
 		return
 	}
 
-	// Validate refresh token
-	refreshToken, err := services.ValidateRefreshToken(input.RefreshToken)
+	// Rotate refresh token: the presented token is revoked and a new one in the same family is issued
+	refreshToken, err := services.RotateRefreshToken(input.RefreshToken, sessionClient(c))
 	if err != nil {
+		errMsg := "Invalid refresh token: " + err.Error()
+		if errors.Is(err, services.ErrSessionRevoked) {
+			errMsg = "This session has been signed out, please login again"
+		}
+		if errors.Is(err, services.ErrRefreshTokenReused) {
+			errMsg = "Refresh token has already been used. All sessions from this login have been revoked, please login again"
+		}
+
 		c.JSON(http.StatusUnauthorized, Response{
 			Success: false,
-			Error:   "Invalid refresh token: " + err.Error(),
+			Error:   errMsg,
 		})
 		return
 	}
@@ -193,7 +191,7 @@ func RefreshToken(c *gin.Context) {
 	}
 
 	// Generate new access token
-	token, exp, err := utils.GenerateToken(refreshToken.UserID, refreshToken.UserType, tokenVersion)
+	token, exp, err := utils.GenerateToken(refreshToken.UserID, refreshToken.UserType, tokenVersion, refreshToken.FamilyID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Success: false,
@@ -202,14 +200,15 @@ func RefreshToken(c *gin.Context) {
 		return
 	}
 
-	// Return new access token
+	// Return new access token together with the rotated refresh token
 	c.JSON(http.StatusOK, Response{
 		Success: true,
-		Data: gin.H{
-			"token":      token,
-			"expires_at": exp,
-			"user_id":    refreshToken.UserID,
-			"user_type":  refreshToken.UserType,
+		Data: LoginResponse{
+			Token:        token,
+			RefreshToken: refreshToken.Token,
+			ExpiresAt:    exp,
+			UserID:       refreshToken.UserID,
+			UserType:     refreshToken.UserType,
 		},
 	})
 }
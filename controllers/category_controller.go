@@ -0,0 +1,79 @@
+// controllers/category_controller.go
+package controllers
+
+import (
+	"dashboard-starter/models"
+	"dashboard-starter/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListCategories handles the request to list all categories as a tree
+func ListCategories(c *gin.Context) {
+	categoryService := services.NewCategoryService()
+	categories, err := categoryService.GetCategoryTree()
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to retrieve categories: "+err.Error())
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, categories)
+}
+
+// CreateCategory handles the request to create a category
+func CreateCategory(c *gin.Context) {
+	var input models.CategoryInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	categoryService := services.NewCategoryService()
+	category, err := categoryService.CreateCategory(&input)
+	if err != nil {
+		respondTaxonomyError(c, "Failed to create category: ", err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusCreated, category)
+}
+
+// GetCategory handles the request to get a category and its direct subcategories
+func GetCategory(c *gin.Context) {
+	categoryService := services.NewCategoryService()
+	category, err := categoryService.GetByID(c.Param("id"))
+	if err != nil {
+		RespondWithError(c, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, category)
+}
+
+// UpdateCategory handles the request to update or move a category
+func UpdateCategory(c *gin.Context) {
+	var input models.CategoryInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	categoryService := services.NewCategoryService()
+	category, err := categoryService.UpdateCategory(c.Param("id"), &input)
+	if err != nil {
+		respondTaxonomyError(c, "Failed to update category: ", err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, category)
+}
+
+// DeleteCategory handles the request to delete a category without subcategories
+func DeleteCategory(c *gin.Context) {
+	categoryService := services.NewCategoryService()
+	if err := categoryService.DeleteCategory(c.Param("id")); err != nil {
+		respondTaxonomyError(c, "Failed to delete category: ", err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
@@ -6,6 +6,7 @@ import (
 	"dashboard-starter/models"
 	"dashboard-starter/services"
 	"dashboard-starter/utils"
+	"errors"
 	"net/http"
 	"time"
 
@@ -26,9 +27,16 @@ func DeviceAuth(c *gin.Context) {
 		return
 	}
 
+	// Back off repeated failures for this device ID before checking credentials
+	if rejectThrottledLogin(c, "device", input.DeviceID) {
+		return
+	}
+
 	// Find device by deviceID
 	var device models.Device
 	if err := db.DB.Where("device_id = ?", input.DeviceID).First(&device).Error; err != nil {
+		recordLoginFailure("device", input.DeviceID)
+
 		c.JSON(http.StatusUnauthorized, Response{
 			Success: false,
 			Error:   "Invalid device ID or API key",
@@ -36,9 +44,18 @@ func DeviceAuth(c *gin.Context) {
 		return
 	}
 
-	// Verify API key (simple comparison for this example)
-	// In production, you might want to use a more secure comparison
-	if device.ApiKey != input.ApiKey {
+	// Verify API key against the stored hash in constant time
+	match, err := services.VerifyDeviceApiKey(&device, input.ApiKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Authentication failed: " + err.Error(),
+		})
+		return
+	}
+	if match == services.DeviceKeyInvalid {
+		recordLoginFailure("device", input.DeviceID)
+
 		c.JSON(http.StatusUnauthorized, Response{
 			Success: false,
 			Error:   "Invalid device ID or API key",
@@ -46,11 +63,94 @@ func DeviceAuth(c *gin.Context) {
 		return
 	}
 
+	resetLoginFailures("device", input.DeviceID)
+
+	response, ok := issueDeviceTokens(c, &device)
+	if !ok {
+		return
+	}
+
+	// A device still using its old key is told to switch before the key stops working
+	if match == services.DeviceKeyPrevious {
+		response.KeyRotation = &models.DeviceKeyRotation{
+			Status:          "pending",
+			NewKeyPrefix:    device.ApiKeyPrefix,
+			OldKeyExpiresAt: *device.PreviousKeyExpiresAt,
+		}
+	}
+
+	// Return tokens
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    response,
+	})
+}
+
+// DeviceMTLSAuth authenticates an IoT device with the client certificate it presented during the
+// TLS handshake. The certificate has already been verified against SERVER_TLS_CLIENT_CA_FILE and
+// must be pinned to the device; no API key is needed.
+func DeviceMTLSAuth(c *gin.Context) {
+	if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+		c.JSON(http.StatusUnauthorized, Response{
+			Success: false,
+			Error:   "A client certificate signed by the configured CA is required",
+		})
+		return
+	}
+
+	cert := c.Request.TLS.VerifiedChains[0][0]
+	identity, err := services.DeviceIDFromCertificate(cert)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if rejectThrottledLogin(c, "device", identity) {
+		return
+	}
+
+	device, err := services.AuthenticateDeviceCertificate(cert)
+	if err != nil {
+		if errors.Is(err, services.ErrCertificateNotPinned) {
+			recordLoginFailure("device", identity)
+
+			c.JSON(http.StatusUnauthorized, Response{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Authentication failed: " + err.Error(),
+		})
+		return
+	}
+
+	resetLoginFailures("device", identity)
+
+	response, ok := issueDeviceTokens(c, device)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    response,
+	})
+}
+
+// issueDeviceTokens marks an authenticated device active and creates its access and refresh tokens.
+// Returns false if an error response has been sent.
+func issueDeviceTokens(c *gin.Context, device *models.Device) (*LoginResponse, bool) {
 	// Update device last seen status
 	err := db.Transaction(func(tx *gorm.DB) error {
 		device.LastSeen = time.Now()
 		device.Status = "active"
-		return tx.Save(&device).Error
+		return tx.Save(device).Error
 	})
 
 	if err != nil {
@@ -58,38 +158,34 @@ func DeviceAuth(c *gin.Context) {
 			Success: false,
 			Error:   "Authentication failed: " + err.Error(),
 		})
-		return
+		return nil, false
 	}
 
-	// Generate access token (30 minutes as in your diagram)
-	token, exp, err := utils.GenerateToken(device.ID, "device", device.TokenVersion)
+	// Generate refresh token (1 year as in your diagram)
+	refreshTokenObj, err := services.CreateRefreshToken(device.ID, "device", deviceSessionClient(c, device))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Success: false,
-			Error:   "Failed to generate token: " + err.Error(),
+			Error:   "Failed to generate refresh token: " + err.Error(),
 		})
-		return
+		return nil, false
 	}
 
-	// Generate refresh token (1 year as in your diagram)
-	refreshTokenObj, err := services.CreateRefreshToken(device.ID, "device")
+	// Generate access token (30 minutes as in your diagram)
+	token, exp, err := utils.GenerateToken(device.ID, "device", device.TokenVersion, refreshTokenObj.FamilyID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Success: false,
-			Error:   "Failed to generate refresh token: " + err.Error(),
+			Error:   "Failed to generate token: " + err.Error(),
 		})
-		return
+		return nil, false
 	}
 
-	// Return tokens
-	c.JSON(http.StatusOK, Response{
-		Success: true,
-		Data: LoginResponse{
-			Token:        token,
-			RefreshToken: refreshTokenObj.Token,
-			ExpiresAt:    exp,
-			UserID:       device.ID,
-			UserType:     "device",
-		},
-	})
+	return &LoginResponse{
+		Token:        token,
+		RefreshToken: refreshTokenObj.Token,
+		ExpiresAt:    exp,
+		UserID:       device.ID,
+		UserType:     "device",
+	}, true
 }
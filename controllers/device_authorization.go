@@ -0,0 +1,135 @@
+// controllers/device_authorization.go
+package controllers
+
+import (
+	"dashboard-starter/models"
+	"dashboard-starter/services"
+	"dashboard-starter/utils"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceCodeGrantType is the RFC 8628 grant type used when polling the token endpoint
+const DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// OAuthTokenResponse is the RFC 6749 section 5.1 token response
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// StartDeviceAuthorization implements the RFC 8628 device authorization endpoint for clients such as
+// kiosks and TVs that cannot take a password. The optional client_name is shown to the approving user;
+// a registered OAuth client sends its client_id so it can revoke the issued tokens later.
+func StartDeviceAuthorization(c *gin.Context) {
+	response, err := services.StartDeviceAuthorization(c.PostForm("client_id"), c.PostForm("client_name"), c.ClientIP())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidClient) {
+			c.JSON(http.StatusUnauthorized, OAuthError{Error: "invalid_client"})
+			return
+		}
+		utils.Error("Failed to start device authorization: %v", err)
+		c.JSON(http.StatusInternalServerError, OAuthError{Error: "server_error"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, response)
+}
+
+// OAuthToken is the token endpoint polled by device authorization clients
+func OAuthToken(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	if c.PostForm("grant_type") != DeviceCodeGrantType {
+		c.JSON(http.StatusBadRequest, OAuthError{Error: "unsupported_grant_type"})
+		return
+	}
+
+	deviceCode := c.PostForm("device_code")
+	if deviceCode == "" {
+		c.JSON(http.StatusBadRequest, OAuthError{Error: "invalid_request", ErrorDescription: "device_code is required"})
+		return
+	}
+
+	tokens, err := services.PollDeviceAuthorization(deviceCode, c.PostForm("client_id"), sessionClient(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrAuthorizationPending), errors.Is(err, services.ErrSlowDown),
+			errors.Is(err, services.ErrAccessDenied), errors.Is(err, services.ErrExpiredDeviceCode),
+			errors.Is(err, services.ErrInvalidDeviceCode):
+			c.JSON(http.StatusBadRequest, OAuthError{Error: err.Error()})
+		default:
+			utils.Error("Failed to poll device authorization: %v", err)
+			c.JSON(http.StatusInternalServerError, OAuthError{Error: "server_error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, OAuthTokenResponse{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(time.Until(tokens.ExpiresAt).Seconds()),
+		RefreshToken: tokens.RefreshToken,
+	})
+}
+
+// GetDeviceAuthorization shows the signed-in user which client a user code belongs to before they approve it
+func GetDeviceAuthorization(c *gin.Context) {
+	authorization, err := services.GetPendingDeviceAuthorization(c.Param("user_code"), c.MustGet("user_id").(uint))
+	if err != nil {
+		respondDeviceAuthorizationError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, authorization)
+}
+
+// ApproveDeviceAuthorization signs the waiting client in as the current user
+func ApproveDeviceAuthorization(c *gin.Context) {
+	var input models.DeviceCodeApprovalInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	userID := c.MustGet("user_id").(uint)
+	if err := services.ApproveDeviceAuthorization(input.UserCode, userID); err != nil {
+		respondDeviceAuthorizationError(c, err)
+		return
+	}
+
+	utils.Info("User %d approved a device authorization request", userID)
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "Device signed in. You can return to your device."})
+}
+
+// DenyDeviceAuthorization rejects the waiting client. Only the user who entered the code can deny it.
+func DenyDeviceAuthorization(c *gin.Context) {
+	var input models.DeviceCodeApprovalInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	userID := c.MustGet("user_id").(uint)
+	if err := services.DenyDeviceAuthorization(input.UserCode, userID); err != nil {
+		respondDeviceAuthorizationError(c, err)
+		return
+	}
+
+	utils.Info("User %d denied a device authorization request", userID)
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "Device sign-in request denied"})
+}
+
+// respondDeviceAuthorizationError maps user code errors to HTTP responses
+func respondDeviceAuthorizationError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvalidUserCode) {
+		RespondWithError(c, http.StatusNotFound, err.Error())
+		return
+	}
+	RespondWithError(c, http.StatusInternalServerError, "Failed to process device authorization: "+err.Error())
+}
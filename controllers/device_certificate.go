@@ -0,0 +1,85 @@
+// controllers/device_certificate.go
+package controllers
+
+import (
+	"dashboard-starter/models"
+	"dashboard-starter/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListDeviceCertificates แสดง client certificate ที่ผูกกับอุปกรณ์
+func ListDeviceCertificates(c *gin.Context) {
+	device, ok := findDevice(c)
+	if !ok {
+		return
+	}
+
+	certificates, err := services.ListDeviceCertificates(device.ID)
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "ไม่สามารถดึงรายการ certificate ได้: "+err.Error())
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, certificates)
+}
+
+// PinDeviceCertificate ผูก client certificate (PEM) กับอุปกรณ์เพื่อใช้ล็อกอินผ่าน /auth/device/mtls
+func PinDeviceCertificate(c *gin.Context) {
+	var input models.DeviceCertificateInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	device, ok := findDevice(c)
+	if !ok {
+		return
+	}
+
+	certificate, err := services.PinDeviceCertificate(device, input.Certificate)
+	if err != nil {
+		respondDeviceCertificateError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusCreated, certificate)
+}
+
+// RevokeDeviceCertificate ยกเลิก certificate และ token ทั้งหมดของอุปกรณ์
+func RevokeDeviceCertificate(c *gin.Context) {
+	device, ok := findDevice(c)
+	if !ok {
+		return
+	}
+
+	certificateID, err := strconv.ParseUint(c.Param("cert_id"), 10, 32)
+	if err != nil {
+		RespondWithError(c, http.StatusBadRequest, "รหัส certificate ไม่ถูกต้อง")
+		return
+	}
+
+	if err := services.RevokeDeviceCertificate(device, uint(certificateID)); err != nil {
+		respondDeviceCertificateError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "ยกเลิก certificate แล้ว อุปกรณ์ต้องล็อกอินใหม่"})
+}
+
+// respondDeviceCertificateError แปลง error ของการจัดการ certificate เป็น HTTP response
+func respondDeviceCertificateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidCertificate), errors.Is(err, services.ErrCertificateIdentityMismatch),
+		errors.Is(err, services.ErrMTLSDisabled):
+		RespondWithError(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrCertificateAlreadyPinned):
+		RespondWithError(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrDeviceCertificateNotFound):
+		RespondWithError(c, http.StatusNotFound, err.Error())
+	default:
+		RespondWithError(c, http.StatusInternalServerError, "ไม่สามารถจัดการ certificate ได้: "+err.Error())
+	}
+}
@@ -1,10 +1,10 @@
 package controllers
 
 import (
-	"crypto/rand"
 	"dashboard-starter/db"
 	"dashboard-starter/models"
-	"encoding/hex"
+	"dashboard-starter/services"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -13,15 +13,6 @@ import (
 	"gorm.io/gorm"
 )
 
-// Helper function to generate random API key
-func generateRandomAPIKey(length int) (string, error) {
-	bytes := make([]byte, length/2)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
-	}
-	return hex.EncodeToString(bytes), nil
-}
-
 // CreateDevice creates a new device in the system (admin only)
 func CreateDevice(c *gin.Context) {
 	// Admin check is handled by AdminRequired middleware
@@ -58,26 +49,25 @@ func CreateDevice(c *gin.Context) {
 		return
 	}
 
-	// สร้าง API key แบบสุ่ม
-	apiKey, err := generateRandomAPIKey(32)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "ไม่สามารถสร้าง API key ได้: " + err.Error(),
-		})
-		return
-	}
-
 	// สร้างอุปกรณ์ใหม่
 	device := models.Device{
 		DeviceID:     input.DeviceID,
 		Name:         input.Name,
-		ApiKey:       apiKey,
 		TokenVersion: 1,
 		Status:       "inactive",
 		LastSeen:     time.Now(),
 	}
 
+	// สร้าง API key แบบสุ่ม (เก็บเฉพาะ hash)
+	apiKey, err := services.NewDeviceApiKey(&device)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "ไม่สามารถสร้าง API key ได้: " + err.Error(),
+		})
+		return
+	}
+
 	if err := db.DB.Create(&device).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Success: false,
@@ -90,12 +80,13 @@ func CreateDevice(c *gin.Context) {
 	c.JSON(http.StatusCreated, Response{
 		Success: true,
 		Data: gin.H{
-			"id":         device.ID,
-			"device_id":  device.DeviceID,
-			"name":       device.Name,
-			"api_key":    apiKey, // แสดง API key ให้ admin เห็นครั้งเดียว
-			"status":     device.Status,
-			"created_by": adminID,
+			"id":             device.ID,
+			"device_id":      device.DeviceID,
+			"name":           device.Name,
+			"api_key":        apiKey, // แสดง API key ให้ admin เห็นครั้งเดียว
+			"api_key_prefix": device.ApiKeyPrefix,
+			"status":         device.Status,
+			"created_by":     adminID,
 		},
 	})
 }
@@ -166,14 +157,8 @@ func ListDevices(c *gin.Context) {
 func GetDevice(c *gin.Context) {
 	// ตรวจสอบว่าเป็น admin โดย AdminRequired middleware แล้ว
 
-	id := c.Param("id")
-
-	var device models.Device
-	if err := db.DB.First(&device, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, Response{
-			Success: false,
-			Error:   "ไม่พบอุปกรณ์",
-		})
+	device, ok := findDevice(c)
+	if !ok {
 		return
 	}
 
@@ -187,14 +172,8 @@ func GetDevice(c *gin.Context) {
 func UpdateDevice(c *gin.Context) {
 	// ตรวจสอบว่าเป็น admin โดย AdminRequired middleware แล้ว
 
-	id := c.Param("id")
-
-	var device models.Device
-	if err := db.DB.First(&device, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, Response{
-			Success: false,
-			Error:   "ไม่พบอุปกรณ์",
-		})
+	device, ok := findDevice(c)
+	if !ok {
 		return
 	}
 
@@ -210,10 +189,10 @@ func UpdateDevice(c *gin.Context) {
 		return
 	}
 
-	// อัปเดตข้อมูลที่อนุญาตให้แก้ไขได้
+	// อัปเดตเฉพาะคอลัมน์ที่อนุญาตให้แก้ไขได้ เพื่อไม่ให้เขียนทับ key ที่กำลังถูกเปลี่ยนพร้อมกัน
 	err := db.Transaction(func(tx *gorm.DB) error {
 		device.Name = input.Name
-		return tx.Save(&device).Error
+		return tx.Model(device).Update("name", input.Name).Error
 	})
 
 	if err != nil {
@@ -230,70 +209,171 @@ func UpdateDevice(c *gin.Context) {
 	})
 }
 
-// ResetDeviceApiKey รีเซ็ต API key ของอุปกรณ์
+// ResetDeviceApiKey รีเซ็ต API key ของอุปกรณ์ทันที (key เดิมใช้ไม่ได้และ token เดิมถูกยกเลิก)
 func ResetDeviceApiKey(c *gin.Context) {
 	// ตรวจสอบว่าเป็น admin โดย AdminRequired middleware แล้ว
 
-	id := c.Param("id")
-
-	var device models.Device
-	if err := db.DB.First(&device, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, Response{
-			Success: false,
-			Error:   "ไม่พบอุปกรณ์",
-		})
+	device, ok := findDevice(c)
+	if !ok {
 		return
 	}
 
-	// สร้าง API key ใหม่แบบสุ่ม
-	newApiKey, err := generateRandomAPIKey(32)
+	// สร้าง API key ใหม่แบบสุ่ม (เก็บเฉพาะ hash) และยกเลิก token เดิมทั้งหมดของอุปกรณ์
+	newApiKey, err := services.ResetDeviceApiKey(device)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Success: false,
-			Error:   "ไม่สามารถสร้าง API key ได้: " + err.Error(),
+			Error:   "ไม่สามารถรีเซ็ต API key ได้: " + err.Error(),
 		})
 		return
 	}
 
-	// อัปเดตค่า
-	err = db.Transaction(func(tx *gorm.DB) error {
-		device.ApiKey = newApiKey
-		device.TokenVersion += 1 // เพิ่มเวอร์ชันเพื่อทำให้ token เก่าหมดอายุ
-		return tx.Save(&device).Error
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data: gin.H{
+			"id":             device.ID,
+			"device_id":      device.DeviceID,
+			"name":           device.Name,
+			"api_key":        newApiKey, // แสดง API key ใหม่ครั้งเดียว
+			"api_key_prefix": device.ApiKeyPrefix,
+			"message":        "API key ถูกรีเซ็ตเรียบร้อยแล้ว ต้องลงทะเบียนอุปกรณ์ใหม่",
+		},
 	})
+}
+
+// RotateDeviceApiKey ออก API key ใหม่โดยที่ key เดิมยังใช้ได้จนหมดช่วง grace period
+// อุปกรณ์ที่ยังใช้ key เดิมจะได้รับ key_rotation ใน response ของการล็อกอิน
+func RotateDeviceApiKey(c *gin.Context) {
+	var input models.RotateDeviceKeyInput
+	if c.Request.ContentLength > 0 && !bindInput(c, &input) {
+		return
+	}
 
+	device, ok := findDevice(c)
+	if !ok {
+		return
+	}
+
+	grace := time.Duration(input.GraceHours) * time.Hour
+	newApiKey, err := services.RotateDeviceApiKey(device, grace)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "ไม่สามารถรีเซ็ต API key ได้: " + err.Error(),
-		})
+		respondDeviceKeyError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, Response{
-		Success: true,
-		Data: gin.H{
-			"id":        device.ID,
-			"device_id": device.DeviceID,
-			"name":      device.Name,
-			"api_key":   newApiKey, // แสดง API key ใหม่
-			"message":   "API key ถูกรีเซ็ตเรียบร้อยแล้ว ต้องลงทะเบียนอุปกรณ์ใหม่",
-		},
+	RespondWithSuccess(c, http.StatusOK, gin.H{
+		"id":                      device.ID,
+		"device_id":               device.DeviceID,
+		"api_key":                 newApiKey, // แสดง API key ใหม่ครั้งเดียว
+		"api_key_prefix":          device.ApiKeyPrefix,
+		"previous_api_key_prefix": device.PreviousApiKeyPrefix,
+		"previous_key_expires_at": device.PreviousKeyExpiresAt,
 	})
 }
 
+// CompleteDeviceKeyRotation ยกเลิก key เดิมทันทีโดยไม่ต้องรอให้หมดช่วง grace period
+func CompleteDeviceKeyRotation(c *gin.Context) {
+	device, ok := findDevice(c)
+	if !ok {
+		return
+	}
+
+	if err := services.CompleteDeviceKeyRotation(device); err != nil {
+		respondDeviceKeyError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "key เดิมของอุปกรณ์ถูกยกเลิกแล้ว"})
+}
+
+// CancelDeviceKeyRotation ยกเลิก key ใหม่และกลับไปใช้ key เดิม
+func CancelDeviceKeyRotation(c *gin.Context) {
+	device, ok := findDevice(c)
+	if !ok {
+		return
+	}
+
+	if err := services.CancelDeviceKeyRotation(device); err != nil {
+		respondDeviceKeyError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "ยกเลิกการเปลี่ยน key แล้ว อุปกรณ์ใช้ key เดิมต่อได้"})
+}
+
+// EnableDeviceRequestSigning สร้าง signing secret ใหม่และบังคับให้อุปกรณ์ลงลายเซ็น HMAC ทุก request
+// ถ้าเปิดอยู่แล้วจะออก secret ใหม่แทน secret เดิมทันที
+func EnableDeviceRequestSigning(c *gin.Context) {
+	device, ok := findDevice(c)
+	if !ok {
+		return
+	}
+
+	secret, err := services.EnableDeviceRequestSigning(device)
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "ไม่สามารถสร้าง signing secret ได้: "+err.Error())
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{
+		"id":                device.ID,
+		"device_id":         device.DeviceID,
+		"signing_secret":    secret, // แสดง secret ครั้งเดียว
+		"require_signature": device.RequireSignature,
+	})
+}
+
+// DisableDeviceRequestSigning ยกเลิกการบังคับลงลายเซ็น อุปกรณ์ใช้ token อย่างเดียวได้อีกครั้ง
+func DisableDeviceRequestSigning(c *gin.Context) {
+	device, ok := findDevice(c)
+	if !ok {
+		return
+	}
+
+	if err := services.DisableDeviceRequestSigning(device); err != nil {
+		if errors.Is(err, services.ErrSigningNotEnabled) {
+			RespondWithError(c, http.StatusConflict, err.Error())
+			return
+		}
+		RespondWithError(c, http.StatusInternalServerError, "ไม่สามารถยกเลิกการลงลายเซ็นได้: "+err.Error())
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "ยกเลิกการบังคับลงลายเซ็นของอุปกรณ์แล้ว"})
+}
+
+// findDevice โหลดอุปกรณ์จาก :id และส่ง 400 ถ้า ID ไม่ใช่ตัวเลข หรือ 404 ถ้าไม่พบ
+func findDevice(c *gin.Context) (*models.Device, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondWithError(c, http.StatusBadRequest, "รหัสอุปกรณ์ไม่ถูกต้อง")
+		return nil, false
+	}
+
+	var device models.Device
+	if err := db.DB.First(&device, "id = ?", id).Error; err != nil {
+		RespondWithError(c, http.StatusNotFound, "ไม่พบอุปกรณ์")
+		return nil, false
+	}
+	return &device, true
+}
+
+// respondDeviceKeyError แปลง error ของการเปลี่ยน key เป็น HTTP response
+func respondDeviceKeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrKeyRotationPending), errors.Is(err, services.ErrNoKeyRotation):
+		RespondWithError(c, http.StatusConflict, err.Error())
+	default:
+		RespondWithError(c, http.StatusInternalServerError, "ไม่สามารถเปลี่ยน API key ได้: "+err.Error())
+	}
+}
+
 // DeleteDevice ลบอุปกรณ์ออกจากระบบ
 func DeleteDevice(c *gin.Context) {
 	// ตรวจสอบว่าเป็น admin โดย AdminRequired middleware แล้ว
 
-	id := c.Param("id")
-
-	var device models.Device
-	if err := db.DB.First(&device, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, Response{
-			Success: false,
-			Error:   "ไม่พบอุปกรณ์",
-		})
+	device, ok := findDevice(c)
+	if !ok {
 		return
 	}
 
@@ -304,8 +384,18 @@ func DeleteDevice(c *gin.Context) {
 			return err
 		}
 
+		// ลบ certificate ที่ผูกไว้
+		if err := tx.Where("device_id = ?", device.ID).Delete(&models.DeviceCertificate{}).Error; err != nil {
+			return err
+		}
+
+		// ลบ nonce ของ request ที่ลงลายเซ็น
+		if err := tx.Where("device_id = ?", device.ID).Delete(&models.DeviceRequestNonce{}).Error; err != nil {
+			return err
+		}
+
 		// ลบอุปกรณ์
-		return tx.Delete(&device).Error
+		return tx.Delete(device).Error
 	})
 
 	if err != nil {
@@ -0,0 +1,110 @@
+// controllers/impersonation.go
+package controllers
+
+import (
+	"dashboard-starter/models"
+	"dashboard-starter/services"
+	"dashboard-starter/utils"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImpersonationResponse is returned when an admin starts impersonating a user
+type ImpersonationResponse struct {
+	Token         string                `json:"token"`
+	ExpiresAt     time.Time             `json:"expires_at"`
+	Impersonation *models.Impersonation `json:"impersonation"`
+}
+
+// StartImpersonation issues a short-lived access token for a user with the current admin as actor
+func StartImpersonation(c *gin.Context) {
+	var input models.ImpersonationInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondWithError(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	adminID := c.MustGet("admin_id").(uint)
+	impersonation, token, expiresAt, err := services.StartImpersonation(adminID, uint(userID), input.Reason, sessionClient(c))
+	if err != nil {
+		respondImpersonationError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusCreated, ImpersonationResponse{
+		Token:         token,
+		ExpiresAt:     expiresAt,
+		Impersonation: impersonation,
+	})
+}
+
+// StopCurrentImpersonation ends the impersonation session of the token making the request
+func StopCurrentImpersonation(c *gin.Context) {
+	sessionID, exists := c.Get("impersonation_id")
+	if !exists {
+		RespondWithError(c, http.StatusBadRequest, "This request is not made with an impersonation token")
+		return
+	}
+
+	if err := services.StopImpersonationSession(sessionID.(string)); err != nil {
+		respondImpersonationError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "Impersonation ended"})
+}
+
+// ListImpersonations returns the impersonation audit trail (?status=active|ended|expired)
+func ListImpersonations(c *gin.Context) {
+	var params utils.PaginationParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		params = utils.NewPaginationParams()
+	}
+
+	impersonations, pagination, err := services.ListImpersonations(params)
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to retrieve impersonations: "+err.Error())
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, impersonations, pagination)
+}
+
+// StopImpersonation lets an admin end a running impersonation session
+func StopImpersonation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondWithError(c, http.StatusBadRequest, "Invalid impersonation ID")
+		return
+	}
+
+	if err := services.StopImpersonation(uint(id)); err != nil {
+		respondImpersonationError(c, err)
+		return
+	}
+
+	adminID, _ := c.Get("admin_id")
+	utils.Info("Admin %v stopped impersonation session %d", adminID, id)
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "Impersonation ended"})
+}
+
+// respondImpersonationError maps impersonation errors to HTTP responses
+func respondImpersonationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPrincipalNotFound):
+		RespondWithError(c, http.StatusNotFound, "User not found")
+	case errors.Is(err, services.ErrImpersonationNotFound):
+		RespondWithError(c, http.StatusNotFound, err.Error())
+	default:
+		RespondWithError(c, http.StatusInternalServerError, "Failed to process impersonation: "+err.Error())
+	}
+}
@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"dashboard-starter/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS publishes the public keys used to verify access and refresh tokens.
+// The response follows RFC 7517 so it is not wrapped in the standard Response envelope.
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.GetJWKS())
+}
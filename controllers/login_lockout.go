@@ -0,0 +1,116 @@
+// controllers/login_lockout.go
+package controllers
+
+import (
+	"dashboard-starter/models"
+	"dashboard-starter/services"
+	"dashboard-starter/utils"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rejectThrottledLogin responds with 429 and returns true while the identifier has to wait
+// because of earlier failed logins. The response is the same whether or not the account exists.
+func rejectThrottledLogin(c *gin.Context, userType, identifier string) bool {
+	retryAfter, err := services.LoginRetryAfter(userType, identifier)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Login failed: " + err.Error(),
+		})
+		return true
+	}
+
+	if retryAfter <= 0 {
+		return false
+	}
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, Response{
+		Success: false,
+		Error:   "Too many failed login attempts. Please try again later",
+		Data:    gin.H{"retry_after": seconds},
+	})
+	return true
+}
+
+// recordLoginFailure counts a failed login; errors are logged so the caller's response stays the same
+func recordLoginFailure(userType, identifier string) {
+	if err := services.RecordLoginFailure(userType, identifier); err != nil {
+		utils.Error("Failed to record login failure for %s: %v", userType, err)
+	}
+}
+
+// resetLoginFailures clears the failure counter after a completed login
+func resetLoginFailures(userType, identifier string) {
+	if err := services.ResetLoginFailures(userType, identifier); err != nil {
+		utils.Error("Failed to reset login failures for %s: %v", userType, err)
+	}
+}
+
+// ListLoginLockouts returns all currently locked logins
+func ListLoginLockouts(c *gin.Context) {
+	attempts, err := services.GetLockedLogins()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to fetch locked logins: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    attempts,
+	})
+}
+
+// UnlockLogin removes the lock and failure counter of an admin, user or device login
+func UnlockLogin(c *gin.Context) {
+	var input models.UnlockAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	// Validate input
+	if err := utils.ValidateStruct(input); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	unlocked, err := services.UnlockLogin(input.UserType, input.Identifier)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to unlock login: " + err.Error(),
+		})
+		return
+	}
+
+	if !unlocked {
+		c.JSON(http.StatusNotFound, Response{
+			Success: false,
+			Error:   "No failed login attempts recorded for this identifier",
+		})
+		return
+	}
+
+	adminID, _ := c.Get("admin_id")
+	utils.Info("Admin %v unlocked %s login %q", adminID, input.UserType, input.Identifier)
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    gin.H{"message": "Login unlocked successfully"},
+	})
+}
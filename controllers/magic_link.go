@@ -0,0 +1,54 @@
+// controllers/magic_link.go
+package controllers
+
+import (
+	"dashboard-starter/models"
+	"dashboard-starter/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestMagicLink emails a passwordless login link to a user
+func RequestMagicLink(c *gin.Context) {
+	var input models.MagicLinkRequestInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	userService := services.NewUserService()
+	if err := userService.RequestMagicLink(input.Email); err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to process login link request")
+		return
+	}
+
+	// Same response whether or not the email exists to prevent user enumeration
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "If the email is registered, a login link has been sent"})
+}
+
+// MagicLinkLogin exchanges an emailed login link for the token pair, like UserLogin
+func MagicLinkLogin(c *gin.Context) {
+	var input models.MagicLinkLoginInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	userService := services.NewUserService()
+	user, err := userService.ConsumeMagicLink(input.Token)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMagicLink) {
+			RespondWithError(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		RespondWithError(c, http.StatusInternalServerError, "Authentication failed: "+err.Error())
+		return
+	}
+
+	// The link replaces the password, not the second factor
+	if beginTwoFactorLogin(c, user.ID, "user", user.Email) {
+		return
+	}
+
+	completeLogin(c, user.ID, "user", nil)
+}
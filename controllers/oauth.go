@@ -0,0 +1,142 @@
+// controllers/oauth.go
+package controllers
+
+import (
+	"dashboard-starter/models"
+	"dashboard-starter/services"
+	"dashboard-starter/utils"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OAuthError is the error body defined by RFC 6749 section 5.2.
+// OAuth endpoints use it instead of Response so standard client libraries understand the errors.
+type OAuthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// IntrospectToken implements RFC 7662 token introspection for registered OAuth clients
+func IntrospectToken(c *gin.Context) {
+	if _, ok := authenticateOAuthClient(c); !ok {
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, OAuthError{Error: "invalid_request", ErrorDescription: "token is required"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, services.IntrospectToken(token, c.PostForm("token_type_hint")))
+}
+
+// RevokeToken implements RFC 7009 token revocation for registered OAuth clients
+func RevokeToken(c *gin.Context) {
+	client, ok := authenticateOAuthClient(c)
+	if !ok {
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, OAuthError{Error: "invalid_request", ErrorDescription: "token is required"})
+		return
+	}
+
+	if err := services.RevokeToken(token, client); err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnsupportedTokenType):
+			c.JSON(http.StatusBadRequest, OAuthError{Error: "unsupported_token_type", ErrorDescription: err.Error()})
+			return
+		case errors.Is(err, services.ErrTokenNotOwned):
+			utils.Warn("OAuth client %s tried to revoke a token issued to another client", client.ClientID)
+			c.JSON(http.StatusBadRequest, OAuthError{Error: "unauthorized_client", ErrorDescription: err.Error()})
+			return
+		}
+		c.JSON(http.StatusServiceUnavailable, OAuthError{Error: "server_error"})
+		return
+	}
+
+	utils.Info("OAuth client %s revoked a token", client.ClientID)
+	c.Status(http.StatusOK)
+}
+
+// authenticateOAuthClient reads client credentials from HTTP Basic auth (client_secret_basic)
+// or the form body (client_secret_post), writing a 401 response on failure
+func authenticateOAuthClient(c *gin.Context) (*models.OAuthClient, bool) {
+	clientID, secret, basic := c.Request.BasicAuth()
+	if basic {
+		// RFC 6749 section 2.3.1 form-encodes the credentials before Basic encoding
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+
+	client, err := services.AuthenticateOAuthClient(clientID, secret)
+	if err != nil {
+		if !errors.Is(err, services.ErrInvalidClient) {
+			utils.Error("OAuth client authentication failed: %v", err)
+		}
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		c.JSON(http.StatusUnauthorized, OAuthError{Error: "invalid_client"})
+		return nil, false
+	}
+
+	return client, true
+}
+
+// ListOAuthClients returns the registered OAuth clients
+func ListOAuthClients(c *gin.Context) {
+	clients, err := services.ListOAuthClients()
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to fetch OAuth clients: "+err.Error())
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, clients)
+}
+
+// CreateOAuthClient registers a client. The secret is only included in this response.
+func CreateOAuthClient(c *gin.Context) {
+	var input models.OAuthClientInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	client, err := services.CreateOAuthClient(&input)
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to create OAuth client: "+err.Error())
+		return
+	}
+
+	adminID, _ := c.Get("admin_id")
+	utils.Info("Admin %v registered OAuth client %s (%s)", adminID, client.ClientID, client.Name)
+	RespondWithSuccess(c, http.StatusCreated, client)
+}
+
+// DeleteOAuthClient removes a client
+func DeleteOAuthClient(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondWithError(c, http.StatusBadRequest, "Invalid client ID")
+		return
+	}
+
+	if err := services.DeleteOAuthClient(uint(id)); err != nil {
+		if errors.Is(err, services.ErrOAuthClientNotFound) {
+			RespondWithError(c, http.StatusNotFound, err.Error())
+			return
+		}
+		RespondWithError(c, http.StatusInternalServerError, "Failed to delete OAuth client: "+err.Error())
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "OAuth client deleted successfully"})
+}
@@ -0,0 +1,110 @@
+// controllers/oidc.go
+package controllers
+
+import (
+	"dashboard-starter/config"
+	"dashboard-starter/models"
+	"dashboard-starter/services"
+	"dashboard-starter/utils"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcStateCookie remembers the state in the browser that started the login
+const oidcStateCookie = "oidc_state"
+
+// StartOIDCLogin redirects the browser to the provider's authorization endpoint.
+// Single-page apps can pass ?redirect=false to receive the URL as JSON instead.
+func StartOIDCLogin(c *gin.Context) {
+	provider := c.Param("provider")
+
+	authURL, state, err := services.OIDCAuthorizationURL(provider)
+	if err != nil {
+		respondOIDCError(c, err)
+		return
+	}
+
+	ttl := time.Duration(config.Config.OIDC.StateMinutes) * time.Minute
+	setOIDCStateCookie(c, provider, state, int(ttl.Seconds()))
+
+	if c.Query("redirect") == "false" {
+		RespondWithSuccess(c, http.StatusOK, gin.H{"authorization_url": authURL})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback completes the login with the code and state the provider redirected back with.
+// It accepts them as query parameters (GET) or as a JSON body (POST) and responds like UserLogin.
+func OIDCCallback(c *gin.Context) {
+	provider := c.Param("provider")
+	if !services.IsOIDCProviderConfigured(provider) {
+		respondOIDCError(c, services.ErrUnknownOIDCProvider)
+		return
+	}
+
+	// The provider reports a cancelled or failed authorization instead of a code
+	if providerError := c.Query("error"); providerError != "" {
+		RespondWithError(c, http.StatusBadRequest, "Authorization failed: "+providerError+" "+c.Query("error_description"))
+		return
+	}
+
+	var input models.OIDCCallbackInput
+	if err := c.ShouldBind(&input); err != nil {
+		RespondWithError(c, http.StatusBadRequest, "Invalid input: "+err.Error())
+		return
+	}
+	if err := utils.ValidateStruct(input); err != nil {
+		RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	boundState, _ := c.Cookie(oidcStateCookie)
+	// The state can only be used once, so the cookie is cleared whatever the outcome
+	setOIDCStateCookie(c, provider, "", -1)
+
+	user, err := services.CompleteOIDCLogin(provider, input.Code, input.State, boundState)
+	if err != nil {
+		respondOIDCError(c, err)
+		return
+	}
+
+	utils.Info("User %d signed in with OIDC provider %s", user.ID, provider)
+
+	// The provider's login does not replace this application's second factor
+	if beginTwoFactorLogin(c, user.ID, "user", user.Email) {
+		return
+	}
+
+	completeLogin(c, user.ID, "user", nil)
+}
+
+// respondOIDCError maps OIDC service errors to HTTP responses
+func respondOIDCError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrUnknownOIDCProvider):
+		RespondWithError(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidOIDCState), errors.Is(err, services.ErrOIDCStateNotBound),
+		errors.Is(err, services.ErrOIDCCodeRejected):
+		RespondWithError(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrInvalidIDToken), errors.Is(err, services.ErrOIDCEmailNotVerified):
+		RespondWithError(c, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, services.ErrOIDCAccountUnverified):
+		RespondWithError(c, http.StatusConflict, err.Error())
+	default:
+		utils.Error("OIDC login failed: %v", err)
+		RespondWithError(c, http.StatusInternalServerError, "OIDC login failed")
+	}
+}
+
+// setOIDCStateCookie stores (or with maxAge -1 clears) the state cookie. It is HttpOnly, scoped to the
+// provider's login and callback routes and sent on the provider's top-level redirect back (SameSite=Lax).
+func setOIDCStateCookie(c *gin.Context, provider, state string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, maxAge, "/api/v1/user/auth/oidc/"+provider, "", secure, true)
+}
@@ -0,0 +1,94 @@
+// controllers/personal_access_token.go
+package controllers
+
+import (
+	"dashboard-starter/models"
+	"dashboard-starter/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListPersonalAccessTokens returns the current principal's personal access tokens
+func ListPersonalAccessTokens(c *gin.Context) {
+	userID, userType, ok := personalTokenOwner(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := services.ListPersonalAccessTokens(userID, userType)
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to fetch tokens: "+err.Error())
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, tokens)
+}
+
+// CreatePersonalAccessToken issues a scoped token. The raw token is only included in this response.
+func CreatePersonalAccessToken(c *gin.Context) {
+	userID, userType, ok := personalTokenOwner(c)
+	if !ok {
+		return
+	}
+
+	var input models.PersonalAccessTokenInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	token, err := services.CreatePersonalAccessToken(userID, userType, &input)
+	if err != nil {
+		respondPersonalTokenError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusCreated, token)
+}
+
+// RevokePersonalAccessToken revokes one of the current principal's tokens
+func RevokePersonalAccessToken(c *gin.Context) {
+	userID, userType, ok := personalTokenOwner(c)
+	if !ok {
+		return
+	}
+
+	tokenID, err := strconv.ParseUint(c.Param("token_id"), 10, 32)
+	if err != nil {
+		RespondWithError(c, http.StatusBadRequest, "Invalid token ID")
+		return
+	}
+
+	if err := services.RevokePersonalAccessToken(userID, userType, uint(tokenID)); err != nil {
+		respondPersonalTokenError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "Token revoked successfully"})
+}
+
+// personalTokenOwner returns the authenticated admin or user; devices cannot own tokens
+func personalTokenOwner(c *gin.Context) (uint, string, bool) {
+	userID, userType := sessionPrincipal(c)
+	if userType != "admin" && userType != "user" {
+		RespondWithError(c, http.StatusForbidden, "Personal access tokens are only available to admins and users")
+		return 0, "", false
+	}
+	return userID, userType, true
+}
+
+// respondPersonalTokenError maps personal access token errors to HTTP responses
+func respondPersonalTokenError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPersonalTokenNotFound):
+		RespondWithError(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrUnknownPermission), errors.Is(err, services.ErrTokenLifetimeTooLong):
+		RespondWithError(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrScopeNotGranted):
+		RespondWithError(c, http.StatusForbidden, err.Error())
+	default:
+		RespondWithError(c, http.StatusInternalServerError, "Token operation failed: "+err.Error())
+	}
+}
@@ -0,0 +1,101 @@
+// controllers/public_article.go
+package controllers
+
+import (
+	"crypto/sha256"
+	"dashboard-starter/config"
+	"dashboard-starter/models"
+	"dashboard-starter/services"
+	"dashboard-starter/utils"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListPublicArticles lists published articles for anonymous readers
+func ListPublicArticles(c *gin.Context) {
+	var params utils.PaginationParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		params = utils.NewPaginationParams()
+		params.OrderBy = ""
+	}
+
+	var filter models.ArticleFilter
+	_ = c.ShouldBindQuery(&filter)
+
+	articleService := services.NewArticleService()
+	articles, pagination, err := articleService.GetPublishedArticles(params, filter)
+	if err != nil {
+		utils.Error("Failed to retrieve public articles: %v", err)
+		RespondWithError(c, http.StatusInternalServerError, "Failed to retrieve articles")
+		return
+	}
+
+	views := make([]models.PublicArticle, len(articles))
+	for i := range articles {
+		views[i] = articles[i].PublicView(false)
+	}
+
+	respondCacheable(c, SuccessResponse(views, pagination))
+}
+
+// GetPublicArticle returns a single published article by its slug
+func GetPublicArticle(c *gin.Context) {
+	articleService := services.NewArticleService()
+	article, err := articleService.GetPublishedArticleBySlug(c.Param("slug"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			RespondWithError(c, http.StatusNotFound, "Article not found")
+			return
+		}
+		utils.Error("Failed to retrieve public article: %v", err)
+		RespondWithError(c, http.StatusInternalServerError, "Failed to retrieve article")
+		return
+	}
+
+	respondCacheable(c, SuccessResponse(article.PublicView(true)))
+}
+
+// respondCacheable sends a response that shared caches may store, with an ETag of its body.
+// A request whose If-None-Match already holds that ETag gets 304 Not Modified without a body.
+func respondCacheable(c *gin.Context, response Response) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to encode response")
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	c.Header("ETag", etag)
+	if maxAge := config.Config.App.PublicCacheSeconds; maxAge > 0 {
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+	} else {
+		c.Header("Cache-Control", "no-cache")
+	}
+
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing weakly as RFC 9110 requires
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
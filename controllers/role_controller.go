@@ -0,0 +1,223 @@
+// controllers/role_controller.go
+package controllers
+
+import (
+	"dashboard-starter/models"
+	"dashboard-starter/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetMyPermissions returns the roles and permissions of the authenticated principal
+func GetMyPermissions(c *gin.Context) {
+	userID, userType := sessionPrincipal(c)
+
+	roles, err := services.GetPrincipalRoles(userID, userType)
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to fetch roles: "+err.Error())
+		return
+	}
+
+	permissions, err := services.GetPermissions(userID, userType)
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to fetch permissions: "+err.Error())
+		return
+	}
+
+	roleNames := make([]string, 0, len(roles))
+	for _, role := range roles {
+		roleNames = append(roleNames, role.Name)
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{
+		"roles":       roleNames,
+		"permissions": permissions,
+	})
+}
+
+// ListRoles returns every role with its permissions
+func ListRoles(c *gin.Context) {
+	roles, err := services.ListRoles()
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to fetch roles: "+err.Error())
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, roles)
+}
+
+// ListPermissions returns every permission that can be granted
+func ListPermissions(c *gin.Context) {
+	permissions, err := services.ListPermissions()
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to fetch permissions: "+err.Error())
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, permissions)
+}
+
+// GetRole returns a single role
+func GetRole(c *gin.Context) {
+	roleID, ok := parseRoleID(c)
+	if !ok {
+		return
+	}
+
+	role, err := services.GetRole(roleID)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, role)
+}
+
+// CreateRole creates a custom role
+func CreateRole(c *gin.Context) {
+	var input models.RoleInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	adminID, _ := c.Get("admin_id")
+	role, err := services.CreateRole(&input, adminID.(uint))
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusCreated, role)
+}
+
+// UpdateRole changes a role and replaces its permissions
+func UpdateRole(c *gin.Context) {
+	roleID, ok := parseRoleID(c)
+	if !ok {
+		return
+	}
+
+	var input models.RoleInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	adminID, _ := c.Get("admin_id")
+	role, err := services.UpdateRole(roleID, &input, adminID.(uint))
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, role)
+}
+
+// DeleteRole deletes a custom role
+func DeleteRole(c *gin.Context) {
+	roleID, ok := parseRoleID(c)
+	if !ok {
+		return
+	}
+
+	if err := services.DeleteRole(roleID); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// ListRoleMembers returns the admins and users that have a role
+func ListRoleMembers(c *gin.Context) {
+	roleID, ok := parseRoleID(c)
+	if !ok {
+		return
+	}
+
+	members, err := services.GetRoleMembers(roleID)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, members)
+}
+
+// AssignRole gives a role to an admin or user
+func AssignRole(c *gin.Context) {
+	roleID, ok := parseRoleID(c)
+	if !ok {
+		return
+	}
+
+	var input models.RoleAssignmentInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	adminID, _ := c.Get("admin_id")
+	if err := services.AssignRole(roleID, input.UserID, input.UserType, adminID.(uint)); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "Role assigned successfully"})
+}
+
+// RemoveRole takes a role away from an admin or user
+func RemoveRole(c *gin.Context) {
+	roleID, ok := parseRoleID(c)
+	if !ok {
+		return
+	}
+
+	userType := c.Param("user_type")
+	if userType != "admin" && userType != "user" {
+		RespondWithError(c, http.StatusBadRequest, "Invalid user type")
+		return
+	}
+
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		RespondWithError(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := services.RemoveRole(roleID, uint(userID), userType); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "Role removed successfully"})
+}
+
+// parseRoleID reads the :id path parameter, writing the error response on failure
+func parseRoleID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondWithError(c, http.StatusBadRequest, "Invalid role ID")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondRoleError maps role service errors to HTTP responses
+func respondRoleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrRoleNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+		RespondWithError(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrRoleExists):
+		RespondWithError(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrSystemRole), errors.Is(err, services.ErrLastActiveAdmin),
+		errors.Is(err, services.ErrRoleEscalation):
+		RespondWithError(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrUnknownPermission), errors.Is(err, services.ErrRoleNotAssigned):
+		RespondWithError(c, http.StatusBadRequest, err.Error())
+	default:
+		RespondWithError(c, http.StatusInternalServerError, "Role operation failed: "+err.Error())
+	}
+}
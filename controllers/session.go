@@ -0,0 +1,187 @@
+// controllers/session.go
+package controllers
+
+import (
+	"dashboard-starter/models"
+	"dashboard-starter/services"
+	"dashboard-starter/utils"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceLabelHeader lets clients name the session they start, e.g. "Work laptop"
+const DeviceLabelHeader = "X-Device-Label"
+
+// sessionClient collects the client details stored with a new or rotated refresh token
+func sessionClient(c *gin.Context) models.SessionClient {
+	return models.SessionClient{
+		UserAgent:   c.Request.UserAgent(),
+		IPAddress:   c.ClientIP(),
+		DeviceLabel: strings.TrimSpace(c.GetHeader(DeviceLabelHeader)),
+	}
+}
+
+// deviceSessionClient labels IoT device sessions with the device name unless the device sends its own label
+func deviceSessionClient(c *gin.Context, device *models.Device) models.SessionClient {
+	client := sessionClient(c)
+	if client.DeviceLabel == "" {
+		client.DeviceLabel = device.Name
+	}
+	return client
+}
+
+// ListSessions returns the active sessions of the authenticated principal
+func ListSessions(c *gin.Context) {
+	userID, userType := sessionPrincipal(c)
+
+	sessions, err := services.ListSessions(userID, userType)
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to fetch sessions: "+err.Error())
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, sessions)
+}
+
+// RevokeSession signs the authenticated principal out of one of its sessions
+func RevokeSession(c *gin.Context) {
+	userID, userType := sessionPrincipal(c)
+
+	sessionID, ok := parseSessionID(c)
+	if !ok {
+		return
+	}
+
+	if err := services.RevokeSession(userID, userType, sessionID); err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeOtherSessions signs the authenticated principal out everywhere except the session of the given refresh token
+func RevokeOtherSessions(c *gin.Context) {
+	userID, userType := sessionPrincipal(c)
+
+	var input models.RevokeOtherSessionsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		RespondWithError(c, http.StatusBadRequest, "Invalid input: "+err.Error())
+		return
+	}
+
+	if err := services.RevokeOtherSessions(userID, userType, input.RefreshToken); err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "All other sessions have been revoked"})
+}
+
+// ListUserSessions returns the active sessions of a user (admin view)
+func ListUserSessions(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := services.ListSessions(userID, "user")
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to fetch sessions: "+err.Error())
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, sessions)
+}
+
+// RevokeUserSession signs a user out of one session (admin action)
+func RevokeUserSession(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	sessionID, ok := parseSessionID(c)
+	if !ok {
+		return
+	}
+
+	if err := services.RevokeSession(userID, "user", sessionID); err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeAllUserSessions signs a user out everywhere, including current access tokens (admin action)
+func RevokeAllUserSessions(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.RevokeAllRefreshTokens(userID, "user"); err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to revoke sessions: "+err.Error())
+		return
+	}
+
+	// Access tokens stay valid until they expire unless the token version changes
+	if err := services.RotateUserTokenVersion(userID, "user"); err != nil {
+		RespondWithError(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if err := services.RevokeAllPersonalAccessTokens(userID, "user"); err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to revoke personal access tokens: "+err.Error())
+		return
+	}
+
+	adminID, _ := c.Get("admin_id")
+	utils.Info("Admin %v revoked all sessions of user %d", adminID, userID)
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "All sessions of the user have been revoked"})
+}
+
+// sessionPrincipal returns the authenticated principal set by AuthMiddleware
+func sessionPrincipal(c *gin.Context) (uint, string) {
+	userID, _ := c.Get("user_id")
+	userType, _ := c.Get("user_type")
+
+	id, _ := userID.(uint)
+	typ, _ := userType.(string)
+	return id, typ
+}
+
+// parseSessionID reads the :session_id path parameter (a token family ID), writing the error response on failure
+func parseSessionID(c *gin.Context) (string, bool) {
+	id := c.Param("session_id")
+	if id == "" || len(id) > 64 {
+		RespondWithError(c, http.StatusBadRequest, "Invalid session ID")
+		return "", false
+	}
+	return id, true
+}
+
+// parseUserIDParam reads the :id path parameter, writing the error response on failure
+func parseUserIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondWithError(c, http.StatusBadRequest, "Invalid ID format")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondSessionError maps session service errors to HTTP responses
+func respondSessionError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrSessionNotFound) {
+		RespondWithError(c, http.StatusNotFound, err.Error())
+		return
+	}
+	RespondWithError(c, http.StatusInternalServerError, "Failed to revoke session: "+err.Error())
+}
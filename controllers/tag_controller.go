@@ -0,0 +1,104 @@
+// controllers/tag_controller.go
+package controllers
+
+import (
+	"dashboard-starter/models"
+	"dashboard-starter/services"
+	"dashboard-starter/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListTags handles the request to list tags with pagination and search
+func ListTags(c *gin.Context) {
+	var params utils.PaginationParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		params = utils.NewPaginationParams()
+	}
+
+	tagService := services.NewTagService()
+	tags, pagination, err := tagService.GetTags(params)
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to retrieve tags: "+err.Error())
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, tags, pagination)
+}
+
+// CreateTag handles the request to create a tag
+func CreateTag(c *gin.Context) {
+	var input models.TagInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	tagService := services.NewTagService()
+	tag, err := tagService.CreateTag(&input)
+	if err != nil {
+		respondTaxonomyError(c, "Failed to create tag: ", err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusCreated, tag)
+}
+
+// GetTag handles the request to get a tag by ID
+func GetTag(c *gin.Context) {
+	tagService := services.NewTagService()
+	tag, err := tagService.GetByID(c.Param("id"))
+	if err != nil {
+		RespondWithError(c, http.StatusNotFound, "Tag not found")
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, tag)
+}
+
+// UpdateTag handles the request to update a tag
+func UpdateTag(c *gin.Context) {
+	var input models.TagInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	tagService := services.NewTagService()
+	tag, err := tagService.UpdateTag(c.Param("id"), &input)
+	if err != nil {
+		respondTaxonomyError(c, "Failed to update tag: ", err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, tag)
+}
+
+// DeleteTag handles the request to delete a tag
+func DeleteTag(c *gin.Context) {
+	tagService := services.NewTagService()
+	if err := tagService.DeleteTag(c.Param("id")); err != nil {
+		respondTaxonomyError(c, "Failed to delete tag: ", err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+}
+
+// respondTaxonomyError maps tag and category errors to HTTP status codes
+func respondTaxonomyError(c *gin.Context, prefix string, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		statusCode = http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidSlug),
+		errors.Is(err, services.ErrInvalidCategoryParent),
+		err.Error() == "invalid ID format":
+		statusCode = http.StatusBadRequest
+	case errors.Is(err, services.ErrCategoryHasChildren):
+		statusCode = http.StatusConflict
+	}
+
+	RespondWithError(c, statusCode, prefix+err.Error())
+}
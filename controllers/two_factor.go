@@ -0,0 +1,428 @@
+package controllers
+
+import (
+	"dashboard-starter/config"
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"dashboard-starter/services"
+	"dashboard-starter/utils"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TwoFactorChallengeResponse is returned by the password step when a second factor is needed
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	SetupRequired     bool      `json:"setup_required,omitempty"`
+	ChallengeToken    string    `json:"challenge_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+	Secret            string    `json:"secret,omitempty"`
+	ProvisioningURI   string    `json:"provisioning_uri,omitempty"`
+}
+
+// beginTwoFactorLogin answers the password step with a challenge when the principal has 2FA
+// enabled, or must enroll because 2FA is mandatory for its user type.
+// It returns true when a response has been written and the login must not continue.
+func beginTwoFactorLogin(c *gin.Context, userID uint, userType, accountName string) bool {
+	enabled, err := services.IsTwoFactorEnabled(userID, userType)
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Authentication failed: "+err.Error())
+		return true
+	}
+
+	response := TwoFactorChallengeResponse{TwoFactorRequired: true}
+
+	if !enabled {
+		required, err := services.IsTwoFactorRequired(userType)
+		if err != nil {
+			RespondWithError(c, http.StatusInternalServerError, "Authentication failed: "+err.Error())
+			return true
+		}
+		if !required {
+			return false
+		}
+
+		// 2FA is mandatory but not set up yet: enrollment is completed by the verify step
+		secret, uri, err := services.ResumeTwoFactorEnrollment(userID, userType, accountName)
+		if err != nil {
+			RespondWithError(c, http.StatusInternalServerError, "Failed to start two-factor setup: "+err.Error())
+			return true
+		}
+		response.SetupRequired = true
+		response.Secret = secret
+		response.ProvisioningURI = uri
+	}
+
+	ttl := time.Duration(config.Config.Security.TwoFactorChallengeMinutes) * time.Minute
+	challenge, exp, err := utils.GenerateChallengeToken(userID, userType, ttl)
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to generate challenge token: "+err.Error())
+		return true
+	}
+	response.ChallengeToken = challenge
+	response.ExpiresAt = exp
+
+	RespondWithSuccess(c, http.StatusOK, response)
+	return true
+}
+
+// completeLogin invalidates previous access tokens, records the login and returns a new token pair
+func completeLogin(c *gin.Context, userID uint, userType string, recoveryCodes []string) {
+	var tokenVersion int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		switch userType {
+		case "admin":
+			var admin models.Admin
+			if err := tx.First(&admin, userID).Error; err != nil {
+				return err
+			}
+			admin.TokenVersion += 1
+			admin.LastLogin = time.Now()
+			tokenVersion = admin.TokenVersion
+			return tx.Save(&admin).Error
+		case "user":
+			var user models.User
+			if err := tx.First(&user, userID).Error; err != nil {
+				return err
+			}
+			user.TokenVersion += 1
+			user.LastLogin = time.Now()
+			tokenVersion = user.TokenVersion
+			return tx.Save(&user).Error
+		default:
+			return errors.New("unsupported user type")
+		}
+	})
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Authentication failed: " + err.Error(),
+		})
+		return
+	}
+
+	// Generate refresh token
+	refreshTokenObj, err := services.CreateRefreshToken(userID, userType, sessionClient(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to generate refresh token: " + err.Error(),
+		})
+		return
+	}
+
+	// Generate JWT token
+	token, exp, err := utils.GenerateToken(userID, userType, tokenVersion, refreshTokenObj.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Error:   "Failed to generate token: " + err.Error(),
+		})
+		return
+	}
+
+	// Return token
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data: LoginResponse{
+			Token:         token,
+			RefreshToken:  refreshTokenObj.Token,
+			ExpiresAt:     exp,
+			UserID:        userID,
+			UserType:      userType,
+			RecoveryCodes: recoveryCodes,
+		},
+	})
+}
+
+// VerifyTwoFactorLogin exchanges a challenge token and a TOTP or recovery code for a token pair
+func VerifyTwoFactorLogin(c *gin.Context) {
+	var input models.TwoFactorVerifyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		RespondWithError(c, http.StatusBadRequest, "Invalid input: "+err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(input); err != nil {
+		RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if input.Code == "" && input.RecoveryCode == "" {
+		RespondWithError(c, http.StatusBadRequest, "code or recovery_code is required")
+		return
+	}
+
+	userID, userType, challengeID, err := utils.ParseChallengeToken(input.ChallengeToken)
+	if err != nil {
+		RespondWithError(c, http.StatusUnauthorized, "Invalid challenge token: "+err.Error())
+		return
+	}
+
+	// Second-factor guesses share the failure counter and lock of the password step
+	identifier, err := principalEmail(userID, userType)
+	if err != nil {
+		RespondWithError(c, http.StatusUnauthorized, "Invalid challenge token")
+		return
+	}
+	if rejectThrottledLogin(c, userType, identifier) {
+		return
+	}
+
+	ttl := time.Duration(config.Config.Security.TwoFactorChallengeMinutes) * time.Minute
+	if err := services.TrackChallengeAttempt(challengeID, time.Now().Add(ttl)); err != nil {
+		RespondWithError(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	enabled, err := services.IsTwoFactorEnabled(userID, userType)
+	if err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Authentication failed: "+err.Error())
+		return
+	}
+
+	var recoveryCodes []string
+	switch {
+	case !enabled:
+		// Mandatory enrollment started by the password step
+		if input.Code == "" {
+			RespondWithError(c, http.StatusBadRequest, "code is required to finish two-factor setup")
+			return
+		}
+		recoveryCodes, err = services.ConfirmTwoFactorEnrollment(userID, userType, input.Code)
+	case input.Code != "":
+		err = services.VerifyTwoFactorCode(userID, userType, input.Code)
+	default:
+		err = services.UseRecoveryCode(userID, userType, input.RecoveryCode)
+	}
+
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidTwoFactorCode) || errors.Is(err, services.ErrTwoFactorNotEnabled) {
+			recordLoginFailure(userType, identifier)
+			RespondWithError(c, http.StatusUnauthorized, "Invalid two-factor code")
+			return
+		}
+		RespondWithError(c, http.StatusInternalServerError, "Authentication failed: "+err.Error())
+		return
+	}
+
+	if err := services.ConsumeChallenge(challengeID); err != nil {
+		RespondWithError(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	resetLoginFailures(userType, identifier)
+	completeLogin(c, userID, userType, recoveryCodes)
+}
+
+// EnrollTwoFactor starts TOTP enrollment for the authenticated admin or user
+func EnrollTwoFactor(c *gin.Context) {
+	userID, userType, ok := twoFactorPrincipal(c)
+	if !ok {
+		return
+	}
+
+	accountName, err := principalEmail(userID, userType)
+	if err != nil {
+		RespondWithError(c, http.StatusNotFound, "Account not found")
+		return
+	}
+
+	secret, uri, err := services.BeginTwoFactorEnrollment(userID, userType, accountName)
+	if err != nil {
+		if errors.Is(err, services.ErrTwoFactorAlreadyEnabled) {
+			RespondWithError(c, http.StatusConflict, err.Error())
+			return
+		}
+		RespondWithError(c, http.StatusInternalServerError, "Failed to start two-factor setup: "+err.Error())
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{
+		"secret":           secret,
+		"provisioning_uri": uri,
+		"message":          "Scan the provisioning URI as a QR code, then confirm with a code from your authenticator app",
+	})
+}
+
+// ConfirmTwoFactor enables 2FA after the first valid code and returns recovery codes once
+func ConfirmTwoFactor(c *gin.Context) {
+	userID, userType, ok := twoFactorPrincipal(c)
+	if !ok {
+		return
+	}
+
+	var input models.TwoFactorCodeInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	codes, err := services.ConfirmTwoFactorEnrollment(userID, userType, input.Code)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{
+		"recovery_codes": codes,
+		"message":        "Two-factor authentication enabled. Store these recovery codes somewhere safe, they are shown only once",
+	})
+}
+
+// DisableTwoFactor turns 2FA off after checking the password and a current code
+func DisableTwoFactor(c *gin.Context) {
+	userID, userType, ok := twoFactorPrincipal(c)
+	if !ok {
+		return
+	}
+
+	var input models.TwoFactorDisableInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	if err := services.CheckPassword(userID, userType, input.Password); err != nil {
+		RespondWithError(c, http.StatusBadRequest, "Password is incorrect")
+		return
+	}
+
+	if err := services.VerifyTwoFactorCode(userID, userType, input.Code); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	if err := services.DisableTwoFactor(userID, userType); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the authenticated principal
+func RegenerateRecoveryCodes(c *gin.Context) {
+	userID, userType, ok := twoFactorPrincipal(c)
+	if !ok {
+		return
+	}
+
+	var input models.TwoFactorCodeInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	if err := services.VerifyTwoFactorCode(userID, userType, input.Code); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	codes, err := services.RegenerateRecoveryCodes(userID, userType)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// GetTwoFactorPolicy returns whether 2FA is mandatory for each user type
+func GetTwoFactorPolicy(c *gin.Context) {
+	policy := gin.H{}
+	for _, userType := range []string{"admin", "user"} {
+		required, err := services.IsTwoFactorRequired(userType)
+		if err != nil {
+			RespondWithError(c, http.StatusInternalServerError, "Failed to load two-factor policy: "+err.Error())
+			return
+		}
+		policy[userType] = required
+	}
+
+	RespondWithSuccess(c, http.StatusOK, policy)
+}
+
+// SetTwoFactorPolicy makes 2FA mandatory or optional for a user type
+func SetTwoFactorPolicy(c *gin.Context) {
+	var input models.TwoFactorPolicyInput
+	if !bindInput(c, &input) {
+		return
+	}
+
+	if err := services.SetTwoFactorRequired(input.UserType, *input.Required); err != nil {
+		RespondWithError(c, http.StatusInternalServerError, "Failed to update two-factor policy: "+err.Error())
+		return
+	}
+
+	RespondWithSuccess(c, http.StatusOK, gin.H{
+		"user_type": input.UserType,
+		"required":  *input.Required,
+	})
+}
+
+// twoFactorPrincipal reads the authenticated principal and rejects user types without 2FA support
+func twoFactorPrincipal(c *gin.Context) (uint, string, bool) {
+	userID, _ := c.Get("user_id")
+	userType, _ := c.Get("user_type")
+
+	id, _ := userID.(uint)
+	typ, _ := userType.(string)
+	if typ != "admin" && typ != "user" {
+		RespondWithError(c, http.StatusForbidden, "Two-factor authentication is only available for admins and users")
+		return 0, "", false
+	}
+
+	return id, typ, true
+}
+
+// bindInput binds and validates a JSON body, writing the error response on failure
+func bindInput(c *gin.Context, input interface{}) bool {
+	if err := c.ShouldBindJSON(input); err != nil {
+		RespondWithError(c, http.StatusBadRequest, "Invalid input: "+err.Error())
+		return false
+	}
+
+	if err := utils.ValidateStruct(input); err != nil {
+		RespondWithError(c, http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	return true
+}
+
+// respondTwoFactorError maps two-factor service errors to HTTP status codes
+func respondTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		RespondWithError(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrTwoFactorNotEnabled), errors.Is(err, services.ErrTwoFactorAlreadyEnabled):
+		RespondWithError(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrTwoFactorRequired):
+		RespondWithError(c, http.StatusForbidden, err.Error())
+	default:
+		RespondWithError(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// principalEmail returns the email of an admin or user, used as the authenticator account name
+func principalEmail(userID uint, userType string) (string, error) {
+	switch userType {
+	case "admin":
+		var admin models.Admin
+		if err := db.DB.Select("email").First(&admin, userID).Error; err != nil {
+			return "", err
+		}
+		return admin.Email, nil
+	case "user":
+		var user models.User
+		if err := db.DB.Select("email").First(&user, userID).Error; err != nil {
+			return "", err
+		}
+		return user.Email, nil
+	default:
+		return "", errors.New("unsupported user type")
+	}
+}
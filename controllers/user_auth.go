@@ -1,10 +1,12 @@
 package controllers
 
 import (
+	"dashboard-starter/config"
 	"dashboard-starter/db"
 	"dashboard-starter/models"
 	"dashboard-starter/services"
 	"dashboard-starter/utils"
+	"errors"
 	"net/http"
 	"time"
 
@@ -89,22 +91,40 @@ func UserRegister(c *gin.Context) {
 		return
 	}
 
-	// Generate tokens
-	token, exp, err := utils.GenerateToken(user.ID, "user", user.TokenVersion)
+	// Send the verification link; registration still succeeds if mail cannot be queued
+	userService := services.NewUserService()
+	if err := userService.SendVerificationEmail(&user); err != nil {
+		utils.Error("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+
+	// Without a verified email the user cannot login yet, so no tokens are issued
+	if config.Config.Security.RequireEmailVerification {
+		c.JSON(http.StatusCreated, Response{
+			Success: true,
+			Data: gin.H{
+				"message": "Registration successful. Please check your email to verify your address",
+				"user_id": user.ID,
+			},
+		})
+		return
+	}
+
+	// Generate refresh token
+	refreshTokenObj, err := services.CreateRefreshToken(user.ID, "user", sessionClient(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Success: false,
-			Error:   "Failed to generate token: " + err.Error(),
+			Error:   "Failed to generate refresh token: " + err.Error(),
 		})
 		return
 	}
 
-	// Generate refresh token
-	refreshTokenObj, err := services.CreateRefreshToken(user.ID, "user")
+	// Generate access token bound to the new session
+	token, exp, err := utils.GenerateToken(user.ID, "user", user.TokenVersion, refreshTokenObj.FamilyID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Success: false,
-			Error:   "Failed to generate refresh token: " + err.Error(),
+			Error:   "Failed to generate token: " + err.Error(),
 		})
 		return
 	}
@@ -144,9 +164,16 @@ func UserLogin(c *gin.Context) {
 		return
 	}
 
+	// Back off repeated failures for this email before checking credentials
+	if rejectThrottledLogin(c, "user", input.Email) {
+		return
+	}
+
 	// Find user by email
 	var user models.User
 	if err := db.DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
+		recordLoginFailure("user", input.Email)
+
 		// Use a generic error message to prevent user enumeration
 		c.JSON(http.StatusUnauthorized, Response{
 			Success: false,
@@ -157,6 +184,8 @@ func UserLogin(c *gin.Context) {
 
 	// Compare password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		recordLoginFailure("user", input.Email)
+
 		// Use a generic error message to prevent user enumeration
 		c.JSON(http.StatusUnauthorized, Response{
 			Success: false,
@@ -165,52 +194,23 @@ func UserLogin(c *gin.Context) {
 		return
 	}
 
-	// Update token version inside transaction
-	err := db.Transaction(func(tx *gorm.DB) error {
-		user.TokenVersion += 1
-		user.LastLogin = time.Now()
-		return tx.Save(&user).Error
-	})
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Authentication failed: " + err.Error(),
-		})
-		return
-	}
-
-	// Generate JWT token
-	token, exp, err := utils.GenerateToken(user.ID, "user", user.TokenVersion)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
+	// Checked after the password so unverified accounts are not revealed to guessers
+	if config.Config.Security.RequireEmailVerification && user.EmailVerifiedAt == nil {
+		c.JSON(http.StatusForbidden, Response{
 			Success: false,
-			Error:   "Failed to generate token: " + err.Error(),
+			Error:   "Email address has not been verified",
 		})
 		return
 	}
 
-	// Generate refresh token
-	refreshTokenObj, err := services.CreateRefreshToken(user.ID, "user")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Success: false,
-			Error:   "Failed to generate refresh token: " + err.Error(),
-		})
+	// Continue with a second factor if the user has 2FA enabled or it is mandatory
+	if beginTwoFactorLogin(c, user.ID, "user", user.Email) {
 		return
 	}
 
-	// Return token
-	c.JSON(http.StatusOK, Response{
-		Success: true,
-		Data: LoginResponse{
-			Token:        token,
-			RefreshToken: refreshTokenObj.Token,
-			ExpiresAt:    exp,
-			UserID:       user.ID,
-			UserType:     "user",
-		},
-	})
+	// Only a completed login clears the failure counter, a correct password alone does not
+	resetLoginFailures("user", input.Email)
+	completeLogin(c, user.ID, "user", nil)
 }
 
 // UserLogout handles the user logout
@@ -233,6 +233,12 @@ func UserLogout(c *gin.Context) {
 		return tx.Save(&user).Error
 	})
 
+	// End the session the access token belongs to, so its refresh token cannot start it again.
+	// Personal access tokens are not tied to a login session and stay valid.
+	if sessionID, ok := c.Get("session_id"); ok && err == nil {
+		err = services.RevokeRefreshTokenFamily(sessionID.(string))
+	}
+
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Success: false,
@@ -252,7 +258,7 @@ func GetUserProfile(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 
 	var user models.User
-	if err := db.DB.Select("id, name, email, created_at, updated_at, last_login").First(&user, userID).Error; err != nil {
+	if err := db.DB.Select("id, name, email, email_verified_at, created_at, updated_at, last_login").First(&user, userID).Error; err != nil {
 		c.JSON(http.StatusNotFound, Response{
 			Success: false,
 			Error:   "User not found",
@@ -298,52 +304,200 @@ func ChangeUserPassword(c *gin.Context) {
 		return
 	}
 
-	// Find user
-	var user models.User
-	if err := db.DB.First(&user, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, Response{
+	// Update the password and sign out every other session; the caller's session stays signed in
+	sessionID, _ := c.Get("session_id")
+	keepSessionID, _ := sessionID.(string)
+
+	userService := services.NewUserService()
+	if err := userService.ChangeUserPassword(userID.(uint), input.CurrentPassword, input.NewPassword, keepSessionID); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, Response{
+				Success: false,
+				Error:   "User not found",
+			})
+		case errors.Is(err, services.ErrIncorrectPassword):
+			c.JSON(http.StatusBadRequest, Response{
+				Success: false,
+				Error:   "Current password is incorrect",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, Response{
+				Success: false,
+				Error:   "Failed to update password: " + err.Error(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    gin.H{"message": "Password updated successfully"},
+	})
+}
+
+// ForgotPassword sends a password reset link to the user's email
+func ForgotPassword(c *gin.Context) {
+	var input models.ForgotPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
 			Success: false,
-			Error:   "User not found",
+			Error:   "Invalid input: " + err.Error(),
 		})
 		return
 	}
 
-	// Verify current password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.CurrentPassword)); err != nil {
+	// Validate input
+	if err := utils.ValidateStruct(input); err != nil {
 		c.JSON(http.StatusBadRequest, Response{
 			Success: false,
-			Error:   "Current password is incorrect",
+			Error:   err.Error(),
 		})
 		return
 	}
 
-	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
-	if err != nil {
+	userService := services.NewUserService()
+	if err := userService.RequestPasswordReset(input.Email); err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Success: false,
-			Error:   "Failed to update password: " + err.Error(),
+			Error:   "Failed to process password reset request",
 		})
 		return
 	}
 
-	// Update password and invalidate tokens
-	err = db.Transaction(func(tx *gorm.DB) error {
-		user.Password = string(hashedPassword)
-		user.TokenVersion += 1 // Invalidate all tokens
-		return tx.Save(&user).Error
+	// Same response whether or not the email exists to prevent user enumeration
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    gin.H{"message": "If the email is registered, a password reset link has been sent"},
 	})
+}
 
+// ResetPassword sets a new password using an emailed reset token
+func ResetPassword(c *gin.Context) {
+	var input models.ResetPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	// Validate input
+	if err := utils.ValidateStruct(input); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// ตรวจสอบความแข็งแรงของรหัสผ่านใหม่
+	isStrong, passwordMsg := utils.IsStrongPassword(input.NewPassword)
+	if !isStrong {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "New password not strong enough: " + passwordMsg,
+		})
+		return
+	}
+
+	userService := services.NewUserService()
+	if err := userService.ResetPasswordWithToken(input.Token, input.NewPassword); err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidResetToken) {
+			statusCode = http.StatusBadRequest
+		}
+
+		c.JSON(statusCode, Response{
+			Success: false,
+			Error:   "Failed to reset password: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    gin.H{"message": "Password has been reset. Please login with your new password"},
+	})
+}
+
+// VerifyEmail confirms the user's email address using the token from the verification link
+func VerifyEmail(c *gin.Context) {
+	var input models.VerifyEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	// Validate input
+	if err := utils.ValidateStruct(input); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	userService := services.NewUserService()
+	user, err := userService.VerifyEmail(input.Token)
 	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidVerificationToken) {
+			statusCode = http.StatusBadRequest
+		}
+
+		c.JSON(statusCode, Response{
+			Success: false,
+			Error:   "Failed to verify email: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data: gin.H{
+			"message":           "Email address verified successfully",
+			"email_verified_at": user.EmailVerifiedAt,
+		},
+	})
+}
+
+// ResendVerification sends a new verification link to an unverified user's email
+func ResendVerification(c *gin.Context) {
+	var input models.ResendVerificationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "Invalid input: " + err.Error(),
+		})
+		return
+	}
+
+	// Validate input
+	if err := utils.ValidateStruct(input); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	userService := services.NewUserService()
+	if err := userService.ResendVerificationEmail(input.Email); err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Success: false,
-			Error:   "Failed to update password: " + err.Error(),
+			Error:   "Failed to process verification request",
 		})
 		return
 	}
 
+	// Same response whether or not the email exists to prevent user enumeration
 	c.JSON(http.StatusOK, Response{
 		Success: true,
-		Data:    gin.H{"message": "Password updated successfully"},
+		Data:    gin.H{"message": "If the email is registered and not yet verified, a verification link has been sent"},
 	})
 }
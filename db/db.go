@@ -74,11 +74,19 @@ func runMigrations() error {
 	// ลงทะเบียนโมเดลทั้งหมด
 	RegisterAllModels()
 
+	// ตรวจสอบ data migration ที่ต้องทำก่อนที่ schema จะเปลี่ยน
+	pending := pendingDataMigrations()
+
 	// ทำ migration
 	if err := DB.AutoMigrate(GetAllModels()...); err != nil {
 		return err
 	}
 
+	// ทำ data migration ที่ AutoMigrate ทำให้ไม่ได้
+	if err := runDataMigrations(pending); err != nil {
+		return err
+	}
+
 	log.Println("Database migrations completed successfully")
 	return nil
 }
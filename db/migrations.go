@@ -0,0 +1,198 @@
+package db
+
+import (
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// dataMigration is a one-time data change that AutoMigrate cannot handle
+type dataMigration struct {
+	Name string
+	// Needed is checked before AutoMigrate runs, so it can detect columns that are about to be added
+	Needed func() bool
+	Run    func() error
+}
+
+// dataMigrations lists all data migrations in the order they run
+var dataMigrations = []dataMigration{
+	{
+		Name: "hash refresh tokens",
+		Needed: func() bool {
+			return DB.Migrator().HasColumn(&models.RefreshToken{}, "token")
+		},
+		Run: MigrateRefreshTokenHashes,
+	},
+	{
+		Name: "backfill refresh token families",
+		Needed: func() bool {
+			migrator := DB.Migrator()
+			if !migrator.HasTable(&models.RefreshToken{}) {
+				return false
+			}
+			if !migrator.HasColumn(&models.RefreshToken{}, "family_id") {
+				return true
+			}
+			var count int64
+			DB.Model(&models.RefreshToken{}).Where("family_id IS NULL OR family_id = ?", "").Count(&count)
+			return count > 0
+		},
+		Run: BackfillRefreshTokenFamilies,
+	},
+	{
+		Name: "mark existing users as verified",
+		Needed: func() bool {
+			return DB.Migrator().HasTable(&models.User{}) &&
+				!DB.Migrator().HasColumn(&models.User{}, "email_verified_at")
+		},
+		Run: MarkExistingUsersVerified,
+	},
+	{
+		Name: "add article full-text search column",
+		Needed: func() bool {
+			return !DB.Migrator().HasColumn(&models.Article{}, "search_vector")
+		},
+		Run: AddArticleSearchVector,
+	},
+	{
+		Name: "add article substring search index",
+		Needed: func() bool {
+			return !DB.Migrator().HasIndex(&models.Article{}, "idx_articles_search_trgm")
+		},
+		Run: AddArticleTrigramIndex,
+	},
+}
+
+// pendingDataMigrations returns the data migrations that still need to run
+func pendingDataMigrations() []dataMigration {
+	var pending []dataMigration
+	for _, migration := range dataMigrations {
+		if migration.Needed() {
+			pending = append(pending, migration)
+		}
+	}
+	return pending
+}
+
+// runDataMigrations runs the given data migrations after the schema has been migrated
+func runDataMigrations(pending []dataMigration) error {
+	for _, migration := range pending {
+		log.Printf("Running data migration: %s", migration.Name)
+		if err := migration.Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateRefreshTokenHashes converts refresh tokens stored as raw JWT strings
+// into a token ID and SHA-256 digest, then drops the plaintext column
+func MigrateRefreshTokenHashes() error {
+	migrator := DB.Migrator()
+	if !migrator.HasColumn(&models.RefreshToken{}, "token") {
+		return nil
+	}
+
+	log.Println("Migrating plaintext refresh tokens to hashed storage...")
+
+	type legacyRefreshToken struct {
+		ID    uint
+		Token string
+	}
+
+	return Transaction(func(tx *gorm.DB) error {
+		var rows []legacyRefreshToken
+		if err := tx.Table("refresh_tokens").
+			Select("id, token").
+			Where("token_hash IS NULL OR token_hash = ?", "").
+			Find(&rows).Error; err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			if err := tx.Table("refresh_tokens").
+				Where("id = ?", row.ID).
+				Updates(map[string]interface{}{
+					"token_id":   utils.RefreshTokenID(row.Token),
+					"token_hash": utils.HashToken(row.Token),
+				}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Migrator().DropColumn(&models.RefreshToken{}, "token"); err != nil {
+			return err
+		}
+
+		log.Printf("Migrated %d refresh tokens to hashed storage", len(rows))
+		return nil
+	})
+}
+
+// BackfillRefreshTokenFamilies gives every refresh token issued before rotation existed its own family,
+// so presenting one of them again after it was rotated still revokes the session it started.
+// It runs before the server starts, so the token service can rely on every refresh token having a family.
+func BackfillRefreshTokenFamilies() error {
+	result := DB.Model(&models.RefreshToken{}).
+		Where("family_id IS NULL OR family_id = ?", "").
+		Update("family_id", gorm.Expr("'legacy-' || id"))
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		log.Printf("Assigned token families to %d legacy refresh tokens", result.RowsAffected)
+	}
+	return nil
+}
+
+// MarkExistingUsersVerified treats users created before email verification existed as verified,
+// so enabling SECURITY_REQUIRE_EMAIL_VERIFICATION does not lock them out
+func MarkExistingUsersVerified() error {
+	result := DB.Model(&models.User{}).
+		Where("email_verified_at IS NULL").
+		Update("email_verified_at", gorm.Expr("created_at"))
+	if result.Error != nil {
+		return result.Error
+	}
+
+	log.Printf("Marked %d existing users as verified", result.RowsAffected)
+	return nil
+}
+
+// AddArticleSearchVector adds the generated, weighted tsvector column used for article search
+// (title A, summary B, content C) and its GIN index. AutoMigrate cannot manage generated columns.
+func AddArticleSearchVector() error {
+	return Transaction(func(tx *gorm.DB) error {
+		// DDL cannot take bind parameters; the configuration name is a constant
+		config := "'" + models.ArticleSearchConfig + "'::regconfig"
+		if err := tx.Exec(fmt.Sprintf(`ALTER TABLE articles ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector(%[1]s, coalesce(title, '')), 'A') ||
+				setweight(to_tsvector(%[1]s, coalesce(summary, '')), 'B') ||
+				setweight(to_tsvector(%[1]s, coalesce(content, '')), 'C')
+			) STORED`, config)).Error; err != nil {
+			return err
+		}
+
+		return tx.Exec("CREATE INDEX IF NOT EXISTS idx_articles_search_vector ON articles USING GIN (search_vector)").Error
+	})
+}
+
+// AddArticleTrigramIndex indexes the text that article searches match as a substring with pg_trgm,
+// so searches in scripts without spaces, such as Thai, do not scan every article.
+// Without permission to create the extension, searches still work but scan the table.
+func AddArticleTrigramIndex() error {
+	if err := DB.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		log.Printf("Skipping article substring search index, pg_trgm is not available: %v", err)
+		return nil
+	}
+
+	// Index expressions name columns without the table
+	expression := strings.ReplaceAll(models.ArticleSearchText, "articles.", "")
+	return DB.Exec("CREATE INDEX IF NOT EXISTS idx_articles_search_trgm ON articles USING GIN ((" + expression + ") gin_trgm_ops)").Error
+}
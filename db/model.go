@@ -19,6 +19,27 @@ func RegisterAllModels() {
 		&models.RefreshToken{},
 		&models.Device{},
 		&models.Article{},
+		&models.TwoFactor{},
+		&models.RecoveryCode{},
+		&models.TwoFactorChallenge{},
+		&models.Setting{},
+		&models.PasswordResetToken{},
+		&models.LoginAttempt{},
+		&models.Permission{},
+		&models.Role{},
+		&models.RoleAssignment{},
+		&models.OIDCState{},
+		&models.UserIdentity{},
+		&models.PersonalAccessToken{},
+		&models.OAuthClient{},
+		&models.DeviceCertificate{},
+		&models.DeviceRequestNonce{},
+		&models.DeviceAuthorization{},
+		&models.Impersonation{},
+		&models.MagicLinkToken{},
+		&models.ArticleRevision{},
+		&models.Tag{},
+		&models.Category{},
 		// เพิ่มโมเดลใหม่ตรงนี้:
 		// &models.Product{},
 		// &models.Category{},
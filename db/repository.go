@@ -145,6 +145,8 @@ func sanitizePreloads(preloads []string) ([]string, error) {
 		"Device":        true,
 		"Articles":      true,
 		"RefreshTokens": true,
+		"Tags":          true,
+		"Categories":    true,
 	}
 
 	var cleanPreloads []string
@@ -3,16 +3,23 @@ package db
 import (
 	"dashboard-starter/models"
 	"dashboard-starter/utils"
+	"errors"
 	"time"
 
 	"log"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // SeedAdmin creates a default admin user if none exists
 func SeedAdmin() error {
+	// Roles must exist before the first admin can be given one
+	if err := SeedRoles(); err != nil {
+		return err
+	}
+
 	// Check if any admin exists
 	var count int64
 	if err := DB.Model(&models.Admin{}).Count(&count).Error; err != nil {
@@ -50,7 +57,7 @@ func SeedAdmin() error {
 
 			// Store the new admin's ID for use with test users
 			adminID = admin.ID
-			return nil
+			return assignRole(tx, models.RoleSuperAdmin, admin.ID, "admin")
 		})
 
 		if err != nil {
@@ -74,6 +81,11 @@ func SeedAdmin() error {
 		}
 	}
 
+	// Admins that existed before roles were introduced keep full access
+	if err := ensureSuperAdmin(); err != nil {
+		return err
+	}
+
 	// After creating/verifying admin, seed test data
 	err := SeedTestData(adminID)
 	if err != nil {
@@ -84,6 +96,95 @@ func SeedAdmin() error {
 	return SeedTestUsers()
 }
 
+// SeedRoles creates the permission catalog and the built-in roles, and grants existing
+// built-in roles any of their default permissions they are missing
+func SeedRoles() error {
+	return Transaction(func(tx *gorm.DB) error {
+		for _, permission := range models.PermissionCatalog {
+			permission := permission
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&permission).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, systemRole := range models.SystemRoles {
+			var permissions []models.Permission
+			if err := tx.Where("name IN ?", systemRole.Permissions).Find(&permissions).Error; err != nil {
+				return err
+			}
+
+			var role models.Role
+			err := tx.Where("name = ?", systemRole.Role.Name).First(&role).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				role = systemRole.Role
+				role.Permissions = permissions
+				if err := tx.Create(&role).Error; err != nil {
+					return err
+				}
+				log.Printf("Created role: %s", role.Name)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			// Existing installs get the permissions added since the role was created;
+			// permissions granted on top of the defaults are kept
+			before := tx.Model(&role).Association("Permissions").Count()
+			if err := tx.Model(&role).Association("Permissions").Append(permissions); err != nil {
+				return err
+			}
+			if added := tx.Model(&role).Association("Permissions").Count() - before; added > 0 {
+				log.Printf("Added %d permissions to role: %s", added, role.Name)
+			}
+		}
+
+		return nil
+	})
+}
+
+// ensureSuperAdmin gives every admin the super_admin role when no admin has a role yet
+func ensureSuperAdmin() error {
+	var assigned int64
+	if err := DB.Model(&models.RoleAssignment{}).Where("user_type = ?", "admin").Count(&assigned).Error; err != nil {
+		return err
+	}
+	if assigned > 0 {
+		return nil
+	}
+
+	var adminIDs []uint
+	if err := DB.Model(&models.Admin{}).Pluck("id", &adminIDs).Error; err != nil {
+		return err
+	}
+
+	return Transaction(func(tx *gorm.DB) error {
+		for _, id := range adminIDs {
+			if err := assignRole(tx, models.RoleSuperAdmin, id, "admin"); err != nil {
+				return err
+			}
+		}
+		if len(adminIDs) > 0 {
+			log.Printf("Assigned the %s role to %d existing admins", models.RoleSuperAdmin, len(adminIDs))
+		}
+		return nil
+	})
+}
+
+// assignRole assigns a role by name, ignoring assignments that already exist
+func assignRole(tx *gorm.DB, roleName string, userID uint, userType string) error {
+	var role models.Role
+	if err := tx.Where("name = ?", roleName).First(&role).Error; err != nil {
+		return err
+	}
+
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RoleAssignment{
+		RoleID:   role.ID,
+		UserID:   userID,
+		UserType: userType,
+	}).Error
+}
+
 // SeedTestData seeds the database with test data (for development only)
 func SeedTestData(adminID uint) error {
 	// Create test users with AdminID (admin-created users)
@@ -0,0 +1,135 @@
+// Package testca issues throwaway certificates for TLS and mTLS tests
+package testca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"dashboard-starter/config"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// CA is a certificate authority that lives for a single test
+type CA struct {
+	t    testing.TB
+	Cert *x509.Certificate
+	Key  *ecdsa.PrivateKey
+}
+
+// New creates a self-signed CA valid for one hour
+func New(t testing.TB, name string) *CA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	return &CA{t: t, Cert: cert, Key: key}
+}
+
+// Issue signs a certificate from template, filling in the key, serial and a validity of one hour
+// unless the template sets its own
+func (ca *CA) Issue(template *x509.Certificate) tls.Certificate {
+	ca.t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		ca.t.Fatalf("GenerateKey: %v", err)
+	}
+	template.SerialNumber, _ = rand.Int(rand.Reader, big.NewInt(1<<62))
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(time.Hour)
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, &key.PublicKey, ca.Key)
+	if err != nil {
+		ca.t.Fatalf("CreateCertificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		ca.t.Fatalf("ParseCertificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// Leaf issues a certificate naming commonName in its subject only, with the given extended key usage
+func (ca *CA) Leaf(commonName string, usage x509.ExtKeyUsage) tls.Certificate {
+	ca.t.Helper()
+
+	return ca.Issue(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: commonName},
+		ExtKeyUsage: []x509.ExtKeyUsage{usage},
+	})
+}
+
+// CertificatePEM encodes a certificate as a PEM block
+func CertificatePEM(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
+
+// UseServerTLS points the SERVER_TLS_* settings at a localhost certificate issued by ca and, if clientCA
+// is set, a client CA bundle containing it. The previous settings are restored when the test ends.
+func UseServerTLS(t testing.TB, ca, clientCA *CA) {
+	t.Helper()
+
+	dir := t.TempDir()
+	server := ca.Issue(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "localhost"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	keyDER, err := x509.MarshalECPrivateKey(server.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %v", err)
+	}
+
+	previous := config.Config.Server
+	t.Cleanup(func() { config.Config.Server = previous })
+
+	config.Config.Server.TLSCertFile = writePEM(t, dir, "server.pem", "CERTIFICATE", server.Certificate[0])
+	config.Config.Server.TLSKeyFile = writePEM(t, dir, "server-key.pem", "EC PRIVATE KEY", keyDER)
+	config.Config.Server.TLSClientCAFile = ""
+	if clientCA != nil {
+		config.Config.Server.TLSClientCAFile = writePEM(t, dir, "ca.pem", "CERTIFICATE", clientCA.Cert.Raw)
+	}
+}
+
+// writePEM writes a PEM block to dir/name and returns its path
+func writePEM(t testing.TB, dir, name, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
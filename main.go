@@ -5,6 +5,7 @@ import (
 	"dashboard-starter/config"
 	"dashboard-starter/db"
 	"dashboard-starter/routes"
+	"dashboard-starter/services"
 	"dashboard-starter/utils"
 	"fmt"
 	"log"
@@ -39,6 +40,11 @@ func main() {
 
 	utils.InitPasswordConfig(config.Config.Security.MinPasswordLength)
 
+	// Initialize mailer
+	if err := utils.InitMailer(); err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
 	// Initialize JWT
 	if err := utils.InitJWT(); err != nil {
 		log.Fatalf("Failed to initialize JWT: %v", err)
@@ -58,6 +64,17 @@ func main() {
 		log.Fatalf("Failed to seed admin user: %v", err)
 	}
 
+	// Publish and archive scheduled articles in the background until shutdown
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	services.StartArticleScheduler(schedulerCtx, time.Duration(config.Config.App.ArticleSchedulerSeconds)*time.Second)
+
+	// Load TLS settings (nil when serving plain HTTP)
+	tlsConfig, err := utils.InitTLS()
+	if err != nil {
+		log.Fatalf("Failed to initialize TLS: %v", err)
+	}
+
 	// Setup HTTP router
 	router := routes.SetupRouter()
 
@@ -68,12 +85,21 @@ func main() {
 		ReadTimeout:  config.Config.Server.ReadTimeout,
 		WriteTimeout: config.Config.Server.WriteTimeout,
 		IdleTimeout:  120 * time.Second,
+		TLSConfig:    tlsConfig,
 	}
 
 	// Start server in a goroutine
 	go func() {
 		log.Printf("Server starting on port %s", config.Config.Server.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+
+		var err error
+		if tlsConfig != nil {
+			// Certificates are already loaded into TLSConfig
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
@@ -83,6 +109,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutting down server...")
+	stopScheduler()
 
 	// Create context with timeout for shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
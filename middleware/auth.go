@@ -2,9 +2,10 @@ package middleware
 
 import (
 	"dashboard-starter/config"
-	"dashboard-starter/db"
 	"dashboard-starter/models"
+	"dashboard-starter/services"
 	"dashboard-starter/utils"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
@@ -82,22 +83,27 @@ func cleanupIPLimiters() {
 }
 
 func getIPLimiter(ip string) *IPLimiter {
+	requestsPerMinute := config.Config.RateLimit.RequestsPerMinute
+	if requestsPerMinute <= 0 {
+		requestsPerMinute = 60 // Default value
+	}
+	return getKeyedLimiter(ip, requestsPerMinute)
+}
+
+// getKeyedLimiter returns the limiter for a key, creating it with the given rate on first use
+func getKeyedLimiter(key string, requestsPerMinute int) *IPLimiter {
 	limitersMutex.Lock()
 	defer limitersMutex.Unlock()
 
-	ipLimiter, exists := ipLimiters[ip]
+	ipLimiter, exists := ipLimiters[key]
 	if !exists {
 		// Create new rate limiter
-		requestsPerMinute := config.Config.RateLimit.RequestsPerMinute
-		if requestsPerMinute <= 0 {
-			requestsPerMinute = 60 // Default value
-		}
 		limiter := rate.NewLimiter(rate.Limit(requestsPerMinute)/60, requestsPerMinute)
 		ipLimiter = &IPLimiter{
 			limiter:    limiter,
 			lastAccess: time.Now(),
 		}
-		ipLimiters[ip] = ipLimiter
+		ipLimiters[key] = ipLimiter
 	} else {
 		ipLimiter.lastAccess = time.Now()
 	}
@@ -123,8 +129,14 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Extract token from header
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 
+		// Personal access tokens are opaque and looked up in the database instead of parsed
+		if strings.HasPrefix(token, models.PersonalAccessTokenPrefix) {
+			authenticatePersonalAccessToken(c, token)
+			return
+		}
+
 		// Parse and validate token
-		userID, userType, tokenVer, err := utils.ParseToken(token)
+		claims, err := utils.ParseAccessToken(token)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"success": false,
@@ -133,79 +145,41 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Verify token version based on user type
-		if userType == "admin" {
-			var admin models.Admin
-			if err := db.DB.First(&admin, userID).Error; err != nil {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-					"success": false,
-					"error":   "Admin account not found",
-				})
-				return
-			}
-
-			// Verify token version matches the one in database
-			if tokenVer != admin.TokenVersion {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-					"success": false,
-					"error":   "Token has been revoked. Please login again",
-				})
-				return
-			}
-		} else if userType == "user" {
-			var user models.User
-			if err := db.DB.First(&user, userID).Error; err != nil {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-					"success": false,
-					"error":   "User account not found",
-				})
-				return
-			}
-
-			// Verify token version matches the one in database
-			if tokenVer != user.TokenVersion {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-					"success": false,
-					"error":   "Token has been revoked. Please login again",
-				})
-				return
-			}
-		} else if userType == "device" {
-			var device models.Device
-			if err := db.DB.First(&device, userID).Error; err != nil {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-					"success": false,
-					"error":   "Device not found",
-				})
-				return
-			}
+		userID, userType := claims.UserID, claims.UserType
 
-			// Verify token version matches the one in database
-			if tokenVer != device.TokenVersion {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-					"success": false,
-					"error":   "Token has been revoked. Please register device again",
-				})
-				return
-			}
-		} else {
-			// ถ้าไม่รู้จัก user type ให้ reject request
+		// Verify the principal and token version, the same checks token introspection applies
+		if err := services.CheckAccessToken(userID, userType, claims.TokenVersion, claims.SessionID); err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"success": false,
-				"error":   "Unknown user type",
+				"error":   accessTokenErrorMessage(userType, err),
 			})
 			return
 		}
 
+		// Devices that require signed requests must sign every request made with their token
+		if userType == "device" && !verifyDeviceSignature(c, userID) {
+			return
+		}
+
 		// Set user info in context for future handlers
 		c.Set("user_id", userID)
 		c.Set("user_type", userType)
+		if claims.SessionID != "" {
+			c.Set("session_id", claims.SessionID)
+		}
 
 		// For backward compatibility
 		if userType == "admin" {
 			c.Set("admin_id", userID)
 		}
 
+		// An admin impersonating a user: the request runs as the user, with the admin exposed as actor
+		if claims.Actor != nil {
+			if !setImpersonationContext(c, claims) {
+				return
+			}
+		}
+
 		c.Next()
 	}
 }
@@ -255,12 +229,34 @@ func RateLimitMiddleware() gin.HandlerFunc {
 	}
 }
 
+// RouteRateLimitMiddleware limits requests per IP to a single route with its own budget,
+// independent of the global RATE_LIMIT_PATHS limiter
+func RouteRateLimitMiddleware(name string, requestsPerMinute int) gin.HandlerFunc {
+	if requestsPerMinute <= 0 {
+		requestsPerMinute = 60 // Default value
+	}
+
+	return func(c *gin.Context) {
+		ipLimiter := getKeyedLimiter(name+"|"+c.ClientIP(), requestsPerMinute)
+
+		if !ipLimiter.limiter.Allow() {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"success": false,
+				"error":   "Rate limit exceeded. Please try again later",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
 // CORSMiddleware handles CORS headers
 func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Device-Label, X-Device-Timestamp, X-Device-Nonce, X-Device-Signature, If-None-Match")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -271,3 +267,29 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// accessTokenErrorMessage describes why CheckAccessToken rejected a token
+func accessTokenErrorMessage(userType string, err error) string {
+	switch {
+	case errors.Is(err, services.ErrUnknownUserType):
+		return "Unknown user type"
+	case errors.Is(err, services.ErrAccountDisabled):
+		return "Admin account is disabled"
+	case errors.Is(err, services.ErrSessionRevoked):
+		return "Session has been revoked. Please login again"
+	case errors.Is(err, services.ErrTokenVersionRevoked):
+		if userType == "device" {
+			return "Token has been revoked. Please register device again"
+		}
+		return "Token has been revoked. Please login again"
+	}
+
+	switch userType {
+	case "admin":
+		return "Admin account not found"
+	case "device":
+		return "Device not found"
+	default:
+		return "User account not found"
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"bytes"
+	"dashboard-starter/services"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSignedBodyBytes limits how much of a request body is read to verify a device signature
+const maxSignedBodyBytes = 10 << 20
+
+// verifyDeviceSignature checks the X-Device-* signature headers of a request made with a device token.
+// The body is only read, up to maxSignedBodyBytes, when the device must sign its requests, and is restored
+// for the handler. Returns false if the request was aborted.
+func verifyDeviceSignature(c *gin.Context, deviceID uint) bool {
+	device, err := services.GetDeviceSigning(deviceID)
+	if err != nil {
+		return abortDeviceSignature(c, err)
+	}
+	if !device.RequireSignature {
+		return true
+	}
+
+	var body []byte
+	if c.Request.Body != nil {
+		body, err = io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxSignedBodyBytes))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+				"success": false,
+				"error":   "Request body is too large",
+			})
+			return false
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	err = services.VerifyDeviceRequest(device, services.SignedDeviceRequest{
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.RequestURI(),
+		Timestamp: c.GetHeader("X-Device-Timestamp"),
+		Nonce:     c.GetHeader("X-Device-Nonce"),
+		Signature: c.GetHeader("X-Device-Signature"),
+		Body:      body,
+	})
+	if err != nil {
+		return abortDeviceSignature(c, err)
+	}
+	return true
+}
+
+// abortDeviceSignature writes the response for a failed signature check and returns false
+func abortDeviceSignature(c *gin.Context, err error) bool {
+	message := "Invalid request signature"
+	switch {
+	case errors.Is(err, services.ErrSignatureRequired), errors.Is(err, services.ErrInvalidSignature),
+		errors.Is(err, services.ErrSignatureExpired), errors.Is(err, services.ErrNonceReused):
+		message = err.Error()
+	case errors.Is(err, services.ErrPrincipalNotFound):
+		message = "Device not found"
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to verify request signature",
+		})
+		return false
+	}
+
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"success": false,
+		"error":   message,
+	})
+	return false
+}
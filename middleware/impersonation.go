@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"dashboard-starter/services"
+	"dashboard-starter/utils"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Context keys set on requests made with an impersonation token
+const (
+	actorIDKey         = "actor_id"         // ID of the admin acting as the user
+	actorTypeKey       = "actor_type"       // Always "admin"
+	impersonationIDKey = "impersonation_id" // Session ID (jti) of the impersonation token
+)
+
+// setImpersonationContext checks that the impersonation is still running and exposes the admin
+// next to the impersonated user. Every request is logged for the audit trail.
+// Returns false if the request was aborted.
+func setImpersonationContext(c *gin.Context, claims *utils.AccessTokenClaims) bool {
+	if err := services.CheckImpersonation(claims); err != nil {
+		if errors.Is(err, services.ErrImpersonationEnded) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"error":   "Impersonation session has ended",
+			})
+			return false
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to verify impersonation session",
+		})
+		return false
+	}
+
+	c.Set(actorIDKey, claims.Actor.UserID)
+	c.Set(actorTypeKey, claims.Actor.UserType)
+	c.Set(impersonationIDKey, claims.TokenID)
+
+	utils.Info("Admin %d as user %d: %s %s", claims.Actor.UserID, claims.UserID, c.Request.Method, c.Request.URL.Path)
+	return true
+}
+
+// RejectImpersonation blocks sensitive actions such as changing the password while an admin
+// is impersonating the user. Must run after AuthMiddleware.
+func RejectImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get(actorIDKey); exists {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "This action is not allowed while impersonating a user",
+			})
+			return
+		}
+		c.Next()
+	}
+}
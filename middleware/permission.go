@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"dashboard-starter/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// permissionsKey caches the principal's permissions in the request context
+const permissionsKey = "permissions"
+
+// RequirePermission allows the request only if the authenticated principal has the named permission
+// through one of its roles and, for personal access tokens, the token's scopes. Must run after AuthMiddleware.
+// Permissions are looked up per request, so role changes apply immediately without new tokens.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		permissions, err := loadPermissions(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to check permissions",
+			})
+			return
+		}
+
+		// A personal access token is further limited to its scopes
+		if scopes, exists := c.Get(tokenScopesKey); exists && !services.HasPermission(scopes.([]string), permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "Forbidden: token scopes do not include " + permission,
+			})
+			return
+		}
+
+		if !services.HasPermission(permissions, permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "Forbidden: missing permission " + permission,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// loadPermissions returns the permissions of the authenticated principal, loading them once per request
+func loadPermissions(c *gin.Context) ([]string, error) {
+	if cached, exists := c.Get(permissionsKey); exists {
+		return cached.([]string), nil
+	}
+
+	userID, _ := c.Get("user_id")
+	userType, _ := c.Get("user_type")
+	id, _ := userID.(uint)
+	typ, _ := userType.(string)
+
+	permissions, err := services.GetPermissions(id, typ)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Set(permissionsKey, permissions)
+	return permissions, nil
+}
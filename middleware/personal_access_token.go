@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"dashboard-starter/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokenScopesKey holds the scopes of the personal access token a request was authenticated with
+const tokenScopesKey = "token_scopes"
+
+// authenticatePersonalAccessToken authenticates the request with a personal access token
+// and sets the same context values as a JWT login
+func authenticatePersonalAccessToken(c *gin.Context, raw string) {
+	token, err := services.AuthenticatePersonalAccessToken(raw)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Invalid token: " + services.ErrInvalidPersonalToken.Error(),
+		})
+		return
+	}
+
+	// The owner must still exist and, for admins, be enabled
+	if err := services.CheckPrincipal(token.UserID, token.UserType); err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   accessTokenErrorMessage(token.UserType, err),
+		})
+		return
+	}
+
+	c.Set("user_id", token.UserID)
+	c.Set("user_type", token.UserType)
+	c.Set(tokenScopesKey, token.Scopes)
+	if token.UserType == "admin" {
+		c.Set("admin_id", token.UserID)
+	}
+
+	c.Next()
+}
+
+// RejectPersonalAccessTokens blocks requests authenticated with a personal access token.
+// Used for account security endpoints (passwords, 2FA, sessions and tokens) that need a real login.
+// Must run after AuthMiddleware.
+func RejectPersonalAccessTokens() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get(tokenScopesKey); exists {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "This endpoint cannot be used with a personal access token",
+			})
+			return
+		}
+		c.Next()
+	}
+}
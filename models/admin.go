@@ -8,17 +8,24 @@ import (
 
 // Admin represents the admin user model in the database
 type Admin struct {
-	gorm.Model             // Embeds ID, CreatedAt, UpdatedAt, DeletedAt
-	Email        string    `gorm:"size:255;not null;uniqueIndex" json:"email"`
-	Password     string    `gorm:"size:255;not null" json:"-"` // Exclude from JSON response
-	TokenVersion int       `gorm:"default:1" json:"-"`         // Exclude from JSON response
-	LastLogin    time.Time `json:"last_login"`
+	gorm.Model              // Embeds ID, CreatedAt, UpdatedAt, DeletedAt
+	Email        string     `gorm:"size:255;not null;uniqueIndex" json:"email"`
+	Password     string     `gorm:"size:255;not null" json:"-"` // Exclude from JSON response
+	TokenVersion int        `gorm:"default:1" json:"-"`         // Exclude from JSON response
+	LastLogin    time.Time  `json:"last_login"`
+	DisabledAt   *time.Time `json:"disabled_at"` // Disabled admins cannot login or use existing tokens
 }
 
 // AdminInput represents the input for creating or updating an admin
 type AdminInput struct {
 	Email    string `json:"email" binding:"required" validate:"required,email,max=255"`
 	Password string `json:"password" binding:"required" validate:"required,min=8,max=72"`
+	RoleIDs  []uint `json:"role_ids" validate:"omitempty,dive,min=1"` // Roles given to the new admin
+}
+
+// AdminUpdateInput represents the input for updating another admin's account
+type AdminUpdateInput struct {
+	Email string `json:"email" binding:"required" validate:"required,email,max=255"`
 }
 
 // ChangePasswordInput represents the input for changing admin password
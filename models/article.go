@@ -6,6 +6,23 @@ import (
 	"gorm.io/gorm"
 )
 
+// Article statuses. Scheduled articles are published by the article scheduler at PublishedAt.
+const (
+	ArticleStatusDraft     = "draft"
+	ArticleStatusScheduled = "scheduled"
+	ArticleStatusPublished = "published"
+	ArticleStatusArchived  = "archived"
+)
+
+// ArticleSearchConfig is the PostgreSQL text search configuration of the articles.search_vector
+// column. "simple" does not stem words, so English words match in any form they are typed.
+// The parser does not split Thai into words, so searches also match ArticleSearchText.
+const ArticleSearchConfig = "simple"
+
+// ArticleSearchText is the lower-cased text that article searches match as a substring, for scripts
+// written without spaces and for slugs. The idx_articles_search_trgm index is built on this expression.
+const ArticleSearchText = "lower(articles.title || ' ' || coalesce(articles.summary, '') || ' ' || articles.content || ' ' || articles.slug)"
+
 // Article represents a content article in the system
 type Article struct {
 	ID          uint           `json:"id" gorm:"primaryKey"`
@@ -13,13 +30,20 @@ type Article struct {
 	Content     string         `json:"content" gorm:"type:text;not null"`
 	Slug        string         `json:"slug" gorm:"size:255;not null;uniqueIndex"`
 	Summary     string         `json:"summary" gorm:"size:500"`
-	Status      string         `json:"status" gorm:"size:20;default:'draft'"` // draft, published, archived
-	PublishedAt *time.Time     `json:"published_at"`
+	Status      string         `json:"status" gorm:"size:20;default:'draft';index"` // draft, scheduled, published, archived
+	PublishedAt *time.Time     `json:"published_at" gorm:"index"`
+	UnpublishAt *time.Time     `json:"unpublish_at" gorm:"index"` // Archived by the scheduler at this time when set
 	AdminID     uint           `json:"admin_id" gorm:"not null"`
 	Admin       Admin          `json:"admin" gorm:"foreignKey:AdminID"`
+	Tags        []Tag          `json:"tags" gorm:"many2many:article_tags"`
+	Categories  []Category     `json:"categories" gorm:"many2many:article_categories"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Only filled in by full-text searches
+	SearchRank    float64 `json:"search_rank,omitempty" gorm:"->;-:migration"`
+	SearchSnippet string  `json:"search_snippet,omitempty" gorm:"->;-:migration"` // Escaped plain text, matches wrapped in <mark></mark>
 }
 
 // ArticleInput represents the input data for creating or updating an article
@@ -28,6 +52,59 @@ type ArticleInput struct {
 	Content     string `json:"content" binding:"required" validate:"required,min=10"`
 	Slug        string `json:"slug" binding:"required" validate:"required,min=3,max=255"`
 	Summary     string `json:"summary" validate:"max=500"`
-	Status      string `json:"status" validate:"oneof=draft published archived"`
-	PublishedAt string `json:"published_at"` // Optional, in ISO 8601 format
+	Status      string `json:"status" validate:"oneof=draft scheduled published archived"`
+	PublishedAt string `json:"published_at"`                                 // Optional, in ISO 8601 format; a future time schedules the article
+	UnpublishAt string `json:"unpublish_at"`                                 // Optional, in ISO 8601 format
+	TagIDs      []uint `json:"tag_ids" validate:"omitempty,dive,min=1"`      // Omit to keep the current tags, [] to remove them all
+	CategoryIDs []uint `json:"category_ids" validate:"omitempty,dive,min=1"` // Omit to keep the current categories, [] to remove them all
+}
+
+// ArticleFilter narrows article listings to a tag or a category subtree, both given by slug
+type ArticleFilter struct {
+	Tag      string `json:"tag" form:"tag"`
+	Category string `json:"category" form:"category"`
+}
+
+// PublishArticleInput optionally schedules publishing instead of publishing immediately
+type PublishArticleInput struct {
+	PublishedAt string `json:"published_at"` // Optional, in ISO 8601 format; defaults to now
+	UnpublishAt string `json:"unpublish_at"` // Optional, in ISO 8601 format
+}
+
+// PublicArticle is the article view served by the public API. It leaves out the author's
+// admin account and fields that only matter to the dashboard.
+type PublicArticle struct {
+	Title       string         `json:"title"`
+	Slug        string         `json:"slug"`
+	Summary     string         `json:"summary"`
+	Content     string         `json:"content,omitempty"` // Only included when a single article is requested
+	Tags        []TaxonomyTerm `json:"tags"`
+	Categories  []TaxonomyTerm `json:"categories"`
+	Snippet     string         `json:"search_snippet,omitempty"` // Only included in search results
+	PublishedAt *time.Time     `json:"published_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// PublicView converts the article to its public representation
+func (a *Article) PublicView(withContent bool) PublicArticle {
+	view := PublicArticle{
+		Title:       a.Title,
+		Slug:        a.Slug,
+		Summary:     a.Summary,
+		Tags:        make([]TaxonomyTerm, len(a.Tags)),
+		Categories:  make([]TaxonomyTerm, len(a.Categories)),
+		Snippet:     a.SearchSnippet,
+		PublishedAt: a.PublishedAt,
+		UpdatedAt:   a.UpdatedAt,
+	}
+	for i, tag := range a.Tags {
+		view.Tags[i] = TaxonomyTerm{Name: tag.Name, Slug: tag.Slug}
+	}
+	for i, category := range a.Categories {
+		view.Categories[i] = TaxonomyTerm{Name: category.Name, Slug: category.Slug}
+	}
+	if withContent {
+		view.Content = a.Content
+	}
+	return view
 }
@@ -0,0 +1,43 @@
+// models/article_revision.go
+package models
+
+import (
+	"time"
+)
+
+// ArticleRevision is a snapshot of an article's editable content, written whenever the article is
+// created, updated or restored. Revisions are numbered from 1 per article.
+type ArticleRevision struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	ArticleID    uint      `json:"article_id" gorm:"not null;uniqueIndex:idx_article_revision"`
+	Revision     int       `json:"revision" gorm:"not null;uniqueIndex:idx_article_revision"`
+	Title        string    `json:"title" gorm:"size:255;not null"`
+	Content      string    `json:"content" gorm:"type:text;not null"`
+	Slug         string    `json:"slug" gorm:"size:255;not null"`
+	Summary      string    `json:"summary" gorm:"size:500"`
+	AdminID      uint      `json:"admin_id" gorm:"not null"` // Admin who made the edit
+	Admin        Admin     `json:"admin" gorm:"foreignKey:AdminID"`
+	RestoredFrom *int      `json:"restored_from,omitempty"` // Revision number this one was restored from
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// DiffLine is one line of a line-based diff
+type DiffLine struct {
+	Op   string `json:"op"` // equal, insert or delete
+	Text string `json:"text"`
+}
+
+// FieldChange holds the old and new value of a single-line field that differs between revisions
+type FieldChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ArticleRevisionDiff compares two revisions of an article
+type ArticleRevisionDiff struct {
+	ArticleID uint                   `json:"article_id"`
+	From      int                    `json:"from"`
+	To        int                    `json:"to"`
+	Fields    map[string]FieldChange `json:"fields"` // Changed title, slug and summary
+	Content   []DiffLine             `json:"content"`
+}
@@ -0,0 +1,33 @@
+// models/category.go
+package models
+
+import (
+	"time"
+)
+
+// Category groups articles in a tree. Filtering by a category also matches articles in its
+// descendant categories.
+type Category struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Name        string     `json:"name" gorm:"size:100;not null"`
+	Slug        string     `json:"slug" gorm:"size:100;not null;uniqueIndex"`
+	Description string     `json:"description" gorm:"size:500"`
+	ParentID    *uint      `json:"parent_id" gorm:"index"`
+	Children    []Category `json:"children,omitempty" gorm:"foreignKey:ParentID"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// CategoryInput represents the input for creating or updating a category
+type CategoryInput struct {
+	Name        string `json:"name" binding:"required" validate:"required,min=1,max=100"`
+	Slug        string `json:"slug" validate:"max=100"` // Optional, generated from the name when empty
+	Description string `json:"description" validate:"max=500"`
+	ParentID    *uint  `json:"parent_id"` // Empty for a top-level category
+}
+
+// TaxonomyTerm is the public view of a tag or category
+type TaxonomyTerm struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
@@ -9,16 +9,37 @@ import (
 
 // Device represents an IoT device in the system
 type Device struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	DeviceID     string         `json:"device_id" gorm:"size:100;not null;uniqueIndex"`
-	Name         string         `json:"name" gorm:"size:255;not null"`
-	ApiKey       string         `json:"-" gorm:"size:255;not null"` // Secret, not exposed in JSON
-	TokenVersion int            `json:"-" gorm:"default:1"`         // For token invalidation
-	LastSeen     time.Time      `json:"last_seen"`
-	Status       string         `json:"status" gorm:"size:50;default:'inactive'"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ID           uint   `json:"id" gorm:"primaryKey"`
+	DeviceID     string `json:"device_id" gorm:"size:100;not null;uniqueIndex"`
+	Name         string `json:"name" gorm:"size:255;not null"`
+	ApiKey       string `json:"-" gorm:"size:255;not null;default:''"` // Legacy plaintext key, hashed and cleared on the next login
+	ApiKeyHash   string `json:"-" gorm:"size:255"`                     // Salted SHA-256 of the API key
+	ApiKeyPrefix string `json:"api_key_prefix" gorm:"size:16"`         // First characters of the key, to identify it
+	TokenVersion int    `json:"-" gorm:"default:1"`                    // For token invalidation
+
+	// Set while a key rotation is pending: the old key keeps working until PreviousKeyExpiresAt
+	PreviousApiKeyHash   string     `json:"-" gorm:"size:255"`
+	PreviousApiKeyPrefix string     `json:"previous_api_key_prefix,omitempty" gorm:"size:16"`
+	PreviousKeyExpiresAt *time.Time `json:"previous_key_expires_at,omitempty"`
+
+	// When RequireSignature is set every authenticated request must carry an HMAC made with SigningSecret
+	SigningSecret    string         `json:"-" gorm:"size:128"`
+	RequireSignature bool           `json:"require_signature" gorm:"not null;default:false"`
+	LastSeen         time.Time      `json:"last_seen"`
+	Status           string         `json:"status" gorm:"size:50;default:'inactive'"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// DeviceRequestNonce is a nonce of a signed device request, kept until the request's timestamp
+// is no longer accepted so the request cannot be replayed on any instance
+type DeviceRequestNonce struct {
+	ID        uint      `gorm:"primaryKey"`
+	DeviceID  uint      `gorm:"not null;uniqueIndex:idx_device_request_nonce"`
+	Nonce     string    `gorm:"size:128;not null;uniqueIndex:idx_device_request_nonce"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
 }
 
 // DeviceAuthInput represents device authentication request
@@ -26,3 +47,15 @@ type DeviceAuthInput struct {
 	DeviceID string `json:"device_id" binding:"required"`
 	ApiKey   string `json:"api_key" binding:"required"`
 }
+
+// RotateDeviceKeyInput starts an API key rotation; the old key stays valid for GraceHours
+type RotateDeviceKeyInput struct {
+	GraceHours int `json:"grace_hours" validate:"min=0,max=8760"` // 0 uses SECURITY_DEVICE_KEY_GRACE_HOURS
+}
+
+// DeviceKeyRotation tells a device that logged in with its old API key that the key is being replaced
+type DeviceKeyRotation struct {
+	Status          string    `json:"status"` // Always "pending"
+	NewKeyPrefix    string    `json:"new_key_prefix"`
+	OldKeyExpiresAt time.Time `json:"old_key_expires_at"`
+}
@@ -0,0 +1,46 @@
+// models/device_authorization.go
+package models
+
+import (
+	"time"
+)
+
+// Statuses of a device authorization request
+const (
+	DeviceAuthorizationPending  = "pending"
+	DeviceAuthorizationApproved = "approved"
+	DeviceAuthorizationDenied   = "denied"
+)
+
+// DeviceAuthorization is a pending RFC 8628 device authorization request.
+// A kiosk or TV polls with the device code while a signed-in user approves the user code.
+// The record is deleted once the client has received its tokens or the user denied it.
+type DeviceAuthorization struct {
+	ID             uint       `json:"-" gorm:"primaryKey"`
+	DeviceCodeHash string     `json:"-" gorm:"size:64;not null;uniqueIndex"` // SHA-256 of the device code
+	UserCode       string     `json:"user_code" gorm:"size:8;not null;uniqueIndex"`
+	ClientName     string     `json:"client_name" gorm:"size:100"` // Name the client gave itself, shown to the user
+	ClientID       string     `json:"-" gorm:"size:64"`            // Registered OAuth client that started the request, if any
+	IPAddress      string     `json:"ip_address" gorm:"size:45"`
+	Status         string     `json:"status" gorm:"size:20;not null;default:'pending'"`
+	UserID         *uint      `json:"-"`                 // User who entered the code, and approved it once Status is approved
+	Interval       int        `json:"-" gorm:"not null"` // Minimum seconds between polls, raised on slow_down
+	LastPolledAt   *time.Time `json:"-"`
+	ExpiresAt      time.Time  `json:"expires_at" gorm:"not null;index"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// DeviceAuthorizationResponse is the RFC 8628 section 3.2 device authorization response
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceCodeApprovalInput identifies the request a user approves or denies
+type DeviceCodeApprovalInput struct {
+	UserCode string `json:"user_code" binding:"required" validate:"required"`
+}
@@ -0,0 +1,26 @@
+// models/device_certificate.go
+package models
+
+import (
+	"time"
+)
+
+// DeviceCertificate is a client certificate pinned to a device by its SHA-256 fingerprint.
+// Only pinned, unrevoked certificates can be used with /auth/device/mtls.
+type DeviceCertificate struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	DeviceID    uint       `json:"device_id" gorm:"not null;index"`
+	Fingerprint string     `json:"fingerprint" gorm:"size:64;not null;uniqueIndex"` // Hex SHA-256 of the DER certificate
+	Subject     string     `json:"subject" gorm:"size:255"`
+	Issuer      string     `json:"issuer" gorm:"size:255"`
+	NotAfter    time.Time  `json:"not_after"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// DeviceCertificateInput pins a PEM encoded client certificate to a device
+type DeviceCertificateInput struct {
+	Certificate string `json:"certificate" binding:"required" validate:"required"`
+}
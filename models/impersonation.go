@@ -0,0 +1,34 @@
+// models/impersonation.go
+package models
+
+import (
+	"time"
+)
+
+// Impersonation records an admin signing in as a user. Rows are kept as an audit trail
+// even after the session ends or the user is deleted.
+type Impersonation struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	SessionID string     `json:"-" gorm:"size:64;not null;uniqueIndex"` // jti of the impersonation token
+	AdminID   uint       `json:"admin_id" gorm:"not null;index"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	Reason    string     `json:"reason" gorm:"size:255;not null"`
+	IPAddress string     `json:"ip_address" gorm:"size:45"`
+	UserAgent string     `json:"user_agent" gorm:"size:255"`
+	CreatedAt time.Time  `json:"started_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+	EndedBy   string     `json:"ended_by" gorm:"size:20"` // "impersonator" or "admin"; empty if the token expired
+	Status    string     `json:"status" gorm:"-"`         // "active", "ended" or "expired"
+}
+
+// ImpersonationInput starts an impersonation; the reason is kept in the audit trail
+type ImpersonationInput struct {
+	Reason string `json:"reason" binding:"required" validate:"required,min=3,max=255"`
+}
+
+// TokenActorInfo names the admin acting on behalf of the token's subject in token introspection
+type TokenActorInfo struct {
+	UserID   uint   `json:"user_id"`
+	UserType string `json:"user_type"`
+}
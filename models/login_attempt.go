@@ -0,0 +1,25 @@
+// models/login_attempt.go
+package models
+
+import (
+	"time"
+)
+
+// LoginAttempt counts consecutive failed logins for a login identifier (email or device ID) of a user type.
+// Counters are kept for identifiers without an account too, so lockouts do not reveal which accounts exist.
+type LoginAttempt struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserType     string     `json:"user_type" gorm:"size:50;not null;uniqueIndex:idx_login_attempt_identity"` // "admin", "user" or "device"
+	Identifier   string     `json:"identifier" gorm:"size:255;not null;uniqueIndex:idx_login_attempt_identity"`
+	FailedCount  int        `json:"failed_count" gorm:"not null;default:0"`
+	LastFailedAt *time.Time `json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// UnlockAccountInput identifies the login to unlock
+type UnlockAccountInput struct {
+	UserType   string `json:"user_type" binding:"required" validate:"required,oneof=admin user device"`
+	Identifier string `json:"identifier" binding:"required" validate:"required,max=255"`
+}
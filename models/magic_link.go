@@ -0,0 +1,27 @@
+// models/magic_link.go
+package models
+
+import (
+	"time"
+)
+
+// MagicLinkToken is a single-use passwordless login link emailed to a user.
+// Only the SHA-256 digest of the token is stored.
+type MagicLinkToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// MagicLinkRequestInput requests a login link by email
+type MagicLinkRequestInput struct {
+	Email string `json:"email" binding:"required" validate:"required,email,max=255"`
+}
+
+// MagicLinkLoginInput exchanges a login link token for the token pair
+type MagicLinkLoginInput struct {
+	Token string `json:"token" binding:"required" validate:"required,max=128"`
+}
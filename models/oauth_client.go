@@ -0,0 +1,39 @@
+// models/oauth_client.go
+package models
+
+import (
+	"time"
+)
+
+// OAuthClient is a service that authenticates with client credentials,
+// e.g. to introspect or revoke tokens issued by this API.
+// Only the SHA-256 digest of the secret is stored; the raw secret is shown once when the client is created.
+type OAuthClient struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	ClientID   string     `json:"client_id" gorm:"size:64;not null;uniqueIndex"`
+	Secret     string     `json:"client_secret,omitempty" gorm:"-"` // Raw secret, only populated when the client is created
+	SecretHash string     `json:"-" gorm:"size:64;not null"`
+	Name       string     `json:"name" gorm:"size:100;not null"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// OAuthClientInput represents the input for registering an OAuth client
+type OAuthClientInput struct {
+	Name string `json:"name" binding:"required" validate:"required,min=2,max=100"`
+}
+
+// TokenIntrospection is the RFC 7662 introspection response
+type TokenIntrospection struct {
+	Active    bool   `json:"active"`
+	TokenType string `json:"token_type,omitempty"` // "access_token", "refresh_token" or "personal_access_token"
+	Subject   string `json:"sub,omitempty"`
+	UserID    uint   `json:"user_id,omitempty"`
+	UserType  string `json:"user_type,omitempty"`
+	Scope     string `json:"scope,omitempty"` // Space separated scopes of a personal access token
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+
+	Act *TokenActorInfo `json:"act,omitempty"` // Set for impersonation tokens
+}
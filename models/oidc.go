@@ -0,0 +1,36 @@
+// models/oidc.go
+package models
+
+import (
+	"time"
+)
+
+// OIDCState is a pending authorization request started by GET /user/auth/oidc/:provider.
+// Only the SHA-256 digest of the state parameter is stored; the nonce and PKCE verifier
+// never leave the server.
+type OIDCState struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	StateHash    string    `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	Provider     string    `json:"provider" gorm:"size:50;not null"`
+	Nonce        string    `json:"-" gorm:"size:128;not null"`
+	CodeVerifier string    `json:"-" gorm:"size:128;not null"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// UserIdentity links a user to an account at an OpenID Connect provider
+type UserIdentity struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	Provider  string    `json:"provider" gorm:"size:50;not null;uniqueIndex:idx_user_identity_subject"`
+	Subject   string    `json:"subject" gorm:"size:255;not null;uniqueIndex:idx_user_identity_subject"`
+	Email     string    `json:"email" gorm:"size:255"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OIDCCallbackInput carries the authorization response the provider redirected back with
+type OIDCCallbackInput struct {
+	Code  string `json:"code" form:"code" binding:"required" validate:"required,max=2048"`
+	State string `json:"state" form:"state" binding:"required" validate:"required,max=128"`
+}
@@ -0,0 +1,29 @@
+// models/password_reset.go
+package models
+
+import (
+	"time"
+)
+
+// PasswordResetToken is a single-use token emailed to a user who forgot their password.
+// Only the SHA-256 digest of the token is stored.
+type PasswordResetToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ForgotPasswordInput requests a password reset email
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required" validate:"required,email,max=255"`
+}
+
+// ResetPasswordInput sets a new password with a reset token
+type ResetPasswordInput struct {
+	Token           string `json:"token" binding:"required" validate:"required,max=128"`
+	NewPassword     string `json:"new_password" binding:"required" validate:"required,min=8,max=72"`
+	ConfirmPassword string `json:"confirm_password" binding:"required" validate:"required,eqfield=NewPassword"`
+}
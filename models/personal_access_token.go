@@ -0,0 +1,34 @@
+// models/personal_access_token.go
+package models
+
+import (
+	"time"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token so it can be told apart from a JWT
+const PersonalAccessTokenPrefix = "pat_"
+
+// PersonalAccessToken is a long-lived credential for scripts and CI jobs.
+// It is limited to its scopes and to the permissions its owner currently has.
+// Only the SHA-256 digest of the token is stored; the raw token is shown once when it is created.
+type PersonalAccessToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Token      string     `json:"token,omitempty" gorm:"-"` // Raw token, only populated when the token is created
+	TokenHash  string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	Hint       string     `json:"hint" gorm:"size:16"` // Last characters of the token, to recognise it in listings
+	UserID     uint       `json:"-" gorm:"not null;index:idx_pat_owner"`
+	UserType   string     `json:"-" gorm:"size:50;not null;index:idx_pat_owner"` // "admin" or "user"
+	Name       string     `json:"name" gorm:"size:100;not null"`
+	Scopes     []string   `json:"scopes" gorm:"serializer:json"` // Permission names, e.g. "articles:read"
+	ExpiresAt  *time.Time `json:"expires_at"`                    // nil means the token never expires
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// PersonalAccessTokenInput represents the input for creating a personal access token
+type PersonalAccessTokenInput struct {
+	Name          string   `json:"name" binding:"required" validate:"required,min=1,max=100"`
+	Scopes        []string `json:"scopes" binding:"required" validate:"required,min=1,dive,required,max=100"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"min=0"` // 0 uses the longest lifetime allowed
+}
@@ -0,0 +1,102 @@
+// models/role.go
+package models
+
+import (
+	"time"
+)
+
+// Permission is a named action such as "articles:publish".
+// "*" grants every permission and "articles:*" every permission of a resource.
+type Permission struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"size:100;not null;uniqueIndex"`
+	Description string    `json:"description" gorm:"size:255"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Role groups permissions and is assigned to admins and users
+type Role struct {
+	ID          uint         `json:"id" gorm:"primaryKey"`
+	Name        string       `json:"name" gorm:"size:100;not null;uniqueIndex"`
+	Description string       `json:"description" gorm:"size:255"`
+	IsSystem    bool         `json:"is_system" gorm:"default:false"` // Built-in roles cannot be renamed or deleted
+	DefaultFor  string       `json:"default_for" gorm:"size:50"`     // User type that always has this role in addition to assigned roles
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions;"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// RoleAssignment links a role to an admin or user
+type RoleAssignment struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	RoleID    uint      `json:"role_id" gorm:"not null;uniqueIndex:idx_role_assignment"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_role_assignment;index:idx_role_assignment_principal"`
+	UserType  string    `json:"user_type" gorm:"size:50;not null;uniqueIndex:idx_role_assignment;index:idx_role_assignment_principal"` // "admin" or "user"
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Names of the built-in roles
+const (
+	RoleSuperAdmin = "super_admin"
+	RoleEditor     = "editor"
+	RoleUser       = "user"
+)
+
+// PermissionCatalog lists every permission checked by the application
+var PermissionCatalog = []Permission{
+	{Name: "*", Description: "Every permission"},
+	{Name: "dashboard:view", Description: "View the admin dashboard"},
+	{Name: "settings:read", Description: "View security settings"},
+	{Name: "settings:write", Description: "Change security settings"},
+	{Name: "security:lockouts", Description: "View and clear login lockouts"},
+	{Name: "roles:manage", Description: "Manage roles and role assignments"},
+	{Name: "oauth_clients:manage", Description: "Register and remove OAuth clients"},
+	{Name: "admins:read", Description: "View admin accounts"},
+	{Name: "admins:write", Description: "Create, update, disable and enable admin accounts"},
+	{Name: "admins:delete", Description: "Delete admin accounts"},
+	{Name: "users:read", Description: "View users and their sessions"},
+	{Name: "users:write", Description: "Create and update users, reset passwords and revoke sessions"},
+	{Name: "users:delete", Description: "Delete users"},
+	{Name: "users:impersonate", Description: "Sign in as a user and view the impersonation audit trail"},
+	{Name: "devices:read", Description: "View devices"},
+	{Name: "devices:write", Description: "Create and update devices and reset API keys"},
+	{Name: "devices:delete", Description: "Delete devices"},
+	{Name: "articles:read", Description: "View articles"},
+	{Name: "articles:write", Description: "Create and update articles"},
+	{Name: "articles:delete", Description: "Delete articles"},
+	{Name: "articles:publish", Description: "Publish articles"},
+	{Name: "profile:read", Description: "View own profile and dashboard"},
+	{Name: "profile:write", Description: "Update own profile"},
+}
+
+// SystemRoles are created on startup if they do not exist
+var SystemRoles = []struct {
+	Role        Role
+	Permissions []string
+}{
+	{
+		Role:        Role{Name: RoleSuperAdmin, Description: "Full access", IsSystem: true},
+		Permissions: []string{"*"},
+	},
+	{
+		Role:        Role{Name: RoleEditor, Description: "Manage and publish articles", IsSystem: true},
+		Permissions: []string{"dashboard:view", "articles:read", "articles:write", "articles:delete", "articles:publish"},
+	},
+	{
+		Role:        Role{Name: RoleUser, Description: "Regular user", IsSystem: true, DefaultFor: "user"},
+		Permissions: []string{"profile:read", "profile:write"},
+	},
+}
+
+// RoleInput represents the input for creating or updating a role
+type RoleInput struct {
+	Name        string   `json:"name" binding:"required" validate:"required,min=2,max=100"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"dive,max=100"`
+}
+
+// RoleAssignmentInput identifies the admin or user a role is assigned to
+type RoleAssignmentInput struct {
+	UserType string `json:"user_type" binding:"required" validate:"required,oneof=admin user"`
+	UserID   uint   `json:"user_id" binding:"required" validate:"required"`
+}
@@ -0,0 +1,13 @@
+// models/setting.go
+package models
+
+import (
+	"time"
+)
+
+// Setting is a key/value pair for settings that admins can change at runtime
+type Setting struct {
+	Key       string    `json:"key" gorm:"primaryKey;size:100"`
+	Value     string    `json:"value" gorm:"size:255;not null"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
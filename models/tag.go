@@ -0,0 +1,21 @@
+// models/tag.go
+package models
+
+import (
+	"time"
+)
+
+// Tag is a flat label that can be attached to any number of articles
+type Tag struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"size:100;not null"`
+	Slug      string    `json:"slug" gorm:"size:100;not null;uniqueIndex"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TagInput represents the input for creating or updating a tag
+type TagInput struct {
+	Name string `json:"name" binding:"required" validate:"required,min=1,max=100"`
+	Slug string `json:"slug" validate:"max=100"` // Optional, generated from the name when empty
+}
@@ -10,7 +10,11 @@ import (
 // RefreshToken represents a refresh token for authentication
 type RefreshToken struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
-	Token     string         `json:"token" gorm:"size:255;not null;uniqueIndex"`
+	Token     string         `json:"token" gorm:"-"`               // Raw token, only populated when the token is issued
+	TokenID   string         `json:"-" gorm:"size:64;uniqueIndex"` // Lookup identifier (JWT ID)
+	TokenHash string         `json:"-" gorm:"size:64;uniqueIndex"` // SHA-256 digest of the raw token
+	FamilyID  string         `json:"-" gorm:"size:64;index"`       // Shared by every token issued from the same login
+	ClientID  string         `json:"-" gorm:"size:64;index"`       // OAuth client the token was issued to, empty for first-party logins
 	UserID    uint           `json:"user_id" gorm:"not null"`
 	UserType  string         `json:"user_type" gorm:"size:50;not null"` // "admin", "user", "device", etc.
 	ExpiresAt time.Time      `json:"expires_at" gorm:"not null"`
@@ -18,4 +22,45 @@ type RefreshToken struct {
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Client details of the session, refreshed on every rotation
+	UserAgent   string     `json:"user_agent" gorm:"size:255"`
+	IPAddress   string     `json:"ip_address" gorm:"size:45"`
+	DeviceLabel string     `json:"device_label" gorm:"size:100"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+
+	// Why the token was revoked, so ending a session is not mistaken for token reuse
+	RevokedReason string `json:"-" gorm:"size:20"`
+}
+
+// Reasons a refresh token was revoked
+const (
+	RefreshTokenRotated = "rotated" // Replaced by a newer token of the same family
+	RefreshTokenRevoked = "revoked" // The session was ended by logout, its owner or an admin
+	RefreshTokenReused  = "reused"  // The family was revoked because a rotated token was presented again
+)
+
+// SessionClient describes the client a refresh token is issued to
+type SessionClient struct {
+	UserAgent   string
+	IPAddress   string
+	DeviceLabel string // Optional name chosen by the client, e.g. "Work laptop"
+	ClientID    string // Registered OAuth client the session is issued to, if any
+}
+
+// Session is an active login (a token family) as shown to its owner.
+// The ID is the family ID, which stays the same when the refresh token is rotated.
+type Session struct {
+	ID          string     `json:"id"`
+	UserAgent   string     `json:"user_agent"`
+	IPAddress   string     `json:"ip_address"`
+	DeviceLabel string     `json:"device_label"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+}
+
+// RevokeOtherSessionsInput identifies the session to keep by its refresh token
+type RevokeOtherSessionsInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required" validate:"required"`
 }
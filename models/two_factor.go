@@ -0,0 +1,64 @@
+// models/two_factor.go
+package models
+
+import (
+	"time"
+)
+
+// TwoFactor stores the TOTP secret of an admin or user.
+// A record without EnabledAt is an enrollment that has not been confirmed yet.
+type TwoFactor struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"not null;uniqueIndex:idx_two_factor_principal"`
+	UserType     string     `json:"user_type" gorm:"size:50;not null;uniqueIndex:idx_two_factor_principal"` // "admin" or "user"
+	Secret       string     `json:"-" gorm:"size:64;not null"`                                              // Base32 TOTP secret
+	EnabledAt    *time.Time `json:"enabled_at"`
+	LastUsedStep int64      `json:"-"` // Last accepted time step, prevents code replay
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// RecoveryCode is a one-time code that can replace a TOTP code, stored as a SHA-256 digest
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index:idx_recovery_code_principal"`
+	UserType  string     `json:"user_type" gorm:"size:50;not null;index:idx_recovery_code_principal"`
+	CodeHash  string     `json:"-" gorm:"size:64;not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TwoFactorChallenge counts the attempts made with a challenge token issued by the password step,
+// so the attempt limit holds across instances and restarts
+type TwoFactorChallenge struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	ChallengeID string     `json:"-" gorm:"size:64;not null;uniqueIndex"` // JWT ID of the challenge token
+	Attempts    int        `json:"attempts" gorm:"not null;default:0"`
+	ConsumedAt  *time.Time `json:"consumed_at"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null;index"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// TwoFactorVerifyInput completes a two-factor login with either a TOTP code or a recovery code
+type TwoFactorVerifyInput struct {
+	ChallengeToken string `json:"challenge_token" binding:"required" validate:"required"`
+	Code           string `json:"code" validate:"omitempty,len=6,numeric"`
+	RecoveryCode   string `json:"recovery_code" validate:"omitempty,max=32"`
+}
+
+// TwoFactorCodeInput carries a TOTP code for an authenticated principal
+type TwoFactorCodeInput struct {
+	Code string `json:"code" binding:"required" validate:"required,len=6,numeric"`
+}
+
+// TwoFactorDisableInput requires both the password and a current code to turn 2FA off
+type TwoFactorDisableInput struct {
+	Password string `json:"password" binding:"required" validate:"required"`
+	Code     string `json:"code" binding:"required" validate:"required,len=6,numeric"`
+}
+
+// TwoFactorPolicyInput makes 2FA mandatory or optional for a user type
+type TwoFactorPolicyInput struct {
+	UserType string `json:"user_type" binding:"required" validate:"required,oneof=admin user"`
+	Required *bool  `json:"required" binding:"required" validate:"required"`
+}
@@ -7,16 +7,20 @@ import (
 )
 
 type User struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	Name         string         `json:"name" gorm:"size:255;not null"`
-	Email        string         `json:"email" gorm:"size:255;not null;uniqueIndex"`
-	Password     string         `json:"-" gorm:"size:255;not null"` // Exclude from JSON response
-	TokenVersion int            `json:"-" gorm:"default:1"`         // For token invalidation
-	LastLogin    time.Time      `json:"last_login"`
-	AdminID      uint           `json:"admin_id"` // Can be optional, null if self-registered
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	Name         string    `json:"name" gorm:"size:255;not null"`
+	Email        string    `json:"email" gorm:"size:255;not null;uniqueIndex"`
+	Password     string    `json:"-" gorm:"size:255;not null"` // Exclude from JSON response
+	TokenVersion int       `json:"-" gorm:"default:1"`         // For token invalidation
+	LastLogin    time.Time `json:"last_login"`
+	AdminID      uint      `json:"admin_id"` // Can be optional, null if self-registered
+
+	EmailVerifiedAt    *time.Time `json:"email_verified_at"`
+	VerificationSentAt *time.Time `json:"-"` // Last verification email, used for the resend cooldown
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 // UserInput represents the input data for creating or updating a user
@@ -39,6 +43,16 @@ type UserLoginInput struct {
 	Password string `json:"password" binding:"required" validate:"required,min=6"`
 }
 
+// VerifyEmailInput carries the token from an email verification link
+type VerifyEmailInput struct {
+	Token string `json:"token" binding:"required" validate:"required"`
+}
+
+// ResendVerificationInput requests a new verification email
+type ResendVerificationInput struct {
+	Email string `json:"email" binding:"required" validate:"required,email,max=255"`
+}
+
 // ChangePasswordInput represents the input for changing user password
 type UserChangePasswordInput struct {
 	CurrentPassword string `json:"current_password" binding:"required" validate:"required"`
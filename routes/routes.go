@@ -32,6 +32,9 @@ func SetupRouter() *gin.Engine {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Public keys for verifying tokens issued by this service
+	r.GET("/.well-known/jwks.json", controllers.JWKS)
+
 	// API versioning
 	v1 := r.Group("/api/v1")
 
@@ -41,30 +44,128 @@ func SetupRouter() *gin.Engine {
 		auth.POST("/login", controllers.Login)
 		auth.POST("/refresh", controllers.RefreshToken)
 		auth.POST("/device", controllers.DeviceAuth)
+		auth.POST("/device/mtls", controllers.DeviceMTLSAuth)
+		auth.POST("/2fa/verify", controllers.VerifyTwoFactorLogin)
 
 		// Protected routes
 		protected := auth.Group("")
 		protected.Use(middleware.AuthMiddleware())
 		{
-			protected.POST("/logout", controllers.Logout)
 			protected.GET("/profile", controllers.GetProfile)
+
+			// Roles and permissions of the current principal
+			protected.GET("/permissions", controllers.GetMyPermissions)
+
+			// Account security requires the principal's own login, not a personal access token or an impersonating admin
+			account := protected.Group("")
+			account.Use(middleware.RejectPersonalAccessTokens(), middleware.RejectImpersonation())
+			{
+				account.POST("/logout", controllers.Logout)
+				account.POST("/change-password", middleware.AdminRequired(), controllers.ChangePassword)
+
+				// Two-factor authentication management
+				account.POST("/2fa/enroll", controllers.EnrollTwoFactor)
+				account.POST("/2fa/confirm", controllers.ConfirmTwoFactor)
+				account.POST("/2fa/disable", controllers.DisableTwoFactor)
+				account.POST("/2fa/recovery-codes", controllers.RegenerateRecoveryCodes)
+
+				// Active sessions
+				account.GET("/sessions", controllers.ListSessions)
+				account.DELETE("/sessions/:session_id", controllers.RevokeSession)
+				account.POST("/sessions/revoke-others", controllers.RevokeOtherSessions)
+
+				// Personal access tokens
+				account.GET("/tokens", controllers.ListPersonalAccessTokens)
+				account.POST("/tokens", controllers.CreatePersonalAccessToken)
+				account.DELETE("/tokens/:token_id", controllers.RevokePersonalAccessToken)
+			}
 		}
 	}
 
+	// OAuth endpoints for other services, authenticated with client credentials
+	oauth := v1.Group("/oauth")
+	{
+		oauth.POST("/introspect", controllers.IntrospectToken)
+		oauth.POST("/revoke", controllers.RevokeToken)
+
+		// RFC 8628 device authorization grant for kiosks and TVs
+		oauth.POST("/device_authorization",
+			middleware.RouteRateLimitMiddleware("device-authorization", config.Config.Security.DeviceCodePerMin),
+			controllers.StartDeviceAuthorization)
+		oauth.POST("/token", controllers.OAuthToken)
+	}
+
 	// User Auth routes - new endpoints for user registration and login
 	userAuth := v1.Group("/user/auth")
 	{
 		userAuth.POST("/register", controllers.UserRegister)
 		userAuth.POST("/login", controllers.UserLogin)
 		userAuth.POST("/refresh", controllers.RefreshToken) // Reuse the same token refresh endpoint
+		userAuth.POST("/2fa/verify", controllers.VerifyTwoFactorLogin)
+		userAuth.POST("/forgot-password",
+			middleware.RouteRateLimitMiddleware("forgot-password", config.Config.Security.PasswordResetPerMin),
+			controllers.ForgotPassword)
+		userAuth.POST("/reset-password", controllers.ResetPassword)
+		userAuth.POST("/verify-email", controllers.VerifyEmail)
+		userAuth.POST("/resend-verification",
+			middleware.RouteRateLimitMiddleware("resend-verification", config.Config.Security.VerificationResendPerMin),
+			controllers.ResendVerification)
+
+		// Passwordless login with an emailed link
+		userAuth.POST("/magic-link",
+			middleware.RouteRateLimitMiddleware("magic-link", config.Config.Security.MagicLinkPerMin),
+			controllers.RequestMagicLink)
+		userAuth.POST("/magic-link/verify", controllers.MagicLinkLogin)
+
+		// Sign in with an OpenID Connect provider configured in OIDC_PROVIDERS
+		userAuth.GET("/oidc/:provider", controllers.StartOIDCLogin)
+		userAuth.GET("/oidc/:provider/callback", controllers.OIDCCallback)
+		userAuth.POST("/oidc/:provider/callback", controllers.OIDCCallback)
 
 		// Protected routes for users
 		userProtected := userAuth.Group("")
 		userProtected.Use(middleware.AuthMiddleware(), middleware.UserRequired())
 		{
-			userProtected.POST("/logout", controllers.UserLogout)
 			userProtected.GET("/profile", controllers.GetUserProfile)
-			userProtected.POST("/change-password", controllers.ChangeUserPassword)
+
+			// Roles and permissions of the current user
+			userProtected.GET("/permissions", controllers.GetMyPermissions)
+
+			// End an impersonation from the impersonated session itself
+			userProtected.POST("/impersonation/stop", controllers.StopCurrentImpersonation)
+
+			// Account security requires the user's own login, not a personal access token or an impersonating admin
+			userAccount := userProtected.Group("")
+			userAccount.Use(middleware.RejectPersonalAccessTokens(), middleware.RejectImpersonation())
+			{
+				userAccount.POST("/logout", controllers.UserLogout)
+				userAccount.POST("/change-password", controllers.ChangeUserPassword)
+
+				// Two-factor authentication management
+				userAccount.POST("/2fa/enroll", controllers.EnrollTwoFactor)
+				userAccount.POST("/2fa/confirm", controllers.ConfirmTwoFactor)
+				userAccount.POST("/2fa/disable", controllers.DisableTwoFactor)
+				userAccount.POST("/2fa/recovery-codes", controllers.RegenerateRecoveryCodes)
+
+				// Active sessions
+				userAccount.GET("/sessions", controllers.ListSessions)
+				userAccount.DELETE("/sessions/:session_id", controllers.RevokeSession)
+				userAccount.POST("/sessions/revoke-others", controllers.RevokeOtherSessions)
+
+				// Personal access tokens
+				userAccount.GET("/tokens", controllers.ListPersonalAccessTokens)
+				userAccount.POST("/tokens", controllers.CreatePersonalAccessToken)
+				userAccount.DELETE("/tokens/:token_id", controllers.RevokePersonalAccessToken)
+
+				// Approve sign-in requests from kiosks and TVs (OAuth device authorization grant)
+				deviceCodes := userAccount.Group("/device")
+				deviceCodes.Use(middleware.RouteRateLimitMiddleware("device-verification", config.Config.Security.DeviceCodePerMin))
+				{
+					deviceCodes.GET("/:user_code", controllers.GetDeviceAuthorization)
+					deviceCodes.POST("/approve", controllers.ApproveDeviceAuthorization)
+					deviceCodes.POST("/deny", controllers.DenyDeviceAuthorization)
+				}
+			}
 		}
 	}
 
@@ -73,7 +174,7 @@ func SetupRouter() *gin.Engine {
 	user.Use(middleware.AuthMiddleware(), middleware.UserRequired())
 	{
 		// User profile and dashboard
-		user.GET("/dashboard", func(c *gin.Context) {
+		user.GET("/dashboard", middleware.RequirePermission("profile:read"), func(c *gin.Context) {
 			userID, _ := c.Get("user_id")
 			c.JSON(200, gin.H{
 				"success": true,
@@ -85,15 +186,16 @@ func SetupRouter() *gin.Engine {
 		})
 
 		// Update own profile
-		user.PUT("/profile", controllers.UpdateUserProfile)
+		user.PUT("/profile", middleware.RejectImpersonation(), middleware.RequirePermission("profile:write"), controllers.UpdateUserProfile)
 	}
 
 	// Admin dashboard routes
-	// First use AuthMiddleware to verify token, then AdminRequired to ensure user is admin
+	// AuthMiddleware verifies the token, AdminRequired ensures the principal is an admin,
+	// and RequirePermission checks the admin's roles for each route
 	admin := v1.Group("/admin")
 	admin.Use(middleware.AuthMiddleware(), middleware.AdminRequired())
 	{
-		admin.GET("/dashboard", func(c *gin.Context) {
+		admin.GET("/dashboard", middleware.RequirePermission("dashboard:view"), func(c *gin.Context) {
 			adminID, _ := c.Get("admin_id")
 			c.JSON(200, gin.H{
 				"success": true,
@@ -104,53 +206,138 @@ func SetupRouter() *gin.Engine {
 			})
 		})
 
+		// Security settings
+		settings := admin.Group("/settings")
+		{
+			settings.GET("/two-factor", middleware.RequirePermission("settings:read"), controllers.GetTwoFactorPolicy)
+			settings.PUT("/two-factor", middleware.RequirePermission("settings:write"), controllers.SetTwoFactorPolicy)
+		}
+
+		// Failed login lockouts
+		lockouts := admin.Group("/lockouts")
+		lockouts.Use(middleware.RequirePermission("security:lockouts"))
+		{
+			lockouts.GET("", controllers.ListLoginLockouts)
+			lockouts.POST("/unlock", controllers.UnlockLogin)
+		}
+
+		// Roles and permissions
+		admin.GET("/permissions", middleware.RequirePermission("roles:manage"), controllers.ListPermissions)
+		roles := admin.Group("/roles")
+		roles.Use(middleware.RequirePermission("roles:manage"))
+		{
+			roles.GET("", controllers.ListRoles)
+			roles.POST("", controllers.CreateRole)
+			roles.GET("/:id", controllers.GetRole)
+			roles.PUT("/:id", controllers.UpdateRole)
+			roles.DELETE("/:id", controllers.DeleteRole)
+			roles.GET("/:id/members", controllers.ListRoleMembers)
+			roles.POST("/:id/members", controllers.AssignRole)
+			roles.DELETE("/:id/members/:user_type/:user_id", controllers.RemoveRole)
+		}
+
+		// OAuth clients allowed to call /oauth/introspect and /oauth/revoke
+		oauthClients := admin.Group("/oauth-clients")
+		oauthClients.Use(middleware.RequirePermission("oauth_clients:manage"))
+		{
+			oauthClients.GET("", controllers.ListOAuthClients)
+			oauthClients.POST("", controllers.CreateOAuthClient)
+			oauthClients.DELETE("/:id", controllers.DeleteOAuthClient)
+		}
+
+		// Admin account management
+		admins := admin.Group("/admins")
+		{
+			admins.GET("", middleware.RequirePermission("admins:read"), controllers.ListAdmins)
+			admins.POST("", middleware.RequirePermission("admins:write"), controllers.CreateAdmin)
+			admins.GET("/:id", middleware.RequirePermission("admins:read"), controllers.GetAdmin)
+			admins.PUT("/:id", middleware.RequirePermission("admins:write"), controllers.UpdateAdmin)
+			admins.DELETE("/:id", middleware.RequirePermission("admins:delete"), controllers.DeleteAdmin)
+			admins.POST("/:id/disable", middleware.RequirePermission("admins:write"), controllers.DisableAdmin)
+			admins.POST("/:id/enable", middleware.RequirePermission("admins:write"), controllers.EnableAdmin)
+		}
+
 		// user management routes
 		users := admin.Group("/users")
 		{
-			users.GET("", controllers.ListUsers)
-			users.POST("", controllers.CreateUser)
-			users.GET("/:id", controllers.GetUser)
-			users.PUT("/:id", controllers.UpdateUser)
-			users.DELETE("/:id", controllers.DeleteUser)
-			users.POST("/:id/reset-password", controllers.ResetUserPassword)
+			users.GET("", middleware.RequirePermission("users:read"), controllers.ListUsers)
+			users.POST("", middleware.RequirePermission("users:write"), controllers.CreateUser)
+			users.GET("/:id", middleware.RequirePermission("users:read"), controllers.GetUser)
+			users.PUT("/:id", middleware.RequirePermission("users:write"), controllers.UpdateUser)
+			users.DELETE("/:id", middleware.RequirePermission("users:delete"), controllers.DeleteUser)
+			users.POST("/:id/reset-password", middleware.RequirePermission("users:write"), controllers.ResetUserPassword)
+			users.GET("/:id/sessions", middleware.RequirePermission("users:read"), controllers.ListUserSessions)
+			users.DELETE("/:id/sessions", middleware.RequirePermission("users:write"), controllers.RevokeAllUserSessions)
+			users.DELETE("/:id/sessions/:session_id", middleware.RequirePermission("users:write"), controllers.RevokeUserSession)
+			users.POST("/:id/impersonate", middleware.RequirePermission("users:impersonate"), controllers.StartImpersonation)
+		}
+
+		// Impersonation audit trail
+		impersonations := admin.Group("/impersonations")
+		impersonations.Use(middleware.RequirePermission("users:impersonate"))
+		{
+			impersonations.GET("", controllers.ListImpersonations)
+			impersonations.DELETE("/:id", controllers.StopImpersonation)
 		}
 
 		// Device management
 		devices := admin.Group("/devices")
 		{
-			devices.POST("", controllers.CreateDevice)
-			devices.GET("", controllers.ListDevices)
-			devices.GET("/:id", controllers.GetDevice)
-			devices.PUT("/:id", controllers.UpdateDevice)
-			devices.DELETE("/:id", controllers.DeleteDevice)
-			devices.POST("/:id/reset-key", controllers.ResetDeviceApiKey)
+			devices.POST("", middleware.RequirePermission("devices:write"), controllers.CreateDevice)
+			devices.GET("", middleware.RequirePermission("devices:read"), controllers.ListDevices)
+			devices.GET("/:id", middleware.RequirePermission("devices:read"), controllers.GetDevice)
+			devices.PUT("/:id", middleware.RequirePermission("devices:write"), controllers.UpdateDevice)
+			devices.DELETE("/:id", middleware.RequirePermission("devices:delete"), controllers.DeleteDevice)
+			devices.POST("/:id/reset-key", middleware.RequirePermission("devices:write"), controllers.ResetDeviceApiKey)
+			devices.POST("/:id/rotate-key", middleware.RequirePermission("devices:write"), controllers.RotateDeviceApiKey)
+			devices.POST("/:id/rotate-key/complete", middleware.RequirePermission("devices:write"), controllers.CompleteDeviceKeyRotation)
+			devices.POST("/:id/rotate-key/cancel", middleware.RequirePermission("devices:write"), controllers.CancelDeviceKeyRotation)
+			devices.POST("/:id/signing-secret", middleware.RequirePermission("devices:write"), controllers.EnableDeviceRequestSigning)
+			devices.DELETE("/:id/signing-secret", middleware.RequirePermission("devices:write"), controllers.DisableDeviceRequestSigning)
+			devices.GET("/:id/certificates", middleware.RequirePermission("devices:read"), controllers.ListDeviceCertificates)
+			devices.POST("/:id/certificates", middleware.RequirePermission("devices:write"), controllers.PinDeviceCertificate)
+			devices.DELETE("/:id/certificates/:cert_id", middleware.RequirePermission("devices:write"), controllers.RevokeDeviceCertificate)
 		}
 
 		// Article management routes
 		articles := admin.Group("/articles")
 		{
-			articles.POST("", controllers.CreateArticle)
-			articles.GET("", controllers.ListArticles)
-			articles.GET("/:id", controllers.GetArticle)
-			articles.PUT("/:id", controllers.UpdateArticle)
-			articles.DELETE("/:id", controllers.DeleteArticle)
-			articles.POST("/:id/publish", controllers.PublishArticle)
+			articles.POST("", middleware.RequirePermission("articles:write"), controllers.CreateArticle)
+			articles.GET("", middleware.RequirePermission("articles:read"), controllers.ListArticles)
+			articles.GET("/:id", middleware.RequirePermission("articles:read"), controllers.GetArticle)
+			articles.PUT("/:id", middleware.RequirePermission("articles:write"), controllers.UpdateArticle)
+			articles.DELETE("/:id", middleware.RequirePermission("articles:delete"), controllers.DeleteArticle)
+			articles.POST("/:id/publish", middleware.RequirePermission("articles:publish"), controllers.PublishArticle)
+			articles.GET("/:id/revisions", middleware.RequirePermission("articles:read"), controllers.ListArticleRevisions)
+			articles.GET("/:id/revisions/diff", middleware.RequirePermission("articles:read"), controllers.DiffArticleRevisions)
+			articles.POST("/:id/revisions/:revision/restore", middleware.RequirePermission("articles:write"), controllers.RestoreArticleRevision)
+		}
+
+		// Article taxonomy routes
+		tags := admin.Group("/tags")
+		{
+			tags.GET("", middleware.RequirePermission("articles:read"), controllers.ListTags)
+			tags.POST("", middleware.RequirePermission("articles:write"), controllers.CreateTag)
+			tags.GET("/:id", middleware.RequirePermission("articles:read"), controllers.GetTag)
+			tags.PUT("/:id", middleware.RequirePermission("articles:write"), controllers.UpdateTag)
+			tags.DELETE("/:id", middleware.RequirePermission("articles:delete"), controllers.DeleteTag)
+		}
+
+		categories := admin.Group("/categories")
+		{
+			categories.GET("", middleware.RequirePermission("articles:read"), controllers.ListCategories)
+			categories.POST("", middleware.RequirePermission("articles:write"), controllers.CreateCategory)
+			categories.GET("/:id", middleware.RequirePermission("articles:read"), controllers.GetCategory)
+			categories.PUT("/:id", middleware.RequirePermission("articles:write"), controllers.UpdateCategory)
+			categories.DELETE("/:id", middleware.RequirePermission("articles:delete"), controllers.DeleteCategory)
 		}
 	}
 
 	// Public API endpoints - accessible without authentication
 	public := v1.Group("/public")
 	{
-		// Example: public article listing
-		public.GET("/articles", func(c *gin.Context) {
-			// Implement a controller for public articles
-			c.JSON(200, gin.H{
-				"success": true,
-				"data": gin.H{
-					"message": "Public articles will be listed here",
-				},
-			})
-		})
+		public.GET("/articles", controllers.ListPublicArticles)
+		public.GET("/articles/:slug", controllers.GetPublicArticle)
 	}
 
 	// ใช้เพื่อการ debug ให้แสดง registerd routes ทั้งหมด
@@ -0,0 +1,330 @@
+// services/admin_service.go
+package services
+
+import (
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"errors"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrLastActiveAdmin is returned when an action would leave no active admin with full access
+	ErrLastActiveAdmin = errors.New("at least one active admin must keep the super_admin role")
+	// ErrSelfAction is returned when an admin tries to disable or delete their own account
+	ErrSelfAction = errors.New("you cannot disable or delete your own account")
+	// ErrAdminEmailExists is returned when the email is used by another admin
+	ErrAdminEmailExists = errors.New("email already exists")
+	// ErrInvalidID is returned when a path ID is not a number
+	ErrInvalidID = errors.New("invalid ID format")
+	// ErrIncorrectPassword is returned when the current password does not match
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	// ErrRoleEscalation is returned when an admin assigns or edits a role granting more than they hold themselves
+	ErrRoleEscalation = errors.New("you cannot grant permissions you do not have")
+)
+
+// adminGuardLockKey serialises changes that could remove the last active super admin
+const adminGuardLockKey = 7420001
+
+type AdminService struct {
+	repo *db.GormRepository[models.Admin]
+}
+
+func NewAdminService() *AdminService {
+	return &AdminService{
+		repo: db.NewRepository[models.Admin](),
+	}
+}
+
+// GetByID retrieves an admin by ID
+func (s *AdminService) GetByID(id string) (*models.Admin, error) {
+	// Convert id to uint
+	idUint, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	return s.repo.FindByID(uint(idUint))
+}
+
+// GetAdmins retrieves admins with pagination and search
+func (s *AdminService) GetAdmins(params utils.PaginationParams) ([]models.Admin, *utils.PaginationResult, error) {
+	var admins []models.Admin
+
+	// Create query
+	query := db.DB.Model(&models.Admin{})
+
+	// Apply search if provided
+	if params.Search != "" {
+		query = utils.ApplySearch(query, params.Search, "email")
+	}
+
+	// Apply pagination
+	result, err := utils.ApplyPagination(query, params, &admins)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return admins, result, nil
+}
+
+// CreateAdmin creates a new admin account and assigns the given roles.
+// The acting admin may only assign roles whose permissions they hold, and only super admins may assign super_admin.
+func (s *AdminService) CreateAdmin(input *models.AdminInput, actorID uint) (*models.Admin, error) {
+	// Deleted admins keep their email in the unique index, so they count as duplicates too
+	var count int64
+	if err := db.DB.Unscoped().Model(&models.Admin{}).Where("email = ?", input.Email).Count(&count).Error; err != nil {
+		return nil, err
+	}
+
+	if count > 0 {
+		return nil, ErrAdminEmailExists
+	}
+
+	granted, err := GetPermissions(actorID, "admin")
+	if err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	admin := &models.Admin{
+		Email:        input.Email,
+		Password:     string(hashedPassword),
+		TokenVersion: 1,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(admin).Error; err != nil {
+			return err
+		}
+
+		for _, roleID := range input.RoleIDs {
+			var role models.Role
+			if err := tx.Preload("Permissions").First(&role, roleID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrRoleNotFound
+				}
+				return err
+			}
+
+			if err := ensureCanGrantRole(tx, &role, actorID, granted); err != nil {
+				return err
+			}
+
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RoleAssignment{
+				RoleID:   role.ID,
+				UserID:   admin.ID,
+				UserType: "admin",
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return admin, nil
+}
+
+// UpdateAdmin changes the email of an admin account
+func (s *AdminService) UpdateAdmin(id string, input *models.AdminUpdateInput) (*models.Admin, error) {
+	admin, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check for duplicate email (if changed)
+	if input.Email != admin.Email {
+		var count int64
+		err := db.DB.Unscoped().Model(&models.Admin{}).Where("email = ? AND id != ?", input.Email, admin.ID).Count(&count).Error
+		if err != nil {
+			return nil, err
+		}
+
+		if count > 0 {
+			return nil, ErrAdminEmailExists
+		}
+	}
+
+	admin.Email = input.Email
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return tx.Save(admin).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return admin, nil
+}
+
+// SetAdminDisabled disables or enables an admin account.
+// Disabling bumps the token version and revokes refresh tokens so the admin is signed out immediately.
+func (s *AdminService) SetAdminDisabled(id string, disabled bool, actorID uint) (*models.Admin, error) {
+	admin, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if disabled && admin.ID == actorID {
+		return nil, ErrSelfAction
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if disabled {
+			if err := ensureOtherActiveSuperAdmin(tx, admin.ID); err != nil {
+				return err
+			}
+
+			now := time.Now()
+			admin.DisabledAt = &now
+		} else {
+			admin.DisabledAt = nil
+		}
+
+		// Invalidate access tokens issued before the change
+		admin.TokenVersion += 1
+		if err := tx.Save(admin).Error; err != nil {
+			return err
+		}
+
+		if !disabled {
+			return nil
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND user_type = ? AND is_revoked = ?", admin.ID, "admin", false).
+			Updates(revokedColumns(models.RefreshTokenRevoked)).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return admin, nil
+}
+
+// DeleteAdmin deletes an admin account together with its refresh tokens, role assignments and personal access tokens
+func (s *AdminService) DeleteAdmin(id string, actorID uint) error {
+	admin, err := s.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	if admin.ID == actorID {
+		return ErrSelfAction
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := ensureOtherActiveSuperAdmin(tx, admin.ID); err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ? AND user_type = ?", admin.ID, "admin").Delete(&models.RefreshToken{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ? AND user_type = ?", admin.ID, "admin").Delete(&models.RoleAssignment{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ? AND user_type = ?", admin.ID, "admin").Delete(&models.PersonalAccessToken{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(admin).Error
+	})
+}
+
+// ChangeAdminPassword changes an admin's own password and signs out all of their sessions
+func (s *AdminService) ChangeAdminPassword(adminID uint, currentPassword, newPassword string) error {
+	admin, err := s.repo.FindByID(adminID)
+	if err != nil {
+		return err
+	}
+
+	// Verify current password
+	if err := bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(currentPassword)); err != nil {
+		return ErrIncorrectPassword
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		admin.Password = string(hashedPassword)
+		admin.TokenVersion += 1 // Invalidate all tokens
+		if err := tx.Save(admin).Error; err != nil {
+			return err
+		}
+		return revokeAllPersonalAccessTokens(tx, admin.ID, "admin")
+	})
+	if err != nil {
+		return err
+	}
+
+	// Sessions started with the old password must not survive the change
+	return RevokeAllRefreshTokens(admin.ID, "admin")
+}
+
+// ensureOtherActiveSuperAdmin returns ErrLastActiveAdmin unless an active admin other than adminID
+// holds the super_admin role. It takes a transaction-scoped lock so concurrent requests cannot
+// each remove a different one of the last two admins.
+func ensureOtherActiveSuperAdmin(tx *gorm.DB, adminID uint) error {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", adminGuardLockKey).Error; err != nil {
+		return err
+	}
+
+	var count int64
+	err := tx.Model(&models.Admin{}).
+		Joins("JOIN role_assignments ON role_assignments.user_id = admins.id AND role_assignments.user_type = ?", "admin").
+		Joins("JOIN roles ON roles.id = role_assignments.role_id").
+		Where("roles.name = ? AND admins.disabled_at IS NULL AND admins.id != ?", models.RoleSuperAdmin, adminID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrLastActiveAdmin
+	}
+	return nil
+}
+
+// ensureCanGrantRole returns ErrRoleEscalation unless the acting admin holds every permission of the role.
+// super_admin can only be granted by another super admin.
+func ensureCanGrantRole(tx *gorm.DB, role *models.Role, actorID uint, granted []string) error {
+	if role.Name == models.RoleSuperAdmin {
+		var count int64
+		if err := tx.Model(&models.RoleAssignment{}).
+			Where("role_id = ? AND user_id = ? AND user_type = ?", role.ID, actorID, "admin").
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrRoleEscalation
+		}
+	}
+
+	for _, permission := range role.Permissions {
+		if !HasPermission(granted, permission.Name) {
+			return ErrRoleEscalation
+		}
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+// services/article_revision_service.go
+package services
+
+import (
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"errors"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrArticleRevisionNotFound is returned when an article has no revision with the requested number
+var ErrArticleRevisionNotFound = errors.New("article revision not found")
+
+// maxDiffRounds bounds the search for a shortest edit script in each part of a diff. Parts of two
+// revisions that differ more than that are shown as deleted and re-inserted, so diffing very
+// different revisions cannot tie up the server.
+const maxDiffRounds = 1000
+
+// writeArticleRevision snapshots the article's current content as its next revision. It must run in
+// the transaction that saved the article, whose row lock keeps revision numbers in order.
+func writeArticleRevision(tx *gorm.DB, article *models.Article, adminID uint, restoredFrom *int) error {
+	var latest int
+	if err := tx.Model(&models.ArticleRevision{}).
+		Where("article_id = ?", article.ID).
+		Select("COALESCE(MAX(revision), 0)").
+		Scan(&latest).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&models.ArticleRevision{
+		ArticleID:    article.ID,
+		Revision:     latest + 1,
+		Title:        article.Title,
+		Content:      article.Content,
+		Slug:         article.Slug,
+		Summary:      article.Summary,
+		AdminID:      adminID,
+		RestoredFrom: restoredFrom,
+	}).Error
+}
+
+// ensureBaselineRevision records the stored content of an article written before revisions were
+// kept, so that its first update does not lose it
+func ensureBaselineRevision(tx *gorm.DB, article *models.Article) error {
+	var count int64
+	if err := tx.Model(&models.ArticleRevision{}).Where("article_id = ?", article.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	return tx.Create(&models.ArticleRevision{
+		ArticleID: article.ID,
+		Revision:  1,
+		Title:     article.Title,
+		Content:   article.Content,
+		Slug:      article.Slug,
+		Summary:   article.Summary,
+		AdminID:   article.AdminID,
+		CreatedAt: article.UpdatedAt,
+	}).Error
+}
+
+// ListArticleRevisions retrieves the revisions of an article with pagination, newest first
+func (s *ArticleService) ListArticleRevisions(id string, params utils.PaginationParams) ([]models.ArticleRevision, *utils.PaginationResult, error) {
+	article, err := s.findArticle(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var revisions []models.ArticleRevision
+	params.Preloads = []string{"Admin"}
+	query := db.DB.Model(&models.ArticleRevision{}).Where("article_id = ?", article.ID)
+
+	result, err := utils.ApplyPagination(query, params, &revisions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return revisions, result, nil
+}
+
+// DiffArticleRevisions compares two revisions of an article line by line
+func (s *ArticleService) DiffArticleRevisions(id string, from, to int) (*models.ArticleRevisionDiff, error) {
+	article, err := s.findArticle(id)
+	if err != nil {
+		return nil, err
+	}
+
+	older, err := findArticleRevision(db.DB, article.ID, from)
+	if err != nil {
+		return nil, err
+	}
+	newer, err := findArticleRevision(db.DB, article.ID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]models.FieldChange{}
+	if older.Title != newer.Title {
+		fields["title"] = models.FieldChange{From: older.Title, To: newer.Title}
+	}
+	if older.Slug != newer.Slug {
+		fields["slug"] = models.FieldChange{From: older.Slug, To: newer.Slug}
+	}
+	if older.Summary != newer.Summary {
+		fields["summary"] = models.FieldChange{From: older.Summary, To: newer.Summary}
+	}
+
+	return &models.ArticleRevisionDiff{
+		ArticleID: article.ID,
+		From:      from,
+		To:        to,
+		Fields:    fields,
+		Content:   diffLines(splitLines(older.Content), splitLines(newer.Content)),
+	}, nil
+}
+
+// RestoreArticleRevision copies an old revision back into the article and records it as a new
+// revision, so the history itself is never rewritten
+func (s *ArticleService) RestoreArticleRevision(id string, revision int, adminID uint) (*models.Article, error) {
+	articleID, err := parseArticleID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var article models.Article
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&article, articleID).Error; err != nil {
+			return err
+		}
+
+		// ตรวจสอบว่า admin เป็นเจ้าของบทความนี้
+		if article.AdminID != adminID {
+			return errors.New("you don't have permission to update this article")
+		}
+
+		restored, err := findArticleRevision(tx, article.ID, revision)
+		if err != nil {
+			return err
+		}
+
+		// The old slug may have been taken by another article since
+		slug := restored.Slug
+		if slug != article.Slug {
+			slug, err = utils.EnsureUniqueSlug(tx, slug, "articles", "slug", article.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		article.Title = restored.Title
+		article.Content = restored.Content
+		article.Slug = slug
+		article.Summary = restored.Summary
+		if err := tx.Save(&article).Error; err != nil {
+			return err
+		}
+
+		return writeArticleRevision(tx, &article, adminID, &restored.Revision)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// ดึงข้อมูลที่อัปเดตแล้วพร้อม Admin
+	return s.repo.FindWithPreload([]string{"Admin"}, articleID)
+}
+
+// findArticle loads an article by its ID as given in the URL
+func (s *ArticleService) findArticle(id string) (*models.Article, error) {
+	articleID, err := parseArticleID(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(articleID)
+}
+
+// parseArticleID converts an article ID from the URL
+func parseArticleID(id string) (uint, error) {
+	idUint, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, errors.New("invalid ID format")
+	}
+	return uint(idUint), nil
+}
+
+// findArticleRevision loads one revision of an article by number
+func findArticleRevision(tx *gorm.DB, articleID uint, revision int) (*models.ArticleRevision, error) {
+	var found models.ArticleRevision
+	err := tx.Where("article_id = ? AND revision = ?", articleID, revision).First(&found).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrArticleRevisionNotFound
+		}
+		return nil, err
+	}
+	return &found, nil
+}
+
+// splitLines splits content into lines, treating CRLF and LF alike
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+}
+
+// diffLines returns a shortest line-based edit script turning a into b (Myers' algorithm).
+// The linear-space variant is used, so memory stays proportional to the number of lines
+// however different the revisions are, and the search is bounded by maxDiffRounds.
+func diffLines(a, b []string) []models.DiffLine {
+	result := make([]models.DiffLine, 0, len(a)+len(b))
+	return appendLineDiff(result, a, b)
+}
+
+// appendLineDiff appends the edit script turning a into b to result
+func appendLineDiff(result []models.DiffLine, a, b []string) []models.DiffLine {
+	// Common leading and trailing lines are kept out of the search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for _, line := range a[:prefix] {
+		result = append(result, models.DiffLine{Op: "equal", Text: line})
+	}
+
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	switch {
+	case len(middleA) == 0:
+		for _, line := range middleB {
+			result = append(result, models.DiffLine{Op: "insert", Text: line})
+		}
+	case len(middleB) == 0:
+		for _, line := range middleA {
+			result = append(result, models.DiffLine{Op: "delete", Text: line})
+		}
+	default:
+		// Split both sides at a point of a shortest edit script and diff the halves
+		x, y := middleSnake(middleA, middleB)
+		result = appendLineDiff(result, middleA[:x], middleB[:y])
+		result = appendLineDiff(result, middleA[x:], middleB[y:])
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		result = append(result, models.DiffLine{Op: "equal", Text: line})
+	}
+	return result
+}
+
+// middleSnake searches for a shortest edit script from both ends at once and returns the point
+// where the two searches meet, which lies on a shortest script. a and b must be non-empty and
+// differ in their first and last lines. Only the furthest point of each diagonal is kept.
+func middleSnake(a, b []string) (int, int) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+
+	// forward[offset+k] is the furthest x reached from the start on diagonal k = x - y,
+	// backward[offset+k] the furthest distance travelled from the end on diagonal k = (n-x) - (m-y)
+	forward := make([]int, 2*maxD+1)
+	backward := make([]int, 2*maxD+1)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	delta := n - m
+	// With an odd delta the searches meet while extending forward, otherwise while extending backward
+	checkForward := delta%2 != 0
+
+	// Diagonals that ran off the edit graph are not extended again
+	forwardStart, forwardEnd, backwardStart, backwardEnd := 0, 0, 0, 0
+
+	for d := 0; d < maxD && d < maxDiffRounds; d++ {
+		for k := -d + forwardStart; k <= d-forwardEnd; k += 2 {
+			i := offset + k
+			var x int
+			if k == -d || (k != d && forward[i-1] < forward[i+1]) {
+				x = forward[i+1]
+			} else {
+				x = forward[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[i] = x
+
+			switch {
+			case x > n:
+				forwardEnd += 2
+			case y > m:
+				forwardStart += 2
+			case checkForward:
+				j := offset + delta - k
+				if j >= 0 && j < len(backward) && backward[j] != -1 && x >= n-backward[j] {
+					return x, y
+				}
+			}
+		}
+
+		for k := -d + backwardStart; k <= d-backwardEnd; k += 2 {
+			i := offset + k
+			var x int
+			if k == -d || (k != d && backward[i-1] < backward[i+1]) {
+				x = backward[i+1]
+			} else {
+				x = backward[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[i] = x
+
+			switch {
+			case x > n:
+				backwardEnd += 2
+			case y > m:
+				backwardStart += 2
+			case !checkForward:
+				j := offset + delta - k
+				if j >= 0 && j < len(forward) && forward[j] != -1 {
+					forwardX := forward[j]
+					if forwardX >= n-x {
+						return forwardX, forwardX - (j - offset)
+					}
+				}
+			}
+		}
+	}
+
+	// The searches did not meet, either because a and b have no line in common or because they
+	// differ too much to search further: show all of a as deleted and all of b as inserted
+	return n, 0
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"dashboard-starter/models"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// applyDiff rebuilds both sides of an edit script and counts its insertions and deletions
+func applyDiff(script []models.DiffLine) (a, b []string, edits int) {
+	for _, line := range script {
+		switch line.Op {
+		case "equal":
+			a = append(a, line.Text)
+			b = append(b, line.Text)
+		case "delete":
+			a = append(a, line.Text)
+			edits++
+		case "insert":
+			b = append(b, line.Text)
+			edits++
+		}
+	}
+	return a, b, edits
+}
+
+// shortestEditCount is the length of a shortest edit script, from the longest common subsequence
+func shortestEditCount(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	return len(a) + len(b) - 2*lcs[0][0]
+}
+
+func checkDiff(t *testing.T, a, b []string) {
+	t.Helper()
+
+	gotA, gotB, edits := applyDiff(diffLines(a, b))
+	if strings.Join(gotA, "\n") != strings.Join(a, "\n") || strings.Join(gotB, "\n") != strings.Join(b, "\n") {
+		t.Fatalf("script does not turn %q into %q", a, b)
+	}
+	if want := shortestEditCount(a, b); edits != want {
+		t.Fatalf("diff of %q and %q has %d edits, want %d", a, b, edits, want)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"", ""},
+		{"a", ""},
+		{"", "a"},
+		{"a", "b"},
+		{"a\nb\nc", "a\nb\nc"},
+		{"a\nb\nc\na\nb\nb\na", "c\nb\na\nb\na\nc"},
+		{"title\nold line\nfooter", "title\nnew line\nfooter"},
+		{"a\nb\nc\nd", "x\ny"},
+		{"a\nb", "b\na\nb"},
+	}
+	for _, tt := range tests {
+		checkDiff(t, splitLines(tt.a), splitLines(tt.b))
+	}
+}
+
+func TestDiffLinesRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(40))
+		for i := range lines {
+			lines[i] = strconv.Itoa(rng.Intn(5))
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		checkDiff(t, randomLines(), randomLines())
+	}
+}
+
+func TestDiffLinesLargeRevision(t *testing.T) {
+	// Completely rewritten content used to need memory quadratic in the number of lines;
+	// now the search gives up after maxDiffRounds and reports every line as changed
+	a := make([]string, 20000)
+	b := make([]string, 20000)
+	for i := range a {
+		a[i] = "old " + strconv.Itoa(i)
+		b[i] = "new " + strconv.Itoa(i)
+	}
+
+	_, _, edits := applyDiff(diffLines(a, b))
+	if edits != len(a)+len(b) {
+		t.Fatalf("expected every line to change, got %d edits", edits)
+	}
+
+	// A long article with a few changed lines still gets a shortest script
+	edited := append([]string(nil), a...)
+	edited[10], edited[9000], edited[19999] = "changed", "changed", "changed"
+	if _, _, edits := applyDiff(diffLines(a, edited)); edits != 6 {
+		t.Fatalf("expected 6 edits, got %d", edits)
+	}
+}
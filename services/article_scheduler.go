@@ -0,0 +1,106 @@
+// services/article_scheduler.go
+package services
+
+import (
+	"context"
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// articleSchedulerLockKey is the Postgres advisory lock that lets only one server instance run a
+// scheduler pass at a time
+const articleSchedulerLockKey int64 = 0x61727469636c6573 // "articles"
+
+// ArticleScheduleResult counts the articles changed by one scheduler pass
+type ArticleScheduleResult struct {
+	Published int64
+	Archived  int64
+}
+
+// StartArticleScheduler publishes and archives scheduled articles every interval until ctx is cancelled.
+// It is safe to run on every server instance.
+func StartArticleScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		utils.Info("Article scheduler disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				runArticleSchedulerPass()
+			}
+		}
+	}()
+}
+
+// runArticleSchedulerPass runs one pass and logs the outcome without stopping the scheduler
+func runArticleSchedulerPass() {
+	defer func() {
+		if r := recover(); r != nil {
+			utils.Error("Article scheduler panicked: %v", r)
+		}
+	}()
+
+	result, err := RunArticleSchedule(time.Now())
+	if err != nil {
+		utils.Error("Article scheduler failed: %v", err)
+		return
+	}
+	if result != nil && (result.Published > 0 || result.Archived > 0) {
+		utils.Info("Article scheduler published %d and archived %d articles", result.Published, result.Archived)
+	}
+}
+
+// RunArticleSchedule publishes scheduled articles whose PublishedAt has passed and archives
+// articles whose UnpublishAt has passed. It returns nil without doing anything when another
+// instance holds the scheduler lock; the conditional updates would be safe to repeat anyway.
+func RunArticleSchedule(now time.Time) (*ArticleScheduleResult, error) {
+	var result *ArticleScheduleResult
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// The lock is released when the transaction ends
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", articleSchedulerLockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		published := tx.Model(&models.Article{}).
+			Where("status = ? AND published_at <= ?", models.ArticleStatusScheduled, now).
+			Update("status", models.ArticleStatusPublished)
+		if published.Error != nil {
+			return published.Error
+		}
+
+		archived := tx.Model(&models.Article{}).
+			Where("status = ? AND unpublish_at <= ?", models.ArticleStatusPublished, now).
+			Update("status", models.ArticleStatusArchived)
+		if archived.Error != nil {
+			return archived.Error
+		}
+
+		result = &ArticleScheduleResult{
+			Published: published.RowsAffected,
+			Archived:  archived.RowsAffected,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -5,12 +5,25 @@ import (
 	"dashboard-starter/models"
 	"dashboard-starter/utils"
 	"errors"
+	"fmt"
+	"html"
 	"strconv"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// articlePreloads are the associations returned with an article on the admin API
+var articlePreloads = []string{"Admin", "Tags", "Categories"}
+
+// ErrUnknownTaxonomy is returned when an article is given a tag or category ID that does not exist
+var ErrUnknownTaxonomy = errors.New("unknown tag or category")
+
+// ErrInvalidArticleSchedule is returned for publish times that cannot be parsed or do not make sense
+var ErrInvalidArticleSchedule = errors.New("invalid publishing schedule")
+
 type ArticleService struct {
 	repo *db.GormRepository[models.Article]
 }
@@ -28,14 +41,14 @@ func (s *ArticleService) GetByID(id string) (*models.Article, error) {
 		return nil, errors.New("invalid ID format")
 	}
 
-	// ใช้ FindWithPreload เพื่อดึงข้อมูลพร้อม Admin
-	return s.repo.FindWithPreload([]string{"Admin"}, uint(idUint))
+	// ใช้ FindWithPreload เพื่อดึงข้อมูลพร้อม Admin, tags และ categories
+	return s.repo.FindWithPreload(articlePreloads, uint(idUint))
 }
 
 // GetArticleBySlug retrieves an article by slug
 func (s *ArticleService) GetArticleBySlug(slug string) (*models.Article, error) {
 	// ใช้ FindOneWithPreload เพื่อค้นหาตาม slug พร้อม preload Admin
-	return s.repo.FindOneWithPreload([]string{"Admin"}, "slug = ?", slug)
+	return s.repo.FindOneWithPreload(articlePreloads, "slug = ?", slug)
 }
 
 // CreateArticle creates a new article
@@ -55,17 +68,10 @@ func (s *ArticleService) CreateArticle(input *models.ArticleInput, adminID uint)
 		input.Slug = uniqueSlug
 	}
 
-	// แปลงวันที่ published
-	var publishedAt *time.Time
-	if input.PublishedAt != "" && input.Status == "published" {
-		parsedTime, err := time.Parse(time.RFC3339, input.PublishedAt)
-		if err != nil {
-			return nil, errors.New("invalid published_at format. Use ISO 8601 (YYYY-MM-DDTHH:MM:SSZ)")
-		}
-		publishedAt = &parsedTime
-	} else if input.Status == "published" {
-		now := time.Now()
-		publishedAt = &now
+	// แปลงวันที่ published และกำหนดเวลาเผยแพร่
+	schedule, err := resolveArticleSchedule(input.Status, input.PublishedAt, input.UnpublishAt, nil)
+	if err != nil {
+		return nil, err
 	}
 
 	// สร้าง article
@@ -74,14 +80,21 @@ func (s *ArticleService) CreateArticle(input *models.ArticleInput, adminID uint)
 		Content:     input.Content,
 		Slug:        input.Slug,
 		Summary:     input.Summary,
-		Status:      input.Status,
-		PublishedAt: publishedAt,
+		Status:      schedule.Status,
+		PublishedAt: schedule.PublishedAt,
+		UnpublishAt: schedule.UnpublishAt,
 		AdminID:     adminID,
 	}
 
 	// สร้าง article ในฐานข้อมูล
 	err = db.Transaction(func(tx *gorm.DB) error {
-		return s.repo.Create(article)
+		if err := tx.Create(article).Error; err != nil {
+			return err
+		}
+		if err := replaceArticleTaxonomy(tx, article, input.TagIDs, input.CategoryIDs); err != nil {
+			return err
+		}
+		return writeArticleRevision(tx, article, adminID, nil)
 	})
 
 	if err != nil {
@@ -89,22 +102,98 @@ func (s *ArticleService) CreateArticle(input *models.ArticleInput, adminID uint)
 	}
 
 	// ดึงข้อมูลที่สมบูรณ์พร้อม Admin
-	return s.repo.FindWithPreload([]string{"Admin"}, article.ID)
+	return s.repo.FindWithPreload(articlePreloads, article.ID)
 }
 
-// GetArticles retrieves articles with pagination and search
-func (s *ArticleService) GetArticles(params utils.PaginationParams) ([]models.Article, *utils.PaginationResult, error) {
-	var articles []models.Article
+// GetArticles retrieves articles with pagination and search, optionally limited to a tag or category subtree
+func (s *ArticleService) GetArticles(params utils.PaginationParams, filter models.ArticleFilter) ([]models.Article, *utils.PaginationResult, error) {
+	// เพิ่ม preload Admin, tags และ categories
+	params.Preloads = articlePreloads
+
+	return s.paginateArticles(db.DB.Model(&models.Article{}), params, filter)
+}
+
+// GetPublishedArticles retrieves the articles visible on the public API: published and with a
+// publish time that has already passed. Newest articles come first unless order_by is given.
+func (s *ArticleService) GetPublishedArticles(params utils.PaginationParams, filter models.ArticleFilter) ([]models.Article, *utils.PaginationResult, error) {
+	params.Status = models.ArticleStatusPublished
+	params.Preloads = []string{"Tags", "Categories"}
+	if params.OrderBy == "" {
+		params.OrderBy = "published_at desc"
+	}
+
+	now := time.Now()
+	query := db.DB.Model(&models.Article{}).
+		Where("published_at IS NOT NULL AND published_at <= ?", now).
+		Where("unpublish_at IS NULL OR unpublish_at > ?", now)
+	return s.paginateArticles(query, params, filter)
+}
+
+// GetPublishedArticleBySlug retrieves an article by slug only if it is visible on the public API
+func (s *ArticleService) GetPublishedArticleBySlug(slug string) (*models.Article, error) {
+	now := time.Now()
+	return s.repo.FindOneWithPreload([]string{"Tags", "Categories"}, "slug = ? AND status = ? AND published_at IS NOT NULL AND published_at <= ? AND (unpublish_at IS NULL OR unpublish_at > ?)",
+		slug, models.ArticleStatusPublished, now, now)
+}
+
+// Markers ts_headline puts around matches; they are replaced with <mark></mark> after the snippet is escaped
+const (
+	snippetStartSel = "[[mark]]"
+	snippetStopSel  = "[[/mark]]"
+)
+
+// articleSnippetOptions control the highlighted content fragments returned with search results
+const articleSnippetOptions = "StartSel=" + snippetStartSel + ", StopSel=" + snippetStopSel +
+	", MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=\" ... \""
+
+// applyArticleSearch limits an article query to full-text matches of a web-style search
+// ("quoted phrases", or, -excluded) and orders them by relevance, adding search_rank and
+// search_snippet to the selected columns. Orders added later only break ties.
+// Articles whose text or slug contains the search as typed also match, since the text search
+// parser does not split Thai and other scripts written without spaces into words.
+func applyArticleSearch(query *gorm.DB, search string) *gorm.DB {
+	search = truncateRunes(search, 200)
+	tsquery := gorm.Expr("websearch_to_tsquery(?::regconfig, ?)", models.ArticleSearchConfig, search)
+
+	// The snippet is built from the content with its HTML tags removed and escaped by formatSearchSnippet
+	query = query.
+		Select("articles.*, ts_rank(articles.search_vector, ?) AS search_rank, "+
+			"ts_headline(?::regconfig, regexp_replace(articles.content, '<[^>]*>', ' ', 'g'), ?, ?) AS search_snippet",
+			tsquery, models.ArticleSearchConfig, tsquery, articleSnippetOptions).
+		Order("search_rank DESC")
+
+	if pattern, ok := substringPattern(search); ok {
+		return query.Where("(articles.search_vector @@ ? OR "+models.ArticleSearchText+" LIKE ?)", tsquery, pattern)
+	}
+	return query.Where("articles.search_vector @@ ?", tsquery)
+}
+
+// substringPattern turns a search into a lower-case LIKE pattern matching it anywhere.
+// Phrase quotes are dropped and LIKE wildcards escaped; ok is false when nothing is left to match.
+func substringPattern(search string) (pattern string, ok bool) {
+	term := strings.TrimSpace(strings.ReplaceAll(search, "\"", ""))
+	if term == "" {
+		return "", false
+	}
+
+	escaped := strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(strings.ToLower(term))
+	return "%" + escaped + "%", true
+}
 
-	// เพิ่ม preload Admin
-	params.Preloads = []string{"Admin"}
+// formatSearchSnippet makes a ts_headline snippet safe to render as HTML: the text is escaped
+// and only the match markers become <mark></mark>
+func formatSearchSnippet(snippet string) string {
+	escaped := html.EscapeString(html.UnescapeString(snippet))
+	return strings.NewReplacer(snippetStartSel, "<mark>", snippetStopSel, "</mark>").Replace(escaped)
+}
 
-	// สร้าง query
-	query := db.DB.Model(&models.Article{})
+// paginateArticles applies search, the status, tag and category filters and pagination to an article query
+func (s *ArticleService) paginateArticles(query *gorm.DB, params utils.PaginationParams, filter models.ArticleFilter) ([]models.Article, *utils.PaginationResult, error) {
+	var articles []models.Article
 
-	// ใช้ search ถ้ามี
+	// ใช้ full-text search ถ้ามี
 	if params.Search != "" {
-		query = utils.ApplySearch(query, params.Search, "title", "content", "slug")
+		query = applyArticleSearch(query, params.Search)
 	}
 
 	// กรองตาม status ถ้ามี
@@ -112,12 +201,34 @@ func (s *ArticleService) GetArticles(params utils.PaginationParams) ([]models.Ar
 		query = query.Where("status = ?", params.Status)
 	}
 
+	// กรองตาม tag และ category รวมถึง category ย่อยทั้งหมด
+	if filter.Tag != "" {
+		query = query.Where("articles.id IN (SELECT article_tags.article_id FROM article_tags JOIN tags ON tags.id = article_tags.tag_id WHERE tags.slug = ?)", filter.Tag)
+	}
+	if filter.Category != "" {
+		categoryIDs, err := categorySubtreeIDs(db.DB, "slug = ?", filter.Category)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(categoryIDs) == 0 {
+			// Unknown category: nothing matches
+			return []models.Article{}, &utils.PaginationResult{Page: params.Page, Limit: params.Limit}, nil
+		}
+		query = query.Where("articles.id IN (SELECT article_id FROM article_categories WHERE category_id IN ?)", categoryIDs)
+	}
+
 	// ใช้ pagination
 	result, err := utils.ApplyPagination(query, params, &articles)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	for i := range articles {
+		if articles[i].SearchSnippet != "" {
+			articles[i].SearchSnippet = formatSearchSnippet(articles[i].SearchSnippet)
+		}
+	}
+
 	return articles, result, nil
 }
 
@@ -129,62 +240,55 @@ func (s *ArticleService) UpdateArticle(id string, input *models.ArticleInput, ad
 		return nil, errors.New("invalid ID format")
 	}
 
-	// ค้นหา article
-	article, err := s.repo.FindByID(uint(idUint))
-	if err != nil {
-		return nil, err
-	}
-
-	// ตรวจสอบว่า admin เป็นเจ้าของบทความนี้
-	if article.AdminID != adminID {
-		return nil, errors.New("you don't have permission to update this article")
-	}
+	// อัปเดตและบันทึก revision ด้วย transaction
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Lock the article first so concurrent updates apply and number their revisions in turn
+		var article models.Article
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&article, idUint).Error; err != nil {
+			return err
+		}
 
-	// ตรวจสอบ slug ซ้ำถ้ามีการเปลี่ยนแปลง
-	if input.Slug != article.Slug {
-		count, err := s.repo.Count("slug = ? AND id != ?", input.Slug, article.ID)
-		if err != nil {
-			return nil, err
+		// ตรวจสอบว่า admin เป็นเจ้าของบทความนี้
+		if article.AdminID != adminID {
+			return errors.New("you don't have permission to update this article")
 		}
 
-		if count > 0 {
-			// If slug already exists, generate a unique one
-			uniqueSlug, err := utils.EnsureUniqueSlug(db.DB, input.Slug, "articles", "slug", article.ID)
+		// ตรวจสอบ slug ซ้ำถ้ามีการเปลี่ยนแปลง
+		if input.Slug != article.Slug {
+			uniqueSlug, err := utils.EnsureUniqueSlug(tx, input.Slug, "articles", "slug", article.ID)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			input.Slug = uniqueSlug
 		}
-	}
 
-	// แปลงวันที่เผยแพร่ถ้ามีการระบุ
-	var publishedAt *time.Time
-	if input.PublishedAt != "" && input.Status == "published" {
-		parsedTime, err := time.Parse(time.RFC3339, input.PublishedAt)
+		// แปลงวันที่เผยแพร่ถ้ามีการระบุ
+		schedule, err := resolveArticleSchedule(input.Status, input.PublishedAt, input.UnpublishAt, &article)
 		if err != nil {
-			return nil, errors.New("invalid published_at format. Use ISO 8601 (YYYY-MM-DDTHH:MM:SSZ)")
+			return err
 		}
-		publishedAt = &parsedTime
-	} else if input.Status == "published" && (article.PublishedAt == nil || article.Status != "published") {
-		// ถ้าสถานะเปลี่ยนเป็น published แต่ไม่ได้ระบุวันที่ ให้ใช้เวลาปัจจุบัน
-		now := time.Now()
-		publishedAt = &now
-	} else {
-		// คงวันที่เผยแพร่เดิม
-		publishedAt = article.PublishedAt
-	}
-
-	// อัปเดตข้อมูลบทความ
-	article.Title = input.Title
-	article.Content = input.Content
-	article.Slug = input.Slug
-	article.Summary = input.Summary
-	article.Status = input.Status
-	article.PublishedAt = publishedAt
-
-	// อัปเดตด้วย transaction
-	err = db.Transaction(func(tx *gorm.DB) error {
-		return s.repo.Update(article)
+
+		// เก็บเนื้อหาเดิมไว้สำหรับบทความที่ยังไม่มีประวัติ
+		if err := ensureBaselineRevision(tx, &article); err != nil {
+			return err
+		}
+
+		// อัปเดตข้อมูลบทความ
+		article.Title = input.Title
+		article.Content = input.Content
+		article.Slug = input.Slug
+		article.Summary = input.Summary
+		article.Status = schedule.Status
+		article.PublishedAt = schedule.PublishedAt
+		article.UnpublishAt = schedule.UnpublishAt
+
+		if err := tx.Save(&article).Error; err != nil {
+			return err
+		}
+		if err := replaceArticleTaxonomy(tx, &article, input.TagIDs, input.CategoryIDs); err != nil {
+			return err
+		}
+		return writeArticleRevision(tx, &article, adminID, nil)
 	})
 
 	if err != nil {
@@ -192,7 +296,7 @@ func (s *ArticleService) UpdateArticle(id string, input *models.ArticleInput, ad
 	}
 
 	// ดึงข้อมูลที่อัปเดตแล้วพร้อม Admin
-	return s.repo.FindWithPreload([]string{"Admin"}, article.ID)
+	return s.repo.FindWithPreload(articlePreloads, uint(idUint))
 }
 
 // DeleteArticle deletes an article
@@ -220,8 +324,9 @@ func (s *ArticleService) DeleteArticle(id string, adminID uint) error {
 	})
 }
 
-// PublishArticle sets an article to published status
-func (s *ArticleService) PublishArticle(id string, adminID uint) (*models.Article, error) {
+// PublishArticle sets an article to published status, or to scheduled when input gives a
+// future published_at. input may be nil to publish immediately.
+func (s *ArticleService) PublishArticle(id string, input *models.PublishArticleInput, adminID uint) (*models.Article, error) {
 	// แปลง id เป็น uint
 	idUint, err := strconv.ParseUint(id, 10, 32)
 	if err != nil {
@@ -239,10 +344,18 @@ func (s *ArticleService) PublishArticle(id string, adminID uint) (*models.Articl
 		return nil, errors.New("you don't have permission to publish this article")
 	}
 
-	// อัปเดตสถานะบทความเป็น published
-	now := time.Now()
-	article.Status = "published"
-	article.PublishedAt = &now
+	if input == nil {
+		input = &models.PublishArticleInput{}
+	}
+
+	// อัปเดตสถานะบทความเป็น published หรือ scheduled ถ้าระบุเวลาในอนาคต
+	schedule, err := resolveArticleSchedule(models.ArticleStatusPublished, input.PublishedAt, input.UnpublishAt, nil)
+	if err != nil {
+		return nil, err
+	}
+	article.Status = schedule.Status
+	article.PublishedAt = schedule.PublishedAt
+	article.UnpublishAt = schedule.UnpublishAt
 
 	// บันทึกการเปลี่ยนแปลงด้วย transaction
 	err = db.Transaction(func(tx *gorm.DB) error {
@@ -254,5 +367,133 @@ func (s *ArticleService) PublishArticle(id string, adminID uint) (*models.Articl
 	}
 
 	// ดึงข้อมูลที่อัปเดตแล้วพร้อม Admin
-	return s.repo.FindWithPreload([]string{"Admin"}, article.ID)
+	return s.repo.FindWithPreload(articlePreloads, article.ID)
+}
+
+// articleSchedule is the status and publishing times an article is saved with
+type articleSchedule struct {
+	Status      string
+	PublishedAt *time.Time
+	UnpublishAt *time.Time
+}
+
+// resolveArticleSchedule works out how an article with the requested status and ISO 8601 times is
+// stored. Published articles with a future publish time become scheduled, and scheduled articles
+// whose time has passed are published right away. current is the stored article on update.
+func resolveArticleSchedule(status, publishedAtInput, unpublishAtInput string, current *models.Article) (*articleSchedule, error) {
+	publishedAt, err := parseArticleTime("published_at", publishedAtInput)
+	if err != nil {
+		return nil, err
+	}
+	unpublishAt, err := parseArticleTime("unpublish_at", unpublishAtInput)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	schedule := &articleSchedule{Status: status}
+
+	switch status {
+	case models.ArticleStatusPublished, models.ArticleStatusScheduled:
+		if publishedAt == nil {
+			if status == models.ArticleStatusScheduled {
+				return nil, fmt.Errorf("%w: published_at is required for scheduled articles", ErrInvalidArticleSchedule)
+			}
+			if current != nil && current.Status == models.ArticleStatusPublished && current.PublishedAt != nil {
+				// คงวันที่เผยแพร่เดิม
+				publishedAt = current.PublishedAt
+			} else {
+				publishedAt = &now
+			}
+		}
+
+		if publishedAt.After(now) {
+			schedule.Status = models.ArticleStatusScheduled
+		} else {
+			schedule.Status = models.ArticleStatusPublished
+		}
+		schedule.PublishedAt = publishedAt
+
+		if unpublishAt != nil {
+			if !unpublishAt.After(*publishedAt) || !unpublishAt.After(now) {
+				return nil, fmt.Errorf("%w: unpublish_at must be in the future and after published_at", ErrInvalidArticleSchedule)
+			}
+			schedule.UnpublishAt = unpublishAt
+		}
+	default:
+		// บทความที่ไม่ได้เผยแพร่คงวันที่เผยแพร่เดิมไว้ แต่ไม่มีกำหนดการใดๆ
+		if current != nil {
+			schedule.PublishedAt = current.PublishedAt
+		}
+	}
+
+	return schedule, nil
+}
+
+// parseArticleTime parses an optional ISO 8601 time from article input
+func parseArticleTime(field, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid %s format. Use ISO 8601 (YYYY-MM-DDTHH:MM:SSZ)", ErrInvalidArticleSchedule, field)
+	}
+	return &parsed, nil
+}
+
+// replaceArticleTaxonomy sets the tags and categories of an article. A nil slice leaves that
+// association unchanged and an empty one clears it.
+func replaceArticleTaxonomy(tx *gorm.DB, article *models.Article, tagIDs, categoryIDs []uint) error {
+	if tagIDs != nil {
+		var tags []models.Tag
+		if err := findTaxonomy(tx, tagIDs, &tags); err != nil {
+			return err
+		}
+		association := tx.Model(article).Association("Tags")
+		if len(tags) == 0 {
+			if err := association.Clear(); err != nil {
+				return err
+			}
+		} else if err := association.Replace(tags); err != nil {
+			return err
+		}
+	}
+
+	if categoryIDs != nil {
+		var categories []models.Category
+		if err := findTaxonomy(tx, categoryIDs, &categories); err != nil {
+			return err
+		}
+		association := tx.Model(article).Association("Categories")
+		if len(categories) == 0 {
+			if err := association.Clear(); err != nil {
+				return err
+			}
+		} else if err := association.Replace(categories); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findTaxonomy loads the tags or categories with the given IDs, failing if any of them does not exist
+func findTaxonomy[T any](tx *gorm.DB, ids []uint, result *[]T) error {
+	unique := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		unique[id] = true
+	}
+	if len(unique) == 0 {
+		*result = []T{}
+		return nil
+	}
+
+	if err := tx.Where("id IN ?", ids).Find(result).Error; err != nil {
+		return err
+	}
+	if len(*result) != len(unique) {
+		return ErrUnknownTaxonomy
+	}
+	return nil
 }
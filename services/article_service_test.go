@@ -0,0 +1,43 @@
+package services
+
+import "testing"
+
+func TestSubstringPattern(t *testing.T) {
+	tests := []struct {
+		search  string
+		pattern string
+		ok      bool
+	}{
+		{"ภาษาไทย", "%ภาษาไทย%", true},
+		{"My-Slug", "%my-slug%", true},
+		{`"exact phrase"`, "%exact phrase%", true},
+		{"100%_off", `%100\%\_off%`, true},
+		{`a\b`, `%a\\b%`, true},
+		{`  "" `, "", false},
+	}
+	for _, tt := range tests {
+		pattern, ok := substringPattern(tt.search)
+		if pattern != tt.pattern || ok != tt.ok {
+			t.Errorf("substringPattern(%q) = %q, %v; want %q, %v", tt.search, pattern, ok, tt.pattern, tt.ok)
+		}
+	}
+}
+
+func TestFormatSearchSnippet(t *testing.T) {
+	tests := []struct {
+		snippet string
+		want    string
+	}{
+		{"a [[mark]]match[[/mark]] here", "a <mark>match</mark> here"},
+		// Markup left in the content is escaped, only the markers become tags
+		{`<img src=x onerror=alert(1)> [[mark]]go[[/mark]]`, "&lt;img src=x onerror=alert(1)&gt; <mark>go</mark>"},
+		{"<mark>fake</mark>", "&lt;mark&gt;fake&lt;/mark&gt;"},
+		// Entities from the HTML content are not escaped twice
+		{"Tom &amp; Jerry &lt;3", "Tom &amp; Jerry &lt;3"},
+	}
+	for _, tt := range tests {
+		if got := formatSearchSnippet(tt.snippet); got != tt.want {
+			t.Errorf("formatSearchSnippet(%q) = %q, want %q", tt.snippet, got, tt.want)
+		}
+	}
+}
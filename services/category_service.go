@@ -0,0 +1,203 @@
+// services/category_service.go
+package services
+
+import (
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrCategoryHasChildren is returned when deleting a category that still has subcategories
+	ErrCategoryHasChildren = errors.New("category has subcategories; move or delete them first")
+	// ErrInvalidCategoryParent is returned when the parent does not exist or lies inside the category's own subtree
+	ErrInvalidCategoryParent = errors.New("invalid parent category")
+)
+
+// categoryTreeLockKey serialises changes to the category hierarchy
+const categoryTreeLockKey = 7420002
+
+type CategoryService struct {
+	repo *db.GormRepository[models.Category]
+}
+
+func NewCategoryService() *CategoryService {
+	return &CategoryService{
+		repo: db.NewRepository[models.Category](),
+	}
+}
+
+// GetCategoryTree returns all categories as a tree of top-level categories, ordered by name
+func (s *CategoryService) GetCategoryTree() ([]models.Category, error) {
+	var categories []models.Category
+	if err := db.DB.Order("name asc").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	children := make(map[uint][]models.Category)
+	var roots []models.Category
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+		} else {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
+
+	var attach func(nodes []models.Category) []models.Category
+	attach = func(nodes []models.Category) []models.Category {
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+		}
+		return nodes
+	}
+
+	return attach(roots), nil
+}
+
+// GetByID retrieves a category with its direct subcategories
+func (s *CategoryService) GetByID(id string) (*models.Category, error) {
+	category, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.DB.Where("parent_id = ?", category.ID).Order("name asc").Find(&category.Children).Error; err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// CreateCategory creates a category, generating a unique slug from the name when none is given
+func (s *CategoryService) CreateCategory(input *models.CategoryInput) (*models.Category, error) {
+	category := &models.Category{
+		Name:        strings.TrimSpace(input.Name),
+		Description: input.Description,
+		ParentID:    input.ParentID,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := checkCategoryParent(tx, 0, input.ParentID); err != nil {
+			return err
+		}
+
+		slug, err := taxonomySlug(tx, "categories", input.Name, input.Slug, 0)
+		if err != nil {
+			return err
+		}
+		category.Slug = slug
+		return tx.Create(category).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
+// UpdateCategory updates a category; it may be moved anywhere except into its own subtree
+func (s *CategoryService) UpdateCategory(id string, input *models.CategoryInput) (*models.Category, error) {
+	category, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := checkCategoryParent(tx, category.ID, input.ParentID); err != nil {
+			return err
+		}
+
+		slug, err := taxonomySlug(tx, "categories", input.Name, input.Slug, category.ID)
+		if err != nil {
+			return err
+		}
+
+		category.Name = strings.TrimSpace(input.Name)
+		category.Slug = slug
+		category.Description = input.Description
+		category.ParentID = input.ParentID
+		return tx.Save(category).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
+// DeleteCategory deletes a category without subcategories and removes it from all articles
+func (s *CategoryService) DeleteCategory(id string) error {
+	category, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Same lock as checkCategoryParent, so no subcategory is added while the category is deleted
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", categoryTreeLockKey).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrCategoryHasChildren
+		}
+
+		if err := tx.Exec("DELETE FROM article_categories WHERE category_id = ?", category.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(category).Error
+	})
+}
+
+// checkCategoryParent makes sure parentID names an existing category outside the subtree of
+// categoryID, which is 0 for a new category. It takes a transaction-scoped lock so two concurrent
+// moves cannot each pass the check and together form a cycle.
+func checkCategoryParent(tx *gorm.DB, categoryID uint, parentID *uint) error {
+	if parentID == nil {
+		return nil
+	}
+
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", categoryTreeLockKey).Error; err != nil {
+		return err
+	}
+
+	var count int64
+	if err := tx.Model(&models.Category{}).Where("id = ?", *parentID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrInvalidCategoryParent
+	}
+
+	if categoryID == 0 {
+		return nil
+	}
+	subtree, err := categorySubtreeIDs(tx, "id = ?", categoryID)
+	if err != nil {
+		return err
+	}
+	for _, id := range subtree {
+		if id == *parentID {
+			return ErrInvalidCategoryParent
+		}
+	}
+	return nil
+}
+
+// categorySubtreeIDs returns the IDs of the categories matching the condition and all of their descendants
+func categorySubtreeIDs(tx *gorm.DB, condition string, args ...interface{}) ([]uint, error) {
+	var ids []uint
+	err := tx.Raw(`WITH RECURSIVE subtree AS (
+			SELECT id FROM categories WHERE `+condition+`
+			UNION
+			SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+		)
+		SELECT id FROM subtree`, args...).Scan(&ids).Error
+	return ids, err
+}
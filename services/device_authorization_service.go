@@ -0,0 +1,321 @@
+// services/device_authorization_service.go
+package services
+
+import (
+	"crypto/rand"
+	"dashboard-starter/config"
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"errors"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Errors returned while polling for tokens; their text is the RFC 8628 error code
+var (
+	ErrAuthorizationPending = errors.New("authorization_pending")
+	ErrSlowDown             = errors.New("slow_down")
+	ErrAccessDenied         = errors.New("access_denied")
+	ErrExpiredDeviceCode    = errors.New("expired_token")
+	ErrInvalidDeviceCode    = errors.New("invalid_grant")
+)
+
+// ErrInvalidUserCode is returned when a user enters a code that is unknown, expired, already used
+// or entered by another user
+var ErrInvalidUserCode = errors.New("invalid or expired user code")
+
+// DeviceAuthorizationTokens are issued to the client of an approved device authorization request
+type DeviceAuthorizationTokens struct {
+	AccessToken  string
+	ExpiresAt    time.Time
+	RefreshToken string
+}
+
+const (
+	// userCodeAlphabet has no vowels or easily confused characters (RFC 8628 section 6.1)
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+	// slowDownIncrement is added to the polling interval each time a client polls too fast
+	slowDownIncrement = 5
+)
+
+// StartDeviceAuthorization creates a device authorization request for a client that cannot
+// take the user's credentials itself. A registered OAuth client passes its client ID so the session
+// is issued to it and it can later revoke the tokens; anonymous clients pass an empty one.
+func StartDeviceAuthorization(clientID, clientName, ipAddress string) (*models.DeviceAuthorizationResponse, error) {
+	if clientID != "" {
+		if _, err := FindOAuthClient(clientID); err != nil {
+			return nil, err
+		}
+	}
+
+	deviceCode, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := time.Duration(config.Config.Security.DeviceCodeMinutes) * time.Minute
+	authorization := &models.DeviceAuthorization{
+		DeviceCodeHash: utils.HashToken(deviceCode),
+		ClientName:     truncateRunes(strings.TrimSpace(clientName), 100),
+		ClientID:       clientID,
+		IPAddress:      ipAddress,
+		Status:         models.DeviceAuthorizationPending,
+		Interval:       config.Config.Security.DeviceCodeIntervalSeconds,
+		ExpiresAt:      time.Now().Add(ttl),
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Abandoned requests are cleaned up whenever a new one starts
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.DeviceAuthorization{}).Error; err != nil {
+			return err
+		}
+
+		// Retry the rare user code collision with another live request
+		for attempt := 0; attempt < 5; attempt++ {
+			userCode, err := generateUserCode()
+			if err != nil {
+				return err
+			}
+
+			var count int64
+			if err := tx.Model(&models.DeviceAuthorization{}).Where("user_code = ?", userCode).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				authorization.UserCode = userCode
+				return tx.Create(authorization).Error
+			}
+		}
+		return errors.New("failed to generate a unique user code")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	displayCode := FormatUserCode(authorization.UserCode)
+	verificationURI := config.Config.App.FrontendURL + "/device"
+	return &models.DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                displayCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + url.QueryEscape(displayCode),
+		ExpiresIn:               int(ttl.Seconds()),
+		Interval:                authorization.Interval,
+	}, nil
+}
+
+// GetPendingDeviceAuthorization returns the request a user code belongs to so the user can check
+// which client they are about to sign in. The first user to enter a code claims the request;
+// only that user can approve or deny it afterwards.
+func GetPendingDeviceAuthorization(userCode string, userID uint) (*models.DeviceAuthorization, error) {
+	var authorization models.DeviceAuthorization
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.DeviceAuthorization{}).
+			Where("user_code = ? AND status = ? AND expires_at > ? AND user_id IS NULL",
+				normalizeUserCode(userCode), models.DeviceAuthorizationPending, time.Now()).
+			Update("user_id", userID)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Where("user_code = ? AND status = ? AND expires_at > ? AND user_id = ?",
+			normalizeUserCode(userCode), models.DeviceAuthorizationPending, time.Now(), userID).
+			First(&authorization).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidUserCode
+		}
+		return nil, err
+	}
+
+	authorization.UserCode = FormatUserCode(authorization.UserCode)
+	return &authorization, nil
+}
+
+// ApproveDeviceAuthorization signs the client of a pending request in as the user.
+// A request entered by another user cannot be approved.
+func ApproveDeviceAuthorization(userCode string, userID uint) error {
+	return decideDeviceAuthorization(userCode, "(user_id IS NULL OR user_id = ?)", userID, map[string]interface{}{
+		"status":  models.DeviceAuthorizationApproved,
+		"user_id": userID,
+	})
+}
+
+// DenyDeviceAuthorization rejects a pending request; the client receives access_denied.
+// Only the user who entered the code can deny it, so others cannot cancel sign-ins by guessing codes.
+func DenyDeviceAuthorization(userCode string, userID uint) error {
+	return decideDeviceAuthorization(userCode, "user_id = ?", userID, map[string]interface{}{
+		"status": models.DeviceAuthorizationDenied,
+	})
+}
+
+// decideDeviceAuthorization moves a live pending request that the user may decide on to its final status exactly once
+func decideDeviceAuthorization(userCode, userCondition string, userID uint, updates map[string]interface{}) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.DeviceAuthorization{}).
+			Where("user_code = ? AND status = ? AND expires_at > ?",
+				normalizeUserCode(userCode), models.DeviceAuthorizationPending, time.Now()).
+			Where(userCondition, userID).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidUserCode
+		}
+		return nil
+	})
+}
+
+// PollDeviceAuthorization is called by the client with its device code. Once the user has approved
+// the request it is consumed and tokens are issued in the same transaction, so a failure leaves the
+// request in place for the next poll; until then one of the RFC 8628 polling errors is returned.
+// The client ID must match the one the request was started with.
+func PollDeviceAuthorization(deviceCode, clientID string, client models.SessionClient) (*DeviceAuthorizationTokens, error) {
+	var (
+		authorization models.DeviceAuthorization
+		tokens        *DeviceAuthorizationTokens
+		pollErr       error
+	)
+
+	// The transaction commits the poll bookkeeping even when the client gets a polling error
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("device_code_hash = ?", utils.HashToken(deviceCode)).
+			First(&authorization).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				pollErr = ErrInvalidDeviceCode
+				return nil
+			}
+			return err
+		}
+
+		// A device code is bound to the client that requested it (RFC 8628 section 3.4)
+		if authorization.ClientID != clientID {
+			pollErr = ErrInvalidDeviceCode
+			return nil
+		}
+
+		now := time.Now()
+		if now.After(authorization.ExpiresAt) {
+			pollErr = ErrExpiredDeviceCode
+			return tx.Delete(&authorization).Error
+		}
+
+		// Clients that poll faster than the interval are asked to back off further
+		minInterval := time.Duration(authorization.Interval) * time.Second
+		if authorization.LastPolledAt != nil && now.Sub(*authorization.LastPolledAt) < minInterval {
+			pollErr = ErrSlowDown
+			authorization.Interval += slowDownIncrement
+		}
+		authorization.LastPolledAt = &now
+		if pollErr != nil {
+			return tx.Model(&authorization).Select("interval", "last_polled_at").Updates(&authorization).Error
+		}
+
+		switch authorization.Status {
+		case models.DeviceAuthorizationApproved:
+			var err error
+			tokens, err = issueDeviceAuthorizationTokens(tx, &authorization, client)
+			if errors.Is(err, ErrPrincipalNotFound) {
+				// The user was deleted between approving and this poll
+				pollErr = ErrInvalidDeviceCode
+			} else if err != nil {
+				return err
+			}
+			return tx.Delete(&authorization).Error
+		case models.DeviceAuthorizationDenied:
+			pollErr = ErrAccessDenied
+			return tx.Delete(&authorization).Error
+		default:
+			pollErr = ErrAuthorizationPending
+			return tx.Model(&authorization).Update("last_polled_at", now).Error
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pollErr != nil {
+		return nil, pollErr
+	}
+
+	return tokens, nil
+}
+
+// issueDeviceAuthorizationTokens starts a session for the user who approved the request
+func issueDeviceAuthorizationTokens(tx *gorm.DB, authorization *models.DeviceAuthorization, client models.SessionClient) (*DeviceAuthorizationTokens, error) {
+	var user models.User
+	if err := tx.Select("id", "token_version").First(&user, *authorization.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPrincipalNotFound
+		}
+		return nil, err
+	}
+
+	if client.DeviceLabel == "" {
+		client.DeviceLabel = authorization.ClientName
+	}
+	client.ClientID = authorization.ClientID
+
+	familyID, err := utils.GenerateSecureToken(16)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := createRefreshTokenInFamily(tx, user.ID, "user", familyID, client)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, expiresAt, err := utils.GenerateToken(user.ID, "user", user.TokenVersion, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeviceAuthorizationTokens{
+		AccessToken:  accessToken,
+		ExpiresAt:    expiresAt,
+		RefreshToken: refreshToken.Token,
+	}, nil
+}
+
+// FormatUserCode inserts a dash in the middle of a user code for display, e.g. WDJB-MJHT
+func FormatUserCode(code string) string {
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
+
+// normalizeUserCode accepts codes typed in lower case or with dashes and spaces
+func normalizeUserCode(code string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(code) {
+		if r >= 'A' && r <= 'Z' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// generateUserCode returns a random user code without the display dash
+func generateUserCode() (string, error) {
+	code := make([]byte, userCodeLength)
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
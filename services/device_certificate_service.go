@@ -0,0 +1,186 @@
+// services/device_certificate_service.go
+package services
+
+import (
+	"crypto/x509"
+	"dashboard-starter/config"
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidCertificate is returned for certificates that cannot be parsed, are expired or are not signed by the client CA
+	ErrInvalidCertificate = errors.New("invalid client certificate")
+	// ErrCertificateIdentityMismatch is returned when a certificate names a different device
+	ErrCertificateIdentityMismatch = errors.New("certificate does not identify this device")
+	// ErrCertificateAlreadyPinned is returned when the certificate is already pinned to a device
+	ErrCertificateAlreadyPinned = errors.New("certificate is already pinned")
+	// ErrDeviceCertificateNotFound is returned when the certificate is not pinned to the device
+	ErrDeviceCertificateNotFound = errors.New("device certificate not found")
+	// ErrCertificateNotPinned is returned when a device logs in with a certificate that is not pinned or was revoked
+	ErrCertificateNotPinned = errors.New("certificate is not pinned to the device or has been revoked")
+	// ErrMTLSDisabled is returned when client certificates are used but no client CA is configured
+	ErrMTLSDisabled = errors.New("client certificate authentication is not enabled")
+)
+
+// DeviceIDFromCertificate returns the device ID a client certificate was issued for, taken from the
+// subject common name or, with SECURITY_DEVICE_CERT_IDENTITY=san, its single DNS subject alternative name
+func DeviceIDFromCertificate(cert *x509.Certificate) (string, error) {
+	switch config.Config.Security.DeviceCertIdentity {
+	case "san":
+		if len(cert.DNSNames) != 1 {
+			return "", fmt.Errorf("%w: expected exactly one DNS subject alternative name", ErrInvalidCertificate)
+		}
+		return cert.DNSNames[0], nil
+	default:
+		if cert.Subject.CommonName == "" {
+			return "", fmt.Errorf("%w: subject has no common name", ErrInvalidCertificate)
+		}
+		return cert.Subject.CommonName, nil
+	}
+}
+
+// verifyClientCertificate checks that a certificate chains to the client CA bundle and allows client authentication
+func verifyClientCertificate(cert *x509.Certificate) error {
+	pool := utils.ClientCAPool()
+	if pool == nil {
+		return ErrMTLSDisabled
+	}
+
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:     pool,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+	}
+	return nil
+}
+
+// PinDeviceCertificate pins a PEM certificate to a device after checking that it is signed by the
+// client CA and names the device
+func PinDeviceCertificate(device *models.Device, certificatePEM string) (*models.DeviceCertificate, error) {
+	cert, err := utils.ParseCertificatePEM(certificatePEM)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+	}
+	if err := verifyClientCertificate(cert); err != nil {
+		return nil, err
+	}
+
+	identity, err := DeviceIDFromCertificate(cert)
+	if err != nil {
+		return nil, err
+	}
+	if identity != device.DeviceID {
+		return nil, ErrCertificateIdentityMismatch
+	}
+
+	pinned := &models.DeviceCertificate{
+		DeviceID:    device.ID,
+		Fingerprint: utils.CertificateFingerprint(cert),
+		Subject:     truncateRunes(cert.Subject.String(), 255),
+		Issuer:      truncateRunes(cert.Issuer.String(), 255),
+		NotAfter:    cert.NotAfter,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.DeviceCertificate{}).Where("fingerprint = ?", pinned.Fingerprint).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrCertificateAlreadyPinned
+		}
+		return tx.Create(pinned).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pinned, nil
+}
+
+// ListDeviceCertificates returns the certificates pinned to a device, newest first
+func ListDeviceCertificates(deviceID uint) ([]models.DeviceCertificate, error) {
+	var certificates []models.DeviceCertificate
+	err := db.DB.Where("device_id = ?", deviceID).Order("created_at DESC").Find(&certificates).Error
+	return certificates, err
+}
+
+// RevokeDeviceCertificate stops a pinned certificate from being used and signs the device out,
+// since tokens issued with the certificate cannot be told apart from the device's other tokens
+func RevokeDeviceCertificate(device *models.Device, certificateID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.DeviceCertificate{}).
+			Where("id = ? AND device_id = ? AND revoked_at IS NULL", certificateID, device.ID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrDeviceCertificateNotFound
+		}
+
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND user_type = ? AND is_revoked = ?", device.ID, "device", false).
+			Updates(revokedColumns(models.RefreshTokenRevoked)).Error; err != nil {
+			return err
+		}
+
+		device.TokenVersion += 1
+		return tx.Model(device).Update("token_version", device.TokenVersion).Error
+	})
+}
+
+// AuthenticateDeviceCertificate returns the device a verified client certificate belongs to.
+// The certificate must name an existing device and be pinned to it.
+func AuthenticateDeviceCertificate(cert *x509.Certificate) (*models.Device, error) {
+	deviceID, err := DeviceIDFromCertificate(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	var device models.Device
+	if err := db.DB.Where("device_id = ?", deviceID).First(&device).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCertificateNotPinned
+		}
+		return nil, err
+	}
+
+	var certificates []models.DeviceCertificate
+	if err := db.DB.Where("device_id = ? AND revoked_at IS NULL", device.ID).Find(&certificates).Error; err != nil {
+		return nil, err
+	}
+
+	pinned := findPinnedCertificate(cert, certificates)
+	if pinned == nil {
+		return nil, ErrCertificateNotPinned
+	}
+
+	now := time.Now()
+	if err := db.DB.Model(pinned).Update("last_used_at", now).Error; err != nil {
+		utils.Warn("Failed to update last use of certificate %d: %v", pinned.ID, err)
+	}
+
+	return &device, nil
+}
+
+// findPinnedCertificate returns the unrevoked pinned certificate with the fingerprint of cert, or nil.
+// Another certificate the CA issued for the same device does not match.
+func findPinnedCertificate(cert *x509.Certificate, certificates []models.DeviceCertificate) *models.DeviceCertificate {
+	fingerprint := utils.CertificateFingerprint(cert)
+	for i := range certificates {
+		if certificates[i].RevokedAt == nil && certificates[i].Fingerprint == fingerprint {
+			return &certificates[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"dashboard-starter/config"
+	"dashboard-starter/internal/testca"
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"errors"
+	"testing"
+	"time"
+)
+
+// deviceCert issues a client certificate naming the device in its common name
+func deviceCert(ca *testca.CA, deviceID string) *x509.Certificate {
+	return ca.Leaf(deviceID, x509.ExtKeyUsageClientAuth).Leaf
+}
+
+// useClientCA enables mTLS with ca as the client CA bundle, the way the server does at startup
+func useClientCA(t *testing.T, ca *testca.CA) {
+	t.Helper()
+
+	// Registered first so it runs after the settings are restored
+	t.Cleanup(func() { utils.InitTLS() })
+	testca.UseServerTLS(t, ca, ca)
+	if _, err := utils.InitTLS(); err != nil {
+		t.Fatalf("InitTLS: %v", err)
+	}
+}
+
+func useDeviceCertIdentity(t *testing.T, identity string) {
+	previous := config.Config.Security.DeviceCertIdentity
+	t.Cleanup(func() { config.Config.Security.DeviceCertIdentity = previous })
+	config.Config.Security.DeviceCertIdentity = identity
+}
+
+func TestPinDeviceCertificateRequiresMTLS(t *testing.T) {
+	ca := testca.New(t, "Device CA")
+	device := &models.Device{ID: 1, DeviceID: "sensor-001"}
+
+	_, err := PinDeviceCertificate(device, testca.CertificatePEM(deviceCert(ca, "sensor-001")))
+	if !errors.Is(err, ErrMTLSDisabled) {
+		t.Fatalf("expected ErrMTLSDisabled, got %v", err)
+	}
+}
+
+func TestPinDeviceCertificateRejectsUnverifiedCertificates(t *testing.T) {
+	useDeviceCertIdentity(t, "cn")
+	ca := testca.New(t, "Device CA")
+	unknownCA := testca.New(t, "Unknown CA")
+	useClientCA(t, ca)
+
+	device := &models.Device{ID: 1, DeviceID: "sensor-001"}
+	expired := ca.Issue(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "sensor-001"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		NotBefore:   time.Now().Add(-48 * time.Hour),
+		NotAfter:    time.Now().Add(-24 * time.Hour),
+	}).Leaf
+	serverOnly := ca.Issue(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "sensor-001"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}).Leaf
+
+	tests := []struct {
+		name string
+		pem  string
+		want error
+	}{
+		{"not PEM", "not a certificate", ErrInvalidCertificate},
+		{"unknown CA", testca.CertificatePEM(deviceCert(unknownCA, "sensor-001")), ErrInvalidCertificate},
+		{"expired", testca.CertificatePEM(expired), ErrInvalidCertificate},
+		{"server certificate", testca.CertificatePEM(serverOnly), ErrInvalidCertificate},
+		{"other device", testca.CertificatePEM(deviceCert(ca, "sensor-002")), ErrCertificateIdentityMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := PinDeviceCertificate(device, tt.pem); !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestVerifyClientCertificateAcceptsDeviceCertificate(t *testing.T) {
+	ca := testca.New(t, "Device CA")
+	useClientCA(t, ca)
+
+	if err := verifyClientCertificate(deviceCert(ca, "sensor-001")); err != nil {
+		t.Fatalf("expected the certificate to verify, got %v", err)
+	}
+}
+
+func TestDeviceIDFromCertificate(t *testing.T) {
+	ca := testca.New(t, "Device CA")
+	withSAN := ca.Issue(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Sensor"},
+		DNSNames:    []string{"sensor-001"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}).Leaf
+
+	useDeviceCertIdentity(t, "cn")
+	if id, err := DeviceIDFromCertificate(withSAN); err != nil || id != "Sensor" {
+		t.Fatalf("expected the common name, got %q, %v", id, err)
+	}
+
+	useDeviceCertIdentity(t, "san")
+	if id, err := DeviceIDFromCertificate(withSAN); err != nil || id != "sensor-001" {
+		t.Fatalf("expected the DNS name, got %q, %v", id, err)
+	}
+	if _, err := DeviceIDFromCertificate(deviceCert(ca, "sensor-001")); !errors.Is(err, ErrInvalidCertificate) {
+		t.Fatalf("expected a certificate without a DNS name to be rejected, got %v", err)
+	}
+}
+
+func TestFindPinnedCertificate(t *testing.T) {
+	ca := testca.New(t, "Device CA")
+	pinnedCert := deviceCert(ca, "sensor-001")
+	// The CA can issue any number of certificates for the same device; only the pinned one may log in
+	reissued := deviceCert(ca, "sensor-001")
+	revokedCert := deviceCert(ca, "sensor-001")
+
+	revokedAt := time.Now()
+	certificates := []models.DeviceCertificate{
+		{ID: 1, DeviceID: 1, Fingerprint: utils.CertificateFingerprint(pinnedCert)},
+		{ID: 2, DeviceID: 1, Fingerprint: utils.CertificateFingerprint(revokedCert), RevokedAt: &revokedAt},
+	}
+
+	if pinned := findPinnedCertificate(pinnedCert, certificates); pinned == nil || pinned.ID != 1 {
+		t.Fatalf("expected the pinned certificate to match, got %+v", pinned)
+	}
+	if pinned := findPinnedCertificate(reissued, certificates); pinned != nil {
+		t.Fatalf("expected an unpinned certificate of the same device to be rejected, got %+v", pinned)
+	}
+	if pinned := findPinnedCertificate(revokedCert, certificates); pinned != nil {
+		t.Fatalf("expected a revoked certificate to be rejected, got %+v", pinned)
+	}
+}
@@ -0,0 +1,242 @@
+// services/device_key_service.go
+package services
+
+import (
+	"crypto/subtle"
+	"dashboard-starter/config"
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrKeyRotationPending is returned when a rotation is started while another one is still pending
+	ErrKeyRotationPending = errors.New("a key rotation is already pending; complete or cancel it first")
+	// ErrNoKeyRotation is returned when completing or cancelling a rotation that is not pending
+	ErrNoKeyRotation = errors.New("no key rotation is pending for this device")
+)
+
+// deviceKeyPrefixLength is how many leading characters of an API key are stored in clear
+const deviceKeyPrefixLength = 8
+
+// DeviceKeyMatch tells which of a device's API keys was presented
+type DeviceKeyMatch int
+
+const (
+	DeviceKeyInvalid  DeviceKeyMatch = iota // Matches no key
+	DeviceKeyCurrent                        // Matches the current key
+	DeviceKeyPrevious                       // Matches the old key of a pending rotation
+)
+
+// NewDeviceApiKey generates a random API key and sets its hash and prefix on the device,
+// replacing the current key immediately and dropping any pending rotation.
+// The raw key is returned to be shown once; it is not stored.
+func NewDeviceApiKey(device *models.Device) (string, error) {
+	key, err := utils.GenerateSecureToken(24)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := utils.HashSecret(key)
+	if err != nil {
+		return "", err
+	}
+
+	device.ApiKey = ""
+	device.ApiKeyHash = hash
+	device.ApiKeyPrefix = key[:deviceKeyPrefixLength]
+	clearPreviousKey(device)
+	return key, nil
+}
+
+// VerifyDeviceApiKey checks an API key against the device's current key and, during a rotation,
+// its old key, in constant time. A device that still has a plaintext key from before hashing was
+// introduced gets the key hashed and the plaintext cleared on its first successful check.
+func VerifyDeviceApiKey(device *models.Device, key string) (DeviceKeyMatch, error) {
+	if device.ApiKeyHash != "" {
+		if utils.CompareSecretHash(key, device.ApiKeyHash) {
+			return DeviceKeyCurrent, nil
+		}
+		if IsKeyRotationPending(device) && utils.CompareSecretHash(key, device.PreviousApiKeyHash) {
+			return DeviceKeyPrevious, nil
+		}
+		return DeviceKeyInvalid, nil
+	}
+
+	if device.ApiKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(device.ApiKey)) != 1 {
+		return DeviceKeyInvalid, nil
+	}
+
+	hash, err := utils.HashSecret(key)
+	if err != nil {
+		return DeviceKeyInvalid, err
+	}
+
+	device.ApiKey = ""
+	device.ApiKeyHash = hash
+	device.ApiKeyPrefix = keyPrefix(key)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return tx.Model(device).Select("api_key", "api_key_hash", "api_key_prefix").Updates(device).Error
+	})
+	if err != nil {
+		return DeviceKeyInvalid, err
+	}
+
+	utils.Info("Migrated plaintext API key of device %s to hashed storage", device.DeviceID)
+	return DeviceKeyCurrent, nil
+}
+
+// IsKeyRotationPending reports whether the device's old API key is still accepted
+func IsKeyRotationPending(device *models.Device) bool {
+	return device.PreviousApiKeyHash != "" &&
+		device.PreviousKeyExpiresAt != nil &&
+		time.Now().Before(*device.PreviousKeyExpiresAt)
+}
+
+// RotateDeviceApiKey issues a new API key while the current one keeps working for the grace period.
+// Existing tokens stay valid so devices can switch keys on their own schedule.
+func RotateDeviceApiKey(device *models.Device, grace time.Duration) (string, error) {
+	if grace <= 0 {
+		grace = time.Duration(config.Config.Security.DeviceKeyGraceHours) * time.Hour
+	}
+
+	var key string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := lockDeviceKeys(tx, device); err != nil {
+			return err
+		}
+		if IsKeyRotationPending(device) {
+			return ErrKeyRotationPending
+		}
+
+		// Keep the old key, hashing it first if it is a legacy plaintext key
+		previousHash, previousPrefix := device.ApiKeyHash, device.ApiKeyPrefix
+		if previousHash == "" && device.ApiKey != "" {
+			hash, err := utils.HashSecret(device.ApiKey)
+			if err != nil {
+				return err
+			}
+			previousHash, previousPrefix = hash, keyPrefix(device.ApiKey)
+		}
+
+		var err error
+		if key, err = NewDeviceApiKey(device); err != nil {
+			return err
+		}
+
+		if previousHash != "" {
+			expiresAt := time.Now().Add(grace)
+			device.PreviousApiKeyHash = previousHash
+			device.PreviousApiKeyPrefix = previousPrefix
+			device.PreviousKeyExpiresAt = &expiresAt
+		}
+
+		return saveDeviceKeys(tx, device)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// ResetDeviceApiKey replaces the device's API key immediately, drops any pending rotation and signs
+// the device out: its access tokens are invalidated through the token version and its refresh tokens revoked
+func ResetDeviceApiKey(device *models.Device) (string, error) {
+	var key string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := lockDeviceKeys(tx, device); err != nil {
+			return err
+		}
+
+		var err error
+		if key, err = NewDeviceApiKey(device); err != nil {
+			return err
+		}
+		if err := saveDeviceKeys(tx, device); err != nil {
+			return err
+		}
+
+		device.TokenVersion += 1
+		if err := tx.Model(device).Update("token_version", device.TokenVersion).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND user_type = ? AND is_revoked = ?", device.ID, "device", false).
+			Updates(revokedColumns(models.RefreshTokenRevoked)).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// CompleteDeviceKeyRotation stops accepting the old key before the grace period ends
+func CompleteDeviceKeyRotation(device *models.Device) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := lockDeviceKeys(tx, device); err != nil {
+			return err
+		}
+		if !IsKeyRotationPending(device) {
+			return ErrNoKeyRotation
+		}
+
+		clearPreviousKey(device)
+		return saveDeviceKeys(tx, device)
+	})
+}
+
+// CancelDeviceKeyRotation discards the new key and makes the old key the current key again
+func CancelDeviceKeyRotation(device *models.Device) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := lockDeviceKeys(tx, device); err != nil {
+			return err
+		}
+		if !IsKeyRotationPending(device) {
+			return ErrNoKeyRotation
+		}
+
+		device.ApiKeyHash = device.PreviousApiKeyHash
+		device.ApiKeyPrefix = device.PreviousApiKeyPrefix
+		clearPreviousKey(device)
+		return saveDeviceKeys(tx, device)
+	})
+}
+
+// lockDeviceKeys reloads a device with SELECT ... FOR UPDATE so concurrent rotations of the same
+// device are serialized and each one decides on the keys as they are committed
+func lockDeviceKeys(tx *gorm.DB, device *models.Device) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", device.ID).
+		First(device).Error
+}
+
+// saveDeviceKeys writes only the key columns of a device
+func saveDeviceKeys(tx *gorm.DB, device *models.Device) error {
+	return tx.Model(device).
+		Select("api_key", "api_key_hash", "api_key_prefix", "previous_api_key_hash", "previous_api_key_prefix", "previous_key_expires_at").
+		Updates(device).Error
+}
+
+// clearPreviousKey forgets the old key of a rotation
+func clearPreviousKey(device *models.Device) {
+	device.PreviousApiKeyHash = ""
+	device.PreviousApiKeyPrefix = ""
+	device.PreviousKeyExpiresAt = nil
+}
+
+// keyPrefix returns the part of a key that is stored in clear
+func keyPrefix(key string) string {
+	if len(key) > deviceKeyPrefixLength {
+		return key[:deviceKeyPrefixLength]
+	}
+	return key
+}
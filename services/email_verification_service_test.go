@@ -0,0 +1,48 @@
+package services
+
+import (
+	"dashboard-starter/config"
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendVerificationEmail(t *testing.T) {
+	config.Config.App.Name = "Dashboard"
+	config.Config.App.FrontendURL = "https://app.example.com"
+	config.Config.Security.EmailVerificationHours = 24
+	config.Config.JWT = config.JWTConfig{Secret: "test-secret", Algorithm: "HS256"}
+	if err := utils.InitJWT(); err != nil {
+		t.Fatalf("InitJWT: %v", err)
+	}
+	mailer := useCaptureMailer(t)
+
+	user := &models.User{ID: 42, Name: "Somchai", Email: "somchai@example.com"}
+	token, err := utils.GenerateEmailVerificationToken(user.ID, user.Email, time.Hour)
+	if err != nil {
+		t.Fatalf("GenerateEmailVerificationToken: %v", err)
+	}
+	sendVerificationEmail(user, token)
+
+	msg := mailer.next(t)
+	if msg.To != user.Email {
+		t.Errorf("To = %q, want %q", msg.To, user.Email)
+	}
+	if msg.Subject != "Verify your Dashboard email address" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+	if !strings.Contains(msg.Body, "expires in 24 hours") {
+		t.Errorf("body does not state the expiry:\n%s", msg.Body)
+	}
+
+	// The emailed link must carry a token that verifies this user's current address
+	userID, email, err := utils.ParseEmailVerificationToken(mailLinkToken(t, msg.Body, "/verify-email"))
+	if err != nil {
+		t.Fatalf("emailed token does not parse: %v", err)
+	}
+	if userID != user.ID || email != user.Email {
+		t.Errorf("emailed token is for %d/%q, want %d/%q", userID, email, user.ID, user.Email)
+	}
+}
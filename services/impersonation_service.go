@@ -0,0 +1,183 @@
+// services/impersonation_service.go
+package services
+
+import (
+	"dashboard-starter/config"
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrImpersonationEnded is returned for impersonation tokens whose session was stopped or whose admin lost access
+	ErrImpersonationEnded = errors.New("impersonation session has ended")
+	// ErrImpersonationNotFound is returned when stopping an unknown or already ended session
+	ErrImpersonationNotFound = errors.New("active impersonation session not found")
+)
+
+// Values of Impersonation.EndedBy
+const (
+	ImpersonationEndedByImpersonator = "impersonator"
+	ImpersonationEndedByAdmin        = "admin"
+)
+
+// StartImpersonation records that an admin is signing in as a user and returns a short-lived
+// access token for the user carrying the admin as actor. No refresh token is issued.
+func StartImpersonation(adminID, userID uint, reason string, client models.SessionClient) (*models.Impersonation, string, time.Time, error) {
+	var user models.User
+	if err := db.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", time.Time{}, ErrPrincipalNotFound
+		}
+		return nil, "", time.Time{}, err
+	}
+
+	sessionID, err := utils.GenerateSecureToken(16)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	ttl := time.Duration(config.Config.Security.ImpersonationMinutes) * time.Minute
+	token, expiresAt, err := utils.GenerateImpersonationToken(user.ID, user.TokenVersion,
+		utils.TokenActor{UserID: adminID, UserType: "admin"}, sessionID, ttl)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	impersonation := &models.Impersonation{
+		SessionID: sessionID,
+		AdminID:   adminID,
+		UserID:    user.ID,
+		Reason:    reason,
+		IPAddress: client.IPAddress,
+		UserAgent: truncateRunes(client.UserAgent, 255),
+		ExpiresAt: expiresAt,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(impersonation).Error
+	})
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	utils.Info("Admin %d started impersonating user %d (session %d): %s", adminID, user.ID, impersonation.ID, reason)
+	impersonation.Status = impersonationStatus(impersonation)
+	return impersonation, token, expiresAt, nil
+}
+
+// CheckImpersonation verifies that the session of an impersonation token is still running
+// and that the impersonating admin still exists and is enabled
+func CheckImpersonation(claims *utils.AccessTokenClaims) error {
+	if claims.Actor == nil {
+		return nil
+	}
+	if claims.Actor.UserType != "admin" || claims.UserType != "user" {
+		return ErrImpersonationEnded
+	}
+
+	var count int64
+	err := db.DB.Model(&models.Impersonation{}).
+		Where("session_id = ? AND admin_id = ? AND user_id = ? AND ended_at IS NULL AND expires_at > ?",
+			claims.TokenID, claims.Actor.UserID, claims.UserID, time.Now()).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrImpersonationEnded
+	}
+
+	if err := CheckPrincipal(claims.Actor.UserID, "admin"); err != nil {
+		if errors.Is(err, ErrPrincipalNotFound) || errors.Is(err, ErrAccountDisabled) {
+			return ErrImpersonationEnded
+		}
+		return err
+	}
+	return nil
+}
+
+// StopImpersonationSession ends the session of the impersonation token in use
+func StopImpersonationSession(sessionID string) error {
+	return endImpersonation("session_id = ?", sessionID, ImpersonationEndedByImpersonator)
+}
+
+// StopImpersonation lets an admin end any running impersonation
+func StopImpersonation(id uint) error {
+	return endImpersonation("id = ?", id, ImpersonationEndedByAdmin)
+}
+
+// endImpersonation marks the running session matching the condition as ended
+func endImpersonation(condition string, value interface{}, endedBy string) error {
+	var impersonation models.Impersonation
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(condition, value).Where("ended_at IS NULL AND expires_at > ?", time.Now()).
+			First(&impersonation).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrImpersonationNotFound
+			}
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&models.Impersonation{}).
+			Where("id = ? AND ended_at IS NULL", impersonation.ID).
+			Updates(map[string]interface{}{"ended_at": now, "ended_by": endedBy})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrImpersonationNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	utils.Info("Impersonation session %d of admin %d as user %d ended by %s",
+		impersonation.ID, impersonation.AdminID, impersonation.UserID, endedBy)
+	return nil
+}
+
+// ListImpersonations returns the impersonation audit trail, newest first.
+// params.Status filters by "active", "ended" or "expired".
+func ListImpersonations(params utils.PaginationParams) ([]models.Impersonation, *utils.PaginationResult, error) {
+	var impersonations []models.Impersonation
+
+	query := db.DB.Model(&models.Impersonation{})
+	now := time.Now()
+	switch params.Status {
+	case "active":
+		query = query.Where("ended_at IS NULL AND expires_at > ?", now)
+	case "ended":
+		query = query.Where("ended_at IS NOT NULL")
+	case "expired":
+		query = query.Where("ended_at IS NULL AND expires_at <= ?", now)
+	}
+
+	result, err := utils.ApplyPagination(query, params, &impersonations)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range impersonations {
+		impersonations[i].Status = impersonationStatus(&impersonations[i])
+	}
+	return impersonations, result, nil
+}
+
+// impersonationStatus describes whether a session is still running
+func impersonationStatus(impersonation *models.Impersonation) string {
+	switch {
+	case impersonation.EndedAt != nil:
+		return "ended"
+	case time.Now().After(impersonation.ExpiresAt):
+		return "expired"
+	default:
+		return "active"
+	}
+}
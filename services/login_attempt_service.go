@@ -0,0 +1,153 @@
+// services/login_attempt_service.go
+package services
+
+import (
+	"dashboard-starter/config"
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// normalizeLoginIdentifier makes "Admin@Example.com " and "admin@example.com" share one counter.
+// Device IDs are case-sensitive, so only surrounding whitespace is removed from them.
+func normalizeLoginIdentifier(userType, identifier string) string {
+	identifier = strings.TrimSpace(identifier)
+	if userType == "device" {
+		return identifier
+	}
+	return strings.ToLower(identifier)
+}
+
+// loginDelay returns the wait required after the given number of consecutive failures.
+// The first LoginFreeAttempts failures are free, after that the delay doubles up to LoginDelayMaxSeconds.
+func loginDelay(failedCount int) time.Duration {
+	cfg := config.Config.Security
+
+	over := failedCount - cfg.LoginFreeAttempts
+	if over <= 0 || cfg.LoginDelayBaseSeconds <= 0 {
+		return 0
+	}
+
+	delay := time.Duration(cfg.LoginDelayBaseSeconds) * time.Second
+	maxDelay := time.Duration(cfg.LoginDelayMaxSeconds) * time.Second
+	for i := 1; i < over && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+// isLoginAttemptStale reports whether the failures of a record are outside the counting window
+func isLoginAttemptStale(attempt *models.LoginAttempt, now time.Time) bool {
+	window := time.Duration(config.Config.Security.LoginAttemptWindowMins) * time.Minute
+	return attempt.LastFailedAt == nil || (window > 0 && now.Sub(*attempt.LastFailedAt) > window)
+}
+
+// loginRetryAfter returns how long the identifier has to wait before the next login attempt
+func loginRetryAfter(attempt *models.LoginAttempt, now time.Time) time.Duration {
+	if attempt.LockedUntil != nil && now.Before(*attempt.LockedUntil) {
+		return attempt.LockedUntil.Sub(now)
+	}
+	if isLoginAttemptStale(attempt, now) {
+		return 0
+	}
+
+	next := attempt.LastFailedAt.Add(loginDelay(attempt.FailedCount))
+	if now.Before(next) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// LoginRetryAfter returns how long a login for the identifier must wait because of earlier failures.
+// Zero means the attempt may proceed.
+func LoginRetryAfter(userType, identifier string) (time.Duration, error) {
+	var attempt models.LoginAttempt
+	err := db.DB.Where("user_type = ? AND identifier = ?", userType, normalizeLoginIdentifier(userType, identifier)).
+		First(&attempt).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return loginRetryAfter(&attempt, time.Now()), nil
+}
+
+// RecordLoginFailure counts a failed login and locks the identifier once the threshold is reached.
+// It is called for unknown identifiers as well so the behaviour is the same for every email.
+func RecordLoginFailure(userType, identifier string) error {
+	identifier = normalizeLoginIdentifier(userType, identifier)
+	cfg := config.Config.Security
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.LoginAttempt{UserType: userType, Identifier: identifier}).Error; err != nil {
+			return err
+		}
+
+		// Row lock so concurrent failures from several instances are all counted
+		var attempt models.LoginAttempt
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_type = ? AND identifier = ?", userType, identifier).
+			First(&attempt).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		// Start counting again after an expired lock or a long quiet period
+		lockExpired := attempt.LockedUntil != nil && !now.Before(*attempt.LockedUntil)
+		if lockExpired || isLoginAttemptStale(&attempt, now) {
+			attempt.FailedCount = 0
+			attempt.LockedUntil = nil
+		}
+
+		attempt.FailedCount++
+		attempt.LastFailedAt = &now
+
+		if cfg.LoginLockThreshold > 0 && attempt.FailedCount >= cfg.LoginLockThreshold && attempt.LockedUntil == nil {
+			lockedUntil := now.Add(time.Duration(cfg.LoginLockMinutes) * time.Minute)
+			attempt.LockedUntil = &lockedUntil
+			utils.Warn("Login for %s %q locked until %s after %d failed attempts",
+				userType, identifier, lockedUntil.Format(time.RFC3339), attempt.FailedCount)
+		}
+
+		return tx.Save(&attempt).Error
+	})
+}
+
+// ResetLoginFailures clears the failure counter after a successful password check
+func ResetLoginFailures(userType, identifier string) error {
+	return db.DB.Where("user_type = ? AND identifier = ?", userType, normalizeLoginIdentifier(userType, identifier)).
+		Delete(&models.LoginAttempt{}).Error
+}
+
+// UnlockLogin removes a lock and the failure counter for an identifier (admin action).
+// It returns false if there was nothing to unlock.
+func UnlockLogin(userType, identifier string) (bool, error) {
+	result := db.DB.Where("user_type = ? AND identifier = ?", userType, normalizeLoginIdentifier(userType, identifier)).
+		Delete(&models.LoginAttempt{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetLockedLogins returns identifiers that are currently locked
+func GetLockedLogins() ([]models.LoginAttempt, error) {
+	var attempts []models.LoginAttempt
+	err := db.DB.Where("locked_until > ?", time.Now()).
+		Order("locked_until DESC").
+		Find(&attempts).Error
+	return attempts, err
+}
@@ -0,0 +1,146 @@
+// services/magic_link_service.go
+package services
+
+import (
+	"dashboard-starter/config"
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidMagicLink is returned for unknown, used or expired login links
+var ErrInvalidMagicLink = errors.New("invalid or expired login link")
+
+// RequestMagicLink emails a single-use login link if the email belongs to a user.
+// It returns nil for unknown emails and for accounts over the hourly limit, so callers
+// cannot tell whether an account exists. The link is issued in the background, so the response
+// does not wait for it and takes as long as for an unknown email.
+func (s *UserService) RequestMagicLink(email string) error {
+	user, err := s.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	go func() {
+		if err := sendMagicLink(user); err != nil {
+			utils.Error("Failed to send login link to user %d: %v", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// sendMagicLink stores a new login link for the user, unless the hourly limit is reached, and emails it
+func sendMagicLink(user *models.User) error {
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Duration(config.Config.Security.MagicLinkMinutes) * time.Minute
+	limited := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		// Links are kept for a day so the per-account limit can be counted
+		if err := tx.Where("user_id = ? AND created_at < ?", user.ID, now.Add(-24*time.Hour)).
+			Delete(&models.MagicLinkToken{}).Error; err != nil {
+			return err
+		}
+
+		var sent int64
+		if err := tx.Model(&models.MagicLinkToken{}).
+			Where("user_id = ? AND created_at > ?", user.ID, now.Add(-time.Hour)).
+			Count(&sent).Error; err != nil {
+			return err
+		}
+		if sent >= int64(config.Config.Security.MagicLinkPerEmailPerHour) {
+			limited = true
+			return nil
+		}
+
+		// Only the latest link stays valid
+		if err := tx.Model(&models.MagicLinkToken{}).
+			Where("user_id = ? AND used_at IS NULL AND expires_at > ?", user.ID, now).
+			Update("expires_at", now).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.MagicLinkToken{
+			UserID:    user.ID,
+			TokenHash: utils.HashToken(token),
+			ExpiresAt: now.Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	if limited {
+		utils.Warn("Login link for user %d not sent: hourly limit reached", user.ID)
+		return nil
+	}
+
+	link := fmt.Sprintf("%s/magic-link?token=%s", config.Config.App.FrontendURL, url.QueryEscape(token))
+	return utils.SendMail(utils.MailMessage{
+		To:      user.Email,
+		Subject: config.Config.App.Name + " login link",
+		Body: fmt.Sprintf("Hello %s,\n\nOpen the link below to sign in:\n\n%s\n\n"+
+			"The link expires in %d minutes and can only be used once. "+
+			"If you did not request it you can ignore this email.\n",
+			user.Name, link, config.Config.Security.MagicLinkMinutes),
+	})
+}
+
+// ConsumeMagicLink uses up a login link and returns its user. Opening the link proves the user
+// controls the email address, so an unverified address is marked verified.
+func (s *UserService) ConsumeMagicLink(token string) (*models.User, error) {
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var link models.MagicLinkToken
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?",
+			utils.HashToken(token), time.Now()).First(&link).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidMagicLink
+			}
+			return err
+		}
+
+		// Conditional update so the link cannot be used twice concurrently
+		result := tx.Model(&models.MagicLinkToken{}).
+			Where("id = ? AND used_at IS NULL", link.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidMagicLink
+		}
+
+		if err := tx.First(&user, link.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidMagicLink
+			}
+			return err
+		}
+
+		if user.EmailVerifiedAt == nil {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+			return tx.Model(&user).Update("email_verified_at", now).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"dashboard-starter/utils"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// captureMailer records every message instead of sending it
+type captureMailer struct {
+	messages chan utils.MailMessage
+}
+
+func (m *captureMailer) Send(msg utils.MailMessage) error {
+	m.messages <- msg
+	return nil
+}
+
+// useCaptureMailer replaces the mailer for the duration of a test
+func useCaptureMailer(t *testing.T) *captureMailer {
+	t.Helper()
+
+	m := &captureMailer{messages: make(chan utils.MailMessage, 10)}
+	utils.SetMailer(m)
+	t.Cleanup(func() { utils.SetMailer(&utils.LogMailer{}) })
+	return m
+}
+
+// next waits for the next message, since services send mail in the background
+func (m *captureMailer) next(t *testing.T) utils.MailMessage {
+	t.Helper()
+
+	select {
+	case msg := <-m.messages:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("no mail was sent")
+		return utils.MailMessage{}
+	}
+}
+
+// mailLinkToken extracts the token query parameter of the first link to path in a mail body
+func mailLinkToken(t *testing.T, body, path string) string {
+	t.Helper()
+
+	link := regexp.MustCompile(`https?://\S+`).FindString(body)
+	if link == "" {
+		t.Fatalf("mail body contains no link:\n%s", body)
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("invalid link %q: %v", link, err)
+	}
+	if u.Path != path {
+		t.Fatalf("link path = %q, want %q", u.Path, path)
+	}
+	return u.Query().Get("token")
+}
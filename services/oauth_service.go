@@ -0,0 +1,246 @@
+// services/oauth_service.go
+package services
+
+import (
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidClient is returned when OAuth client authentication fails
+	ErrInvalidClient = errors.New("invalid client credentials")
+	// ErrOAuthClientNotFound is returned when an OAuth client does not exist
+	ErrOAuthClientNotFound = errors.New("OAuth client not found")
+	// ErrUnsupportedTokenType is returned when a token type cannot be revoked individually
+	ErrUnsupportedTokenType = errors.New("access tokens cannot be revoked individually; revoke the refresh token or sign the account out instead")
+	// ErrTokenNotOwned is returned when a client revokes a token that was not issued to it (RFC 7009 section 2.1)
+	ErrTokenNotOwned = errors.New("the token was not issued to this client")
+)
+
+// Token type identifiers used by introspection and revocation (RFC 7662, RFC 7009)
+const (
+	TokenTypeAccess   = "access_token"
+	TokenTypeRefresh  = "refresh_token"
+	TokenTypePersonal = "personal_access_token"
+)
+
+// CreateOAuthClient registers a client. The raw secret is only available on the returned struct.
+func CreateOAuthClient(input *models.OAuthClientInput) (*models.OAuthClient, error) {
+	clientID, err := utils.GenerateSecureToken(12)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &models.OAuthClient{
+		ClientID:   "cli_" + clientID,
+		SecretHash: utils.HashToken(secret),
+		Name:       input.Name,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(client).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client.Secret = secret
+	return client, nil
+}
+
+// ListOAuthClients returns every registered client
+func ListOAuthClients() ([]models.OAuthClient, error) {
+	var clients []models.OAuthClient
+	err := db.DB.Order("name").Find(&clients).Error
+	return clients, err
+}
+
+// DeleteOAuthClient removes a client so its credentials stop working
+func DeleteOAuthClient(id uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.OAuthClient{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOAuthClientNotFound
+		}
+		return nil
+	})
+}
+
+// AuthenticateOAuthClient checks client credentials in constant time
+func AuthenticateOAuthClient(clientID, secret string) (*models.OAuthClient, error) {
+	if clientID == "" || secret == "" {
+		return nil, ErrInvalidClient
+	}
+
+	var client models.OAuthClient
+	if err := db.DB.Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidClient
+		}
+		return nil, err
+	}
+
+	if !utils.CompareTokenHash(secret, client.SecretHash) {
+		return nil, ErrInvalidClient
+	}
+
+	now := time.Now()
+	if client.LastUsedAt == nil || now.Sub(*client.LastUsedAt) > time.Minute {
+		if err := db.DB.Model(&client).UpdateColumn("last_used_at", now).Error; err != nil {
+			utils.Warn("Failed to record use of OAuth client %s: %v", client.ClientID, err)
+		}
+	}
+
+	return &client, nil
+}
+
+// FindOAuthClient looks up a registered client by its public client ID, e.g. for the device authorization grant
+// where the client identifies itself without a secret
+func FindOAuthClient(clientID string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	if err := db.DB.Where("client_id = ?", clientID).First(&client).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidClient
+		}
+		return nil, err
+	}
+	return &client, nil
+}
+
+// IntrospectToken reports whether a token would be accepted by this API right now (RFC 7662).
+// Access tokens get the same checks as AuthMiddleware, refresh tokens the same checks as the refresh endpoint.
+// Introspection is read-only: it does not record the use of a personal access token.
+func IntrospectToken(token, hint string) models.TokenIntrospection {
+	inactive := models.TokenIntrospection{Active: false}
+
+	if strings.HasPrefix(token, models.PersonalAccessTokenPrefix) {
+		pat, err := findActivePersonalAccessToken(token)
+		if err != nil || CheckPrincipal(pat.UserID, pat.UserType) != nil {
+			return inactive
+		}
+
+		result := activeIntrospection(TokenTypePersonal, pat.UserID, pat.UserType, pat.CreatedAt)
+		result.Scope = strings.Join(pat.Scopes, " ")
+		if pat.ExpiresAt != nil {
+			result.ExpiresAt = pat.ExpiresAt.Unix()
+		}
+		return result
+	}
+
+	// The hint only decides which type is tried first
+	if hint == TokenTypeRefresh {
+		if result, ok := introspectRefreshToken(token); ok {
+			return result
+		}
+		if result, ok := introspectAccessToken(token); ok {
+			return result
+		}
+		return inactive
+	}
+
+	if result, ok := introspectAccessToken(token); ok {
+		return result
+	}
+	if result, ok := introspectRefreshToken(token); ok {
+		return result
+	}
+	return inactive
+}
+
+// introspectAccessToken validates an access token like AuthMiddleware does
+func introspectAccessToken(token string) (models.TokenIntrospection, bool) {
+	claims, err := utils.ParseAccessToken(token)
+	if err != nil {
+		return models.TokenIntrospection{}, false
+	}
+
+	if err := CheckAccessToken(claims.UserID, claims.UserType, claims.TokenVersion, claims.SessionID); err != nil {
+		return models.TokenIntrospection{}, false
+	}
+	if err := CheckImpersonation(claims); err != nil {
+		return models.TokenIntrospection{}, false
+	}
+
+	result := activeIntrospection(TokenTypeAccess, claims.UserID, claims.UserType, claims.IssuedAt)
+	result.ExpiresAt = claims.ExpiresAt.Unix()
+	if claims.Actor != nil {
+		result.Act = &models.TokenActorInfo{UserID: claims.Actor.UserID, UserType: claims.Actor.UserType}
+	}
+	return result, true
+}
+
+// introspectRefreshToken validates a refresh token like the refresh endpoint does
+func introspectRefreshToken(token string) (models.TokenIntrospection, bool) {
+	refreshToken, err := ValidateRefreshToken(token)
+	if err != nil {
+		return models.TokenIntrospection{}, false
+	}
+
+	if err := CheckPrincipal(refreshToken.UserID, refreshToken.UserType); err != nil {
+		return models.TokenIntrospection{}, false
+	}
+
+	result := activeIntrospection(TokenTypeRefresh, refreshToken.UserID, refreshToken.UserType, refreshToken.CreatedAt)
+	result.ExpiresAt = refreshToken.ExpiresAt.Unix()
+	return result, true
+}
+
+// activeIntrospection fills the fields shared by every active token
+func activeIntrospection(tokenType string, userID uint, userType string, issuedAt time.Time) models.TokenIntrospection {
+	return models.TokenIntrospection{
+		Active:    true,
+		TokenType: tokenType,
+		Subject:   userType + ":" + strconv.FormatUint(uint64(userID), 10),
+		UserID:    userID,
+		UserType:  userType,
+		IssuedAt:  issuedAt.Unix(),
+	}
+}
+
+// RevokeToken revokes a refresh token (and the rest of its session) issued to the calling client (RFC 7009).
+// Unknown and already invalid tokens are ignored, as the RFC requires. Tokens issued to another client or
+// to a first-party login, and personal access tokens, which are never issued to a client, return ErrTokenNotOwned.
+func RevokeToken(token string, client *models.OAuthClient) error {
+	if strings.HasPrefix(token, models.PersonalAccessTokenPrefix) {
+		var count int64
+		if err := db.DB.Model(&models.PersonalAccessToken{}).
+			Where("token_hash = ?", utils.HashToken(token)).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrTokenNotOwned
+		}
+		return nil
+	}
+
+	if refreshToken, err := findRefreshToken(token); err == nil {
+		if refreshToken.ClientID != client.ClientID {
+			return ErrTokenNotOwned
+		}
+		if refreshToken.FamilyID == "" {
+			return RevokeRefreshToken(token)
+		}
+		return RevokeRefreshTokenFamily(refreshToken.FamilyID)
+	}
+
+	if _, err := utils.ParseAccessToken(token); err == nil {
+		return ErrUnsupportedTokenType
+	}
+
+	return nil
+}
@@ -0,0 +1,511 @@
+// services/oidc_service.go
+package services
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"dashboard-starter/config"
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUnknownOIDCProvider is returned when the :provider path parameter is not configured
+	ErrUnknownOIDCProvider = errors.New("unknown OIDC provider")
+	// ErrInvalidOIDCState is returned for unknown, used or expired state parameters
+	ErrInvalidOIDCState = errors.New("invalid or expired OIDC state")
+	// ErrOIDCStateNotBound is returned when the callback comes from a browser that did not start the login
+	ErrOIDCStateNotBound = errors.New("the OIDC login was not started by this browser")
+	// ErrOIDCCodeRejected is returned when the token endpoint refuses the authorization code
+	ErrOIDCCodeRejected = errors.New("the identity provider rejected the authorization code")
+	// ErrInvalidIDToken is returned when the provider's ID token fails validation
+	ErrInvalidIDToken = errors.New("invalid ID token")
+	// ErrOIDCEmailNotVerified is returned when a new identity has no verified email to link by
+	ErrOIDCEmailNotVerified = errors.New("the provider did not return a verified email address")
+	// ErrOIDCAccountUnverified is returned when the email belongs to a local account that was never verified.
+	// Linking it would hand the account to whoever registered the address first.
+	ErrOIDCAccountUnverified = errors.New("an account with this email exists but has not been verified; sign in with your password and verify your email first")
+)
+
+const (
+	// oidcDiscoveryTTL is how long a discovery document is cached
+	oidcDiscoveryTTL = time.Hour
+	// oidcJWKSRefreshInterval limits refetching the provider's keys when a token has an unknown kid
+	oidcJWKSRefreshInterval = time.Minute
+)
+
+// oidcHTTPClient is used for every request to an identity provider
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// oidcDiscovery is the part of the provider's discovery document we use
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcProviderState caches the discovery document and signing keys of one provider
+type oidcProviderState struct {
+	mu            sync.Mutex
+	discovery     *oidcDiscovery
+	discoveredAt  time.Time
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+var (
+	oidcStatesMu sync.Mutex
+	oidcStates   = map[string]*oidcProviderState{}
+)
+
+// oidcClaims are the ID token claims used to find or create the user
+type oidcClaims struct {
+	jwt.RegisteredClaims
+	AuthorizedParty string      `json:"azp"`
+	Nonce           string      `json:"nonce"`
+	Email           string      `json:"email"`
+	EmailVerified   interface{} `json:"email_verified"` // Some providers send "true" as a string
+	Name            string      `json:"name"`
+}
+
+// emailVerified reports whether the provider vouches for the email claim
+func (c *oidcClaims) emailVerified() bool {
+	switch v := c.EmailVerified.(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
+
+// IsOIDCProviderConfigured reports whether users can sign in with the named provider
+func IsOIDCProviderConfigured(provider string) bool {
+	_, ok := config.Config.OIDC.Providers[provider]
+	return ok
+}
+
+// OIDCAuthorizationURL starts an authorization-code flow with PKCE and returns the URL
+// the browser should be sent to together with the state. The nonce and code verifier are kept
+// server side; the caller binds the state to the browser so the callback can be checked with it.
+func OIDCAuthorizationURL(provider string) (string, string, error) {
+	cfg, ok := config.Config.OIDC.Providers[provider]
+	if !ok {
+		return "", "", ErrUnknownOIDCProvider
+	}
+
+	discovery, err := getOIDCDiscovery(provider, cfg)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	ttl := time.Duration(config.Config.OIDC.StateMinutes) * time.Minute
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Abandoned flows are cleaned up whenever a new one starts
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.OIDCState{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.OIDCState{
+			StateHash:    utils.HashToken(state),
+			Provider:     provider,
+			Nonce:        nonce,
+			CodeVerifier: verifier,
+			ExpiresAt:    time.Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return buildOIDCAuthorizationURL(cfg, discovery, state, nonce, verifier), state, nil
+}
+
+// buildOIDCAuthorizationURL returns the authorization request URL with an S256 code challenge for the verifier
+func buildOIDCAuthorizationURL(cfg config.OIDCProviderConfig, discovery *oidcDiscovery, state, nonce, verifier string) string {
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {cfg.ClientID},
+		"redirect_uri":          {cfg.RedirectURL},
+		"scope":                 {strings.Join(cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + params.Encode()
+}
+
+// CompleteOIDCLogin exchanges the authorization code, validates the ID token and returns
+// the linked user, creating one if no account uses the verified email yet.
+// boundState is the state remembered by the browser that started the login; it must match state.
+func CompleteOIDCLogin(provider, code, state, boundState string) (*models.User, error) {
+	cfg, ok := config.Config.OIDC.Providers[provider]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+
+	// Rejects login CSRF: a callback with a state this browser never received
+	if boundState == "" || subtle.ConstantTimeCompare([]byte(state), []byte(boundState)) != 1 {
+		return nil, ErrOIDCStateNotBound
+	}
+
+	pending, err := consumeOIDCState(provider, state)
+	if err != nil {
+		return nil, err
+	}
+
+	discovery, err := getOIDCDiscovery(provider, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, err := exchangeOIDCCode(cfg, discovery, code, pending.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := validateIDToken(provider, cfg, discovery, rawIDToken, pending.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return linkOIDCUser(provider, claims)
+}
+
+// consumeOIDCState deletes and returns the pending request for a state so it can only be used once
+func consumeOIDCState(provider, state string) (*models.OIDCState, error) {
+	var pending models.OIDCState
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state_hash = ? AND provider = ?", utils.HashToken(state), provider).
+			First(&pending).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidOIDCState
+			}
+			return err
+		}
+
+		result := tx.Delete(&pending)
+		if result.Error != nil {
+			return result.Error
+		}
+		// Another request consumed the same state first
+		if result.RowsAffected == 0 {
+			return ErrInvalidOIDCState
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Now().After(pending.ExpiresAt) {
+		return nil, ErrInvalidOIDCState
+	}
+	return &pending, nil
+}
+
+// exchangeOIDCCode redeems the authorization code at the token endpoint and returns the raw ID token
+func exchangeOIDCCode(cfg config.OIDCProviderConfig, discovery *oidcDiscovery, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.RedirectURL},
+		"client_id":     {cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// Public clients rely on PKCE alone; confidential clients use client_secret_basic
+	if cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	}
+
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("%w: status %d %s %s", ErrOIDCCodeRejected, resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", fmt.Errorf("%w: token response has no id_token", ErrInvalidIDToken)
+	}
+
+	return body.IDToken, nil
+}
+
+// validateIDToken checks the signature, issuer, audience, expiry and nonce of an ID token
+func validateIDToken(provider string, cfg config.OIDCProviderConfig, discovery *oidcDiscovery, rawIDToken, nonce string) (*oidcClaims, error) {
+	claims := &oidcClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return getOIDCKey(provider, discovery, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != cfg.ClientID {
+		return nil, fmt.Errorf("%w: token was issued to another party", ErrInvalidIDToken)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+// linkOIDCUser finds the user linked to the provider subject, links an existing user with the
+// same verified email, or creates a new user
+func linkOIDCUser(provider string, claims *oidcClaims) (*models.User, error) {
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var identity models.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", provider, claims.Subject).First(&identity).Error
+		if err == nil {
+			err = tx.First(&user, identity.UserID).Error
+			if err == nil {
+				return tx.Model(&identity).Update("email", claims.Email).Error
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			// The linked user was deleted; treat this as a first login
+			if err := tx.Delete(&identity).Error; err != nil {
+				return err
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if claims.Email == "" || !claims.emailVerified() {
+			return ErrOIDCEmailNotVerified
+		}
+
+		err = tx.Where("email = ?", claims.Email).First(&user).Error
+		switch {
+		case err == nil:
+			if user.EmailVerifiedAt == nil {
+				return ErrOIDCAccountUnverified
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := createOIDCUser(tx, &user, claims); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		return tx.Create(&models.UserIdentity{
+			UserID:   user.ID,
+			Provider: provider,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// createOIDCUser creates a user for a first-time OIDC login.
+// The random password is never shown; the user can set one through the forgot-password flow.
+func createOIDCUser(tx *gorm.DB, user *models.User, claims *oidcClaims) error {
+	password, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	name := strings.TrimSpace(claims.Name)
+	if name == "" {
+		name = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+
+	now := time.Now()
+	*user = models.User{
+		Name:            truncateRunes(name, 255),
+		Email:           claims.Email,
+		Password:        string(hashedPassword),
+		TokenVersion:    1,
+		LastLogin:       now,
+		EmailVerifiedAt: &now, // Verified by the provider
+	}
+	return tx.Create(user).Error
+}
+
+// oidcProviderCache returns the cache entry of a provider, creating it on first use
+func oidcProviderCache(provider string) *oidcProviderState {
+	oidcStatesMu.Lock()
+	defer oidcStatesMu.Unlock()
+
+	state, ok := oidcStates[provider]
+	if !ok {
+		state = &oidcProviderState{}
+		oidcStates[provider] = state
+	}
+	return state
+}
+
+// getOIDCDiscovery returns the provider's discovery document, fetching it when the cache is stale
+func getOIDCDiscovery(provider string, cfg config.OIDCProviderConfig) (*oidcDiscovery, error) {
+	state := oidcProviderCache(provider)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if state.discovery != nil && time.Since(state.discoveredAt) < oidcDiscoveryTTL {
+		return state.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := fetchOIDCJSON(cfg.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("failed to load OIDC discovery document: %w", err)
+	}
+
+	// The document must describe the issuer we were configured with
+	if strings.TrimRight(discovery.Issuer, "/") != cfg.Issuer {
+		return nil, fmt.Errorf("OIDC discovery issuer %q does not match %q", discovery.Issuer, cfg.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is missing required endpoints")
+	}
+
+	state.discovery = &discovery
+	state.discoveredAt = time.Now()
+	return state.discovery, nil
+}
+
+// getOIDCKey returns the provider's public key for kid, refetching the key set once per
+// oidcJWKSRefreshInterval so rotated keys are picked up
+func getOIDCKey(provider string, discovery *oidcDiscovery, kid string) (crypto.PublicKey, error) {
+	state := oidcProviderCache(provider)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if key, ok := lookupOIDCKey(state.keys, kid); ok {
+		return key, nil
+	}
+
+	if time.Since(state.keysFetchedAt) < oidcJWKSRefreshInterval {
+		return nil, errors.New("unknown signing key")
+	}
+
+	var set utils.JWKSet
+	if err := fetchOIDCJSON(discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to load OIDC signing keys: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			utils.Warn("Skipping OIDC key %q of provider %s: %v", jwk.Kid, provider, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	state.keys = keys
+	state.keysFetchedAt = time.Now()
+
+	if key, ok := lookupOIDCKey(state.keys, kid); ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// lookupOIDCKey finds a key by kid. Tokens without a kid are accepted only if the set has a single key.
+func lookupOIDCKey(keys map[string]crypto.PublicKey, kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	key, ok := keys[kid]
+	return key, ok
+}
+
+// fetchOIDCJSON GETs a JSON document from an identity provider
+func fetchOIDCJSON(endpoint string, target interface{}) error {
+	resp, err := oidcHTTPClient.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(target)
+}
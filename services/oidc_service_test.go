@@ -0,0 +1,334 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"dashboard-starter/config"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockIdP is an in-process OpenID Connect provider that issues codes, checks PKCE
+// at its token endpoint and signs ID tokens with a key it can rotate
+type mockIdP struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu    sync.Mutex
+	key   *rsa.PrivateKey
+	kid   string
+	codes map[string]mockIdPGrant
+	// claims overrides the ID token claims of the next token response
+	claims jwt.MapClaims
+}
+
+// mockIdPGrant is an authorization code waiting to be redeemed
+type mockIdPGrant struct {
+	clientID    string
+	redirectURI string
+	nonce       string
+	challenge   string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+
+	idp := &mockIdP{t: t, codes: map[string]mockIdPGrant{}}
+	idp.rotateKey("key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+// rotateKey replaces the signing key, as a provider does when it rotates keys
+func (idp *mockIdP) rotateKey(kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		idp.t.Fatalf("GenerateKey: %v", err)
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.key = key
+	idp.kid = kid
+}
+
+// authorize plays the browser and the provider's login page: it approves the authorization
+// request URL and returns the code the provider would redirect back with
+func (idp *mockIdP) authorize(authURL string) (code, state string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		idp.t.Fatalf("invalid authorization URL: %v", err)
+	}
+	query := u.Query()
+	if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" {
+		idp.t.Fatalf("authorization request is not a PKCE code request: %s", authURL)
+	}
+
+	code = "code-" + query.Get("state")[:8]
+	idp.mu.Lock()
+	idp.codes[code] = mockIdPGrant{
+		clientID:    query.Get("client_id"),
+		redirectURI: query.Get("redirect_uri"),
+		nonce:       query.Get("nonce"),
+		challenge:   query.Get("code_challenge"),
+	}
+	idp.mu.Unlock()
+
+	return code, query.Get("state")
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	idp.mu.Lock()
+	grant, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	key, kid, overrides := idp.key, idp.kid, idp.claims
+	idp.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || grant.clientID != r.PostForm.Get("client_id") ||
+		grant.redirectURI != r.PostForm.Get("redirect_uri") ||
+		grant.challenge != base64.RawURLEncoding.EncodeToString(challenge[:]) {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"sub":            "subject-1",
+		"aud":            grant.clientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          grant.nonce,
+		"email":          "oidc.user@example.com",
+		"email_verified": true,
+	}
+	for name, value := range overrides {
+		claims[name] = value
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = kid
+	signed, err := idToken.SignedString(key)
+	if err != nil {
+		writeTestJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeTestJSON(w, http.StatusOK, map[string]string{"token_type": "Bearer", "id_token": signed})
+}
+
+func (idp *mockIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	pub, kid := idp.key.PublicKey, idp.kid
+	idp.mu.Unlock()
+
+	writeTestJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// useMockIdP configures a provider named "mock" pointing at the mock IdP and clears cached discovery and keys
+func useMockIdP(t *testing.T) (*mockIdP, config.OIDCProviderConfig, *oidcDiscovery) {
+	t.Helper()
+
+	idp := newMockIdP(t)
+	cfg := config.OIDCProviderConfig{
+		Issuer:      idp.server.URL,
+		ClientID:    "dashboard",
+		RedirectURL: "https://app.example.com/oidc/callback",
+		Scopes:      []string{"openid", "email"},
+	}
+	config.Config.OIDC.Providers = map[string]config.OIDCProviderConfig{"mock": cfg}
+
+	oidcStatesMu.Lock()
+	oidcStates = map[string]*oidcProviderState{}
+	oidcStatesMu.Unlock()
+
+	discovery, err := getOIDCDiscovery("mock", cfg)
+	if err != nil {
+		t.Fatalf("getOIDCDiscovery: %v", err)
+	}
+	return idp, cfg, discovery
+}
+
+// startMockLogin builds an authorization request and has the mock IdP approve it
+func startMockLogin(t *testing.T, idp *mockIdP, cfg config.OIDCProviderConfig, discovery *oidcDiscovery) (code, nonce, verifier string) {
+	t.Helper()
+
+	nonce, verifier = "nonce-0123456789", "verifier-0123456789abcdefghijklmnopqrstuvwxyz"
+	authURL := buildOIDCAuthorizationURL(cfg, discovery, "state-0123456789", nonce, verifier)
+	code, state := idp.authorize(authURL)
+	if state != "state-0123456789" {
+		t.Fatalf("state was not passed to the provider: %q", state)
+	}
+	return code, nonce, verifier
+}
+
+func TestOIDCAuthorizationCodeFlowWithPKCE(t *testing.T) {
+	idp, cfg, discovery := useMockIdP(t)
+	code, nonce, verifier := startMockLogin(t, idp, cfg, discovery)
+
+	rawIDToken, err := exchangeOIDCCode(cfg, discovery, code, verifier)
+	if err != nil {
+		t.Fatalf("exchangeOIDCCode: %v", err)
+	}
+
+	claims, err := validateIDToken("mock", cfg, discovery, rawIDToken, nonce)
+	if err != nil {
+		t.Fatalf("validateIDToken: %v", err)
+	}
+	if claims.Subject != "subject-1" || claims.Email != "oidc.user@example.com" || !claims.emailVerified() {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+
+	// Codes are single use
+	if _, err := exchangeOIDCCode(cfg, discovery, code, verifier); !errors.Is(err, ErrOIDCCodeRejected) {
+		t.Errorf("second exchange error = %v, want ErrOIDCCodeRejected", err)
+	}
+}
+
+func TestOIDCExchangeRejectsWrongVerifier(t *testing.T) {
+	idp, cfg, discovery := useMockIdP(t)
+	code, _, _ := startMockLogin(t, idp, cfg, discovery)
+
+	// An injected code cannot be redeemed without the verifier of the login that requested it
+	_, err := exchangeOIDCCode(cfg, discovery, code, "verifier-of-another-login-0123456789abcdef")
+	if !errors.Is(err, ErrOIDCCodeRejected) {
+		t.Fatalf("error = %v, want ErrOIDCCodeRejected", err)
+	}
+}
+
+func TestOIDCValidateIDTokenRejectsInvalidTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		nonce  string
+	}{
+		{name: "nonce mismatch", nonce: "another-nonce"},
+		{name: "wrong audience", claims: jwt.MapClaims{"aud": "another-client"}},
+		{name: "wrong issuer", claims: jwt.MapClaims{"iss": "https://evil.example.com"}},
+		{name: "expired", claims: jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}},
+		{name: "missing subject", claims: jwt.MapClaims{"sub": ""}},
+		{name: "other authorized party", claims: jwt.MapClaims{"aud": []string{"dashboard", "other"}, "azp": "other"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp, cfg, discovery := useMockIdP(t)
+			idp.claims = tt.claims
+			code, nonce, verifier := startMockLogin(t, idp, cfg, discovery)
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+
+			rawIDToken, err := exchangeOIDCCode(cfg, discovery, code, verifier)
+			if err != nil {
+				t.Fatalf("exchangeOIDCCode: %v", err)
+			}
+
+			if _, err := validateIDToken("mock", cfg, discovery, rawIDToken, nonce); !errors.Is(err, ErrInvalidIDToken) {
+				t.Errorf("error = %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+}
+
+func TestOIDCValidateIDTokenFollowsKeyRotation(t *testing.T) {
+	idp, cfg, discovery := useMockIdP(t)
+
+	code, nonce, verifier := startMockLogin(t, idp, cfg, discovery)
+	rawIDToken, err := exchangeOIDCCode(cfg, discovery, code, verifier)
+	if err != nil {
+		t.Fatalf("exchangeOIDCCode: %v", err)
+	}
+	if _, err := validateIDToken("mock", cfg, discovery, rawIDToken, nonce); err != nil {
+		t.Fatalf("validateIDToken with the first key: %v", err)
+	}
+
+	idp.rotateKey("key-2")
+	code, nonce, verifier = startMockLogin(t, idp, cfg, discovery)
+	rawIDToken, err = exchangeOIDCCode(cfg, discovery, code, verifier)
+	if err != nil {
+		t.Fatalf("exchangeOIDCCode: %v", err)
+	}
+
+	// The key set was fetched moments ago, so the unknown kid is not refetched yet
+	if _, err := validateIDToken("mock", cfg, discovery, rawIDToken, nonce); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("error before the refresh interval = %v, want ErrInvalidIDToken", err)
+	}
+
+	cache := oidcProviderCache("mock")
+	cache.mu.Lock()
+	cache.keysFetchedAt = time.Now().Add(-2 * oidcJWKSRefreshInterval)
+	cache.mu.Unlock()
+
+	if _, err := validateIDToken("mock", cfg, discovery, rawIDToken, nonce); err != nil {
+		t.Fatalf("validateIDToken after rotation: %v", err)
+	}
+}
+
+func TestCompleteOIDCLoginRequiresBrowserBoundState(t *testing.T) {
+	useMockIdP(t)
+
+	tests := []struct {
+		name       string
+		boundState string
+	}{
+		{name: "no state cookie", boundState: ""},
+		{name: "state of another login", boundState: "state-started-by-the-attacker"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Rejected before the state is looked up or the code is redeemed
+			_, err := CompleteOIDCLogin("mock", "code", "state-0123456789", tt.boundState)
+			if !errors.Is(err, ErrOIDCStateNotBound) {
+				t.Errorf("error = %v, want ErrOIDCStateNotBound", err)
+			}
+		})
+	}
+}
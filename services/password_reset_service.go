@@ -0,0 +1,155 @@
+// services/password_reset_service.go
+package services
+
+import (
+	"dashboard-starter/config"
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidResetToken is returned for unknown, used or expired reset tokens
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// RequestPasswordReset emails a single-use reset link if the email belongs to a user.
+// It returns nil for unknown emails and for accounts over the hourly limit, so callers
+// cannot tell whether an account exists. The link is issued in the background, so the response
+// does not wait for it and takes as long as for an unknown email.
+func (s *UserService) RequestPasswordReset(email string) error {
+	user, err := s.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	go func() {
+		if err := sendPasswordReset(user); err != nil {
+			utils.Error("Failed to send password reset link to user %d: %v", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// sendPasswordReset stores a new reset token for the user, unless the hourly limit is reached, and emails it
+func sendPasswordReset(user *models.User) error {
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Duration(config.Config.Security.PasswordResetMinutes) * time.Minute
+	limited := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		// Tokens are kept for a day so the per-account limit can be counted
+		if err := tx.Where("user_id = ? AND created_at < ?", user.ID, now.Add(-24*time.Hour)).
+			Delete(&models.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+
+		var sent int64
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND created_at > ?", user.ID, now.Add(-time.Hour)).
+			Count(&sent).Error; err != nil {
+			return err
+		}
+		if sent >= int64(config.Config.Security.PasswordResetPerEmailHour) {
+			limited = true
+			return nil
+		}
+
+		// Only the latest reset link stays valid
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL AND expires_at > ?", user.ID, now).
+			Update("expires_at", now).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: utils.HashToken(token),
+			ExpiresAt: now.Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	if limited {
+		utils.Warn("Password reset link for user %d not sent: hourly limit reached", user.ID)
+		return nil
+	}
+
+	return sendPasswordResetEmail(user, token)
+}
+
+// sendPasswordResetEmail emails the reset link for a raw reset token
+func sendPasswordResetEmail(user *models.User, token string) error {
+	link := fmt.Sprintf("%s/reset-password?token=%s", config.Config.App.FrontendURL, url.QueryEscape(token))
+	return utils.SendMail(utils.MailMessage{
+		To:      user.Email,
+		Subject: config.Config.App.Name + " password reset",
+		Body: fmt.Sprintf("Hello %s,\n\nWe received a request to reset your password. "+
+			"Open the link below to choose a new password:\n\n%s\n\n"+
+			"The link expires in %d minutes and can only be used once. "+
+			"If you did not request a reset you can ignore this email.\n",
+			user.Name, link, config.Config.Security.PasswordResetMinutes),
+	})
+}
+
+// ResetPasswordWithToken sets a new password using a reset token and signs the user out everywhere
+func (s *UserService) ResetPasswordWithToken(token, newPassword string) error {
+	var resetToken models.PasswordResetToken
+	if err := db.DB.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?",
+		utils.HashToken(token), time.Now()).First(&resetToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Conditional update so the token cannot be used twice concurrently
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", resetToken.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+
+		var user models.User
+		if err := tx.First(&user, resetToken.UserID).Error; err != nil {
+			return err
+		}
+
+		user.Password = string(hashedPassword)
+		user.TokenVersion += 1 // Invalidate existing access tokens
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return revokeAllPersonalAccessTokens(tx, user.ID, "user")
+	})
+	if err != nil {
+		return err
+	}
+
+	// Sessions started with the old password must not survive the reset
+	return RevokeAllRefreshTokens(resetToken.UserID, "user")
+}
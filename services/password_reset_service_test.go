@@ -0,0 +1,39 @@
+package services
+
+import (
+	"dashboard-starter/config"
+	"dashboard-starter/models"
+	"strings"
+	"testing"
+)
+
+func TestSendPasswordResetEmail(t *testing.T) {
+	config.Config.App.Name = "Dashboard"
+	config.Config.App.FrontendURL = "https://app.example.com"
+	config.Config.Security.PasswordResetMinutes = 30
+	mailer := useCaptureMailer(t)
+
+	user := &models.User{Name: "Somchai", Email: "somchai@example.com"}
+	token := "reset+token/with=special&chars"
+	if err := sendPasswordResetEmail(user, token); err != nil {
+		t.Fatalf("sendPasswordResetEmail: %v", err)
+	}
+
+	msg := mailer.next(t)
+	if msg.To != user.Email {
+		t.Errorf("To = %q, want %q", msg.To, user.Email)
+	}
+	if msg.Subject != "Dashboard password reset" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+	if !strings.Contains(msg.Body, "Hello Somchai") {
+		t.Errorf("body does not greet the user:\n%s", msg.Body)
+	}
+	if !strings.Contains(msg.Body, "expires in 30 minutes") {
+		t.Errorf("body does not state the expiry:\n%s", msg.Body)
+	}
+
+	if got := mailLinkToken(t, msg.Body, "/reset-password"); got != token {
+		t.Errorf("link token = %q, want %q", got, token)
+	}
+}
@@ -0,0 +1,178 @@
+// services/personal_access_token_service.go
+package services
+
+import (
+	"dashboard-starter/config"
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrPersonalTokenNotFound is returned when the token does not exist or belongs to someone else
+	ErrPersonalTokenNotFound = errors.New("personal access token not found")
+	// ErrInvalidPersonalToken is returned for unknown, expired or revoked personal access tokens
+	ErrInvalidPersonalToken = errors.New("invalid, expired or revoked personal access token")
+	// ErrScopeNotGranted is returned when a token would get a permission its owner does not have
+	ErrScopeNotGranted = errors.New("scope is not granted to this account")
+	// ErrTokenLifetimeTooLong is returned when the requested expiry exceeds SECURITY_PAT_MAX_DAYS
+	ErrTokenLifetimeTooLong = errors.New("token lifetime is too long")
+)
+
+// personalTokenUsageInterval limits how often last_used_at is written for a busy token
+const personalTokenUsageInterval = time.Minute
+
+// CreatePersonalAccessToken issues a token for an admin or user. Every scope must be a permission
+// the owner has now; the raw token is only available on the returned struct.
+func CreatePersonalAccessToken(userID uint, userType string, input *models.PersonalAccessTokenInput) (*models.PersonalAccessToken, error) {
+	maxDays := config.Config.Security.PersonalTokenMaxDays
+	days := input.ExpiresInDays
+	if days == 0 {
+		days = maxDays
+	}
+	if maxDays > 0 && days > maxDays {
+		return nil, fmt.Errorf("%w: at most %d days", ErrTokenLifetimeTooLong, maxDays)
+	}
+
+	scopes, err := checkTokenScopes(userID, userType, input.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return nil, err
+	}
+	raw = models.PersonalAccessTokenPrefix + raw
+
+	token := &models.PersonalAccessToken{
+		TokenHash: utils.HashToken(raw),
+		Hint:      raw[len(raw)-4:],
+		UserID:    userID,
+		UserType:  userType,
+		Name:      input.Name,
+		Scopes:    scopes,
+	}
+	if days > 0 {
+		expiresAt := time.Now().AddDate(0, 0, days)
+		token.ExpiresAt = &expiresAt
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(token).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token.Token = raw
+	return token, nil
+}
+
+// checkTokenScopes removes duplicates and rejects scopes that are unknown or not granted to the owner
+func checkTokenScopes(userID uint, userType string, scopes []string) ([]string, error) {
+	unique := make([]string, 0, len(scopes))
+	seen := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+
+	if _, err := findPermissions(db.DB, unique); err != nil {
+		return nil, err
+	}
+
+	granted, err := GetPermissions(userID, userType)
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range unique {
+		if !HasPermission(granted, scope) {
+			return nil, fmt.Errorf("%w: %q", ErrScopeNotGranted, scope)
+		}
+	}
+
+	return unique, nil
+}
+
+// ListPersonalAccessTokens returns the tokens of an admin or user that have not been revoked
+func ListPersonalAccessTokens(userID uint, userType string) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	err := db.DB.Where("user_id = ? AND user_type = ? AND revoked_at IS NULL", userID, userType).
+		Order("created_at DESC").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+// RevokePersonalAccessToken revokes one of the principal's own tokens
+func RevokePersonalAccessToken(userID uint, userType string, tokenID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PersonalAccessToken{}).
+			Where("id = ? AND user_id = ? AND user_type = ? AND revoked_at IS NULL", tokenID, userID, userType).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPersonalTokenNotFound
+		}
+		return nil
+	})
+}
+
+// RevokeAllPersonalAccessTokens revokes every active token of an admin or user.
+// Called wherever a principal is signed out everywhere, since tokens do not follow the token version.
+func RevokeAllPersonalAccessTokens(userID uint, userType string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		return revokeAllPersonalAccessTokens(tx, userID, userType)
+	})
+}
+
+// revokeAllPersonalAccessTokens revokes every active token of a principal within a transaction
+func revokeAllPersonalAccessTokens(tx *gorm.DB, userID uint, userType string) error {
+	return tx.Model(&models.PersonalAccessToken{}).
+		Where("user_id = ? AND user_type = ? AND revoked_at IS NULL", userID, userType).
+		Update("revoked_at", time.Now()).Error
+}
+
+// AuthenticatePersonalAccessToken returns the active token matching a raw token and records its use
+func AuthenticatePersonalAccessToken(raw string) (*models.PersonalAccessToken, error) {
+	token, err := findActivePersonalAccessToken(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > personalTokenUsageInterval {
+		if err := db.DB.Model(token).UpdateColumn("last_used_at", now).Error; err != nil {
+			utils.Warn("Failed to record use of personal access token %d: %v", token.ID, err)
+		}
+		token.LastUsedAt = &now
+	}
+
+	return token, nil
+}
+
+// findActivePersonalAccessToken returns the active token matching a raw token without recording its use
+func findActivePersonalAccessToken(raw string) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	if err := db.DB.Where("token_hash = ?", utils.HashToken(raw)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidPersonalToken
+		}
+		return nil, err
+	}
+
+	if token.RevokedAt != nil || (token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt)) {
+		return nil, ErrInvalidPersonalToken
+	}
+
+	return &token, nil
+}
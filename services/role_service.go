@@ -0,0 +1,309 @@
+// services/role_service.go
+package services
+
+import (
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrRoleNotFound is returned when a role does not exist
+	ErrRoleNotFound = errors.New("role not found")
+	// ErrRoleExists is returned when a role name is already taken
+	ErrRoleExists = errors.New("role name already exists")
+	// ErrSystemRole is returned when a built-in role would be renamed, deleted or, for super_admin, changed
+	ErrSystemRole = errors.New("built-in roles cannot be renamed or deleted and super_admin cannot be changed")
+	// ErrUnknownPermission is returned when a role refers to a permission that does not exist
+	ErrUnknownPermission = errors.New("unknown permission")
+	// ErrRoleNotAssigned is returned when removing a role the principal does not have
+	ErrRoleNotAssigned = errors.New("role is not assigned to this account")
+)
+
+// GetPermissions returns the names of every permission granted to a principal
+// by its assigned roles and the default roles of its user type
+func GetPermissions(userID uint, userType string) ([]string, error) {
+	return principalPermissions(db.DB, userID, userType)
+}
+
+// principalPermissions is GetPermissions within the given transaction
+func principalPermissions(tx *gorm.DB, userID uint, userType string) ([]string, error) {
+	var names []string
+	err := tx.Table("permissions").
+		Distinct("permissions.name").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Where("roles.default_for = ? OR roles.id IN (?)", userType, assignedRoleIDs(userID, userType)).
+		Pluck("permissions.name", &names).Error
+	return names, err
+}
+
+// HasPermission reports whether the granted permissions include the required one,
+// either exactly, through "*" or through a resource wildcard such as "articles:*"
+func HasPermission(granted []string, required string) bool {
+	resource := required
+	if i := strings.Index(required, ":"); i >= 0 {
+		resource = required[:i]
+	}
+
+	for _, permission := range granted {
+		if permission == required || permission == "*" || permission == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPrincipalRoles returns the roles that apply to a principal, including default roles
+func GetPrincipalRoles(userID uint, userType string) ([]models.Role, error) {
+	var roles []models.Role
+	err := db.DB.Preload("Permissions").
+		Where("default_for = ? OR id IN (?)", userType, assignedRoleIDs(userID, userType)).
+		Order("name").
+		Find(&roles).Error
+	return roles, err
+}
+
+// assignedRoleIDs is a subquery selecting the IDs of the roles assigned to a principal
+func assignedRoleIDs(userID uint, userType string) *gorm.DB {
+	return db.DB.Model(&models.RoleAssignment{}).
+		Select("role_id").
+		Where("user_id = ? AND user_type = ?", userID, userType)
+}
+
+// ListRoles returns every role with its permissions
+func ListRoles() ([]models.Role, error) {
+	var roles []models.Role
+	err := db.DB.Preload("Permissions").Order("name").Find(&roles).Error
+	return roles, err
+}
+
+// ListPermissions returns every known permission
+func ListPermissions() ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := db.DB.Order("name").Find(&permissions).Error
+	return permissions, err
+}
+
+// GetRole returns a role with its permissions
+func GetRole(id uint) (*models.Role, error) {
+	var role models.Role
+	if err := db.DB.Preload("Permissions").First(&role, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+// CreateRole creates a custom role. The acting admin may only include permissions they hold.
+func CreateRole(input *models.RoleInput, actorID uint) (*models.Role, error) {
+	var role models.Role
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := ensureRoleNameFree(tx, input.Name, 0); err != nil {
+			return err
+		}
+
+		permissions, err := findPermissions(tx, input.Permissions)
+		if err != nil {
+			return err
+		}
+
+		role = models.Role{
+			Name:        input.Name,
+			Description: input.Description,
+			Permissions: permissions,
+		}
+		if err := ensureActorCanGrantRole(tx, &role, actorID); err != nil {
+			return err
+		}
+		return tx.Create(&role).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+// UpdateRole changes the name, description and permissions of a role.
+// Built-in roles keep their name, and super_admin keeps full access.
+// The acting admin may only give the role permissions they hold.
+func UpdateRole(id uint, input *models.RoleInput, actorID uint) (*models.Role, error) {
+	role, err := GetRole(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if role.IsSystem && input.Name != role.Name {
+		return nil, ErrSystemRole
+	}
+	if role.Name == models.RoleSuperAdmin {
+		return nil, ErrSystemRole
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := ensureRoleNameFree(tx, input.Name, role.ID); err != nil {
+			return err
+		}
+
+		permissions, err := findPermissions(tx, input.Permissions)
+		if err != nil {
+			return err
+		}
+		if err := ensureActorCanGrantRole(tx, &models.Role{Name: role.Name, Permissions: permissions}, actorID); err != nil {
+			return err
+		}
+
+		role.Name = input.Name
+		role.Description = input.Description
+		if err := tx.Omit("Permissions").Save(role).Error; err != nil {
+			return err
+		}
+
+		role.Permissions = permissions
+		return tx.Model(role).Association("Permissions").Replace(permissions)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return role, nil
+}
+
+// DeleteRole deletes a custom role together with its assignments
+func DeleteRole(id uint) error {
+	role, err := GetRole(id)
+	if err != nil {
+		return err
+	}
+
+	if role.IsSystem {
+		return ErrSystemRole
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.RoleAssignment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+}
+
+// GetRoleMembers returns the assignments of a role
+func GetRoleMembers(roleID uint) ([]models.RoleAssignment, error) {
+	if _, err := GetRole(roleID); err != nil {
+		return nil, err
+	}
+
+	var assignments []models.RoleAssignment
+	err := db.DB.Where("role_id = ?", roleID).Order("user_type, user_id").Find(&assignments).Error
+	return assignments, err
+}
+
+// AssignRole gives a role to an admin or user. The acting admin may only assign roles
+// whose permissions they hold, and only super admins may assign super_admin.
+func AssignRole(roleID, userID uint, userType string, actorID uint) error {
+	role, err := GetRole(roleID)
+	if err != nil {
+		return err
+	}
+
+	// Make sure the principal exists
+	if _, err := GetUserTokenVersion(userID, userType); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := ensureActorCanGrantRole(tx, role, actorID); err != nil {
+			return err
+		}
+
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RoleAssignment{
+			RoleID:   roleID,
+			UserID:   userID,
+			UserType: userType,
+		}).Error
+	})
+}
+
+// RemoveRole takes a role away from an admin or user
+func RemoveRole(roleID, userID uint, userType string) error {
+	role, err := GetRole(roleID)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if role.Name == models.RoleSuperAdmin && userType == "admin" {
+			if err := ensureOtherActiveSuperAdmin(tx, userID); err != nil {
+				return err
+			}
+		}
+
+		result := tx.Where("role_id = ? AND user_id = ? AND user_type = ?", role.ID, userID, userType).
+			Delete(&models.RoleAssignment{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRoleNotAssigned
+		}
+		return nil
+	})
+}
+
+// ensureActorCanGrantRole loads the acting admin's permissions in tx and returns ErrRoleEscalation
+// if the role grants more than they hold
+func ensureActorCanGrantRole(tx *gorm.DB, role *models.Role, actorID uint) error {
+	granted, err := principalPermissions(tx, actorID, "admin")
+	if err != nil {
+		return err
+	}
+	return ensureCanGrantRole(tx, role, actorID, granted)
+}
+
+// ensureRoleNameFree returns ErrRoleExists if another role already uses the name
+func ensureRoleNameFree(tx *gorm.DB, name string, exceptID uint) error {
+	var count int64
+	if err := tx.Model(&models.Role{}).Where("name = ? AND id != ?", name, exceptID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrRoleExists
+	}
+	return nil
+}
+
+// findPermissions loads permissions by name and rejects unknown names
+func findPermissions(tx *gorm.DB, names []string) ([]models.Permission, error) {
+	permissions := []models.Permission{}
+	if len(names) == 0 {
+		return permissions, nil
+	}
+
+	if err := tx.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		found[permission.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf("%w %q", ErrUnknownPermission, name)
+		}
+	}
+
+	return permissions, nil
+}
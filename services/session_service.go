@@ -0,0 +1,70 @@
+// services/session_service.go
+package services
+
+import (
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrSessionNotFound is returned when a session does not exist, has ended or belongs to another principal
+var ErrSessionNotFound = errors.New("session not found")
+
+// ListSessions returns the active sessions of a principal, most recently used first.
+// Each token family has exactly one live refresh token, so every live token is one session;
+// the session starts with the first token of its family.
+func ListSessions(userID uint, userType string) ([]models.Session, error) {
+	if _, err := GetUserTypeProvider(userType); err != nil {
+		return nil, err
+	}
+
+	var sessions []models.Session
+	err := db.DB.Model(&models.RefreshToken{}).
+		Select(`family_id AS id, user_agent, ip_address, device_label, last_used_at, expires_at,
+			(SELECT MIN(first.created_at) FROM refresh_tokens first WHERE first.family_id = refresh_tokens.family_id) AS created_at`).
+		Where("user_id = ? AND user_type = ? AND is_revoked = ? AND expires_at > ?",
+			userID, userType, false, time.Now()).
+		Order("last_used_at DESC NULLS LAST, id DESC").
+		Scan(&sessions).Error
+
+	return sessions, err
+}
+
+// RevokeSession signs out a single session of a principal by revoking its token family.
+// Access tokens issued for the session stop working immediately.
+func RevokeSession(userID uint, userType string, sessionID string) error {
+	var token models.RefreshToken
+	err := db.DB.Where("family_id = ? AND user_id = ? AND user_type = ? AND is_revoked = ? AND expires_at > ?",
+		sessionID, userID, userType, false, time.Now()).
+		First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+
+	return RevokeRefreshTokenFamily(token.FamilyID)
+}
+
+// RevokeOtherSessions signs out every session of a principal except the one the refresh token belongs to
+func RevokeOtherSessions(userID uint, userType string, currentRefreshToken string) error {
+	current, err := ValidateRefreshToken(currentRefreshToken)
+	if err != nil || current.UserID != userID || current.UserType != userType {
+		return ErrSessionNotFound
+	}
+
+	return RevokeAllRefreshTokens(userID, userType, current.FamilyID)
+}
+
+// truncateRunes shortens client supplied values to fit their columns without splitting characters
+func truncateRunes(value string, max int) string {
+	runes := []rune(value)
+	if len(runes) <= max {
+		return value
+	}
+	return string(runes[:max])
+}
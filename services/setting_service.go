@@ -0,0 +1,48 @@
+// services/setting_service.go
+package services
+
+import (
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"errors"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetSetting returns the value of a runtime setting or fallback if it has not been set
+func GetSetting(key, fallback string) (string, error) {
+	var setting models.Setting
+	if err := db.DB.Where("key = ?", key).First(&setting).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fallback, nil
+		}
+		return "", err
+	}
+	return setting.Value, nil
+}
+
+// GetBoolSetting returns a runtime setting parsed as a boolean
+func GetBoolSetting(key string, fallback bool) (bool, error) {
+	value, err := GetSetting(key, strconv.FormatBool(fallback))
+	if err != nil {
+		return fallback, err
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fallback, nil
+	}
+	return parsed, nil
+}
+
+// SetSetting creates or updates a runtime setting
+func SetSetting(key, value string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+		}).Create(&models.Setting{Key: key, Value: value}).Error
+	})
+}
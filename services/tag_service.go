@@ -0,0 +1,123 @@
+// services/tag_service.go
+package services
+
+import (
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidSlug is returned when no slug was given and none can be generated from the name
+var ErrInvalidSlug = errors.New("slug could not be generated from the name; provide a slug")
+
+type TagService struct {
+	repo *db.GormRepository[models.Tag]
+}
+
+func NewTagService() *TagService {
+	return &TagService{
+		repo: db.NewRepository[models.Tag](),
+	}
+}
+
+// GetTags retrieves tags with pagination and search by name
+func (s *TagService) GetTags(params utils.PaginationParams) ([]models.Tag, *utils.PaginationResult, error) {
+	var tags []models.Tag
+
+	query := db.DB.Model(&models.Tag{})
+	if params.Search != "" {
+		query = utils.ApplySearch(query, params.Search, "name", "slug")
+	}
+
+	result, err := utils.ApplyPagination(query, params, &tags)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tags, result, nil
+}
+
+// GetByID retrieves a tag by ID
+func (s *TagService) GetByID(id string) (*models.Tag, error) {
+	return s.repo.FindByID(id)
+}
+
+// CreateTag creates a tag, generating a unique slug from the name when none is given
+func (s *TagService) CreateTag(input *models.TagInput) (*models.Tag, error) {
+	tag := &models.Tag{Name: strings.TrimSpace(input.Name)}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		slug, err := taxonomySlug(tx, "tags", input.Name, input.Slug, 0)
+		if err != nil {
+			return err
+		}
+		tag.Slug = slug
+		return tx.Create(tag).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// UpdateTag renames a tag and updates its slug
+func (s *TagService) UpdateTag(id string, input *models.TagInput) (*models.Tag, error) {
+	tag, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		slug, err := taxonomySlug(tx, "tags", input.Name, input.Slug, tag.ID)
+		if err != nil {
+			return err
+		}
+		tag.Name = strings.TrimSpace(input.Name)
+		tag.Slug = slug
+		return tx.Save(tag).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// DeleteTag deletes a tag and removes it from all articles
+func (s *TagService) DeleteTag(id string) error {
+	tag, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM article_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(tag).Error
+	})
+}
+
+// taxonomySlug returns a unique slug for a tag or category, built from slug when given and from
+// name otherwise. excludeID is the row being updated.
+func taxonomySlug(tx *gorm.DB, table, name, slug string, excludeID uint) (string, error) {
+	source := slug
+	if strings.TrimSpace(source) == "" {
+		source = name
+	}
+
+	base, err := utils.GenerateSlug(source, 100)
+	if err != nil {
+		return "", err
+	}
+	if base == "" {
+		return "", ErrInvalidSlug
+	}
+
+	return utils.EnsureUniqueSlug(tx, base, table, "slug", excludeID)
+}
@@ -51,9 +51,11 @@ func (p *DeviceProvider) GetModelName() string {
 type UserProvider struct{}
 
 func (p *UserProvider) GetTokenVersion(userID uint) (int, error) {
-	// Regular users don't have token versioning yet
-	// This is a placeholder and can be implemented later
-	return 1, nil
+	var user models.User
+	if err := db.DB.Select("token_version").First(&user, userID).Error; err != nil {
+		return 0, err
+	}
+	return user.TokenVersion, nil
 }
 
 func (p *UserProvider) GetModelName() string {
@@ -81,49 +83,83 @@ func GetUserTypeProvider(userType string) (UserTypeProvider, error) {
 	return provider, nil
 }
 
-// CreateRefreshToken creates and stores a new refresh token
-func CreateRefreshToken(userID uint, userType string) (*models.RefreshToken, error) {
+var (
+	// ErrRefreshTokenReused is returned when a refresh token that was already rotated is presented again
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrSessionRevoked is returned for refresh and access tokens of a session that has been ended
+	ErrSessionRevoked = errors.New("session has been revoked")
+)
+
+// revokedColumns returns the columns set when refresh tokens are revoked for the given reason
+func revokedColumns(reason string) map[string]interface{} {
+	return map[string]interface{}{"is_revoked": true, "revoked_reason": reason}
+}
+
+// CreateRefreshToken creates and stores a new refresh token that starts a new token family (a new session)
+func CreateRefreshToken(userID uint, userType string, client models.SessionClient) (*models.RefreshToken, error) {
 	// Check if the user type is supported
 	if _, err := GetUserTypeProvider(userType); err != nil {
 		return nil, err
 	}
 
-	// Generate a JWT refresh token
-	tokenString, expiresAt, err := utils.GenerateRefreshToken(userID, userType)
+	familyID, err := utils.GenerateSecureToken(16)
 	if err != nil {
 		return nil, err
 	}
 
 	// Create token inside a transaction
-	var refreshToken models.RefreshToken
+	var refreshToken *models.RefreshToken
 	err = db.Transaction(func(tx *gorm.DB) error {
-		// Create new refresh token
-		refreshToken = models.RefreshToken{
-			Token:     tokenString,
-			UserID:    userID,
-			UserType:  userType,
-			ExpiresAt: expiresAt,
-			IsRevoked: false,
-		}
+		var err error
+		refreshToken, err = createRefreshTokenInFamily(tx, userID, userType, familyID, client)
+		return err
+	})
 
-		if err := tx.Create(&refreshToken).Error; err != nil {
-			return err
-		}
+	if err != nil {
+		return nil, err
+	}
 
-		return nil
-	})
+	return refreshToken, nil
+}
 
+// createRefreshTokenInFamily generates a refresh token JWT and stores it under the given family
+func createRefreshTokenInFamily(tx *gorm.DB, userID uint, userType, familyID string, client models.SessionClient) (*models.RefreshToken, error) {
+	// Generate a JWT refresh token
+	tokenString, tokenID, expiresAt, err := utils.GenerateRefreshToken(userID, userType)
 	if err != nil {
 		return nil, err
 	}
 
+	// Only the digest is stored, the raw token is returned to the caller once
+	now := time.Now()
+	refreshToken := models.RefreshToken{
+		TokenID:     tokenID,
+		TokenHash:   utils.HashToken(tokenString),
+		FamilyID:    familyID,
+		ClientID:    client.ClientID,
+		UserID:      userID,
+		UserType:    userType,
+		ExpiresAt:   expiresAt,
+		IsRevoked:   false,
+		UserAgent:   truncateRunes(client.UserAgent, 255),
+		IPAddress:   truncateRunes(client.IPAddress, 45),
+		DeviceLabel: truncateRunes(client.DeviceLabel, 100),
+		LastUsedAt:  &now,
+	}
+
+	if err := tx.Create(&refreshToken).Error; err != nil {
+		return nil, err
+	}
+
+	refreshToken.Token = tokenString
 	return &refreshToken, nil
 }
 
-// ValidateRefreshToken checks if a refresh token is valid and not revoked
-func ValidateRefreshToken(tokenString string) (*models.RefreshToken, error) {
+// findRefreshToken parses a raw refresh token and loads its stored record by token ID,
+// verifying the stored digest in constant time. Revoked and expired records are returned as well.
+func findRefreshToken(tokenString string) (*models.RefreshToken, error) {
 	// Parse the token to get user information
-	userID, userType, err := utils.ParseRefreshToken(tokenString)
+	userID, userType, tokenID, err := utils.ParseRefreshToken(tokenString)
 	if err != nil {
 		return nil, err
 	}
@@ -133,16 +169,130 @@ func ValidateRefreshToken(tokenString string) (*models.RefreshToken, error) {
 		return nil, err
 	}
 
-	// Find the token in database
 	var refreshToken models.RefreshToken
-	if err := db.DB.Where("token = ? AND user_id = ? AND user_type = ? AND is_revoked = ? AND expires_at > ?",
-		tokenString, userID, userType, false, time.Now()).First(&refreshToken).Error; err != nil {
+	if err := db.DB.Where("token_id = ? AND user_id = ? AND user_type = ?", tokenID, userID, userType).
+		First(&refreshToken).Error; err != nil {
 		return nil, err
 	}
 
+	if !utils.CompareTokenHash(tokenString, refreshToken.TokenHash) {
+		return nil, gorm.ErrRecordNotFound
+	}
+
 	return &refreshToken, nil
 }
 
+// ValidateRefreshToken checks if a refresh token is valid and not revoked
+func ValidateRefreshToken(tokenString string) (*models.RefreshToken, error) {
+	refreshToken, err := findRefreshToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if refreshToken.IsRevoked {
+		return nil, errors.New("refresh token revoked")
+	}
+
+	if !refreshToken.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("refresh token expired")
+	}
+
+	return refreshToken, nil
+}
+
+// RotateRefreshToken revokes the presented refresh token and issues a new one in the same family.
+// If the presented token was already rotated, the whole family is revoked and ErrRefreshTokenReused is returned;
+// a token of a session that was ended returns ErrSessionRevoked.
+// The session keeps its device label unless the client sends a new one, and always keeps its OAuth client.
+func RotateRefreshToken(tokenString string, client models.SessionClient) (*models.RefreshToken, error) {
+	// Look the token up regardless of its revoked state so reuse can be detected
+	current, err := findRefreshToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if current.IsRevoked {
+		return nil, revokedRefreshTokenError(current)
+	}
+
+	if !current.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("refresh token expired")
+	}
+
+	var rotated *models.RefreshToken
+	reused := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Conditional update so that two concurrent refreshes cannot both succeed
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND is_revoked = ?", current.ID, false).
+			Updates(revokedColumns(models.RefreshTokenRotated))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			return ErrRefreshTokenReused
+		}
+
+		if client.DeviceLabel == "" {
+			client.DeviceLabel = current.DeviceLabel
+		}
+		client.ClientID = current.ClientID
+
+		var err error
+		rotated, err = createRefreshTokenInFamily(tx, current.UserID, current.UserType, current.FamilyID, client)
+		return err
+	})
+
+	if reused {
+		return nil, revokedRefreshTokenError(current)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rotated, nil
+}
+
+// revokedRefreshTokenError handles a refresh token that is no longer live. Tokens of an ended session
+// are simply rejected; anything else is a rotated token presented again, which revokes its family.
+func revokedRefreshTokenError(token *models.RefreshToken) error {
+	ended, err := isSessionEnded(token)
+	if err != nil {
+		utils.Error("Failed to check session of refresh token %d: %v", token.ID, err)
+	}
+	if ended {
+		return ErrSessionRevoked
+	}
+
+	revokeFamilyOnReuse(token)
+	return ErrRefreshTokenReused
+}
+
+// isSessionEnded reports whether the session of a refresh token was ended by logout, its owner or an admin
+func isSessionEnded(token *models.RefreshToken) (bool, error) {
+	if token.RevokedReason == models.RefreshTokenRevoked {
+		return true, nil
+	}
+
+	var count int64
+	err := db.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_reason = ?", token.FamilyID, models.RefreshTokenRevoked).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// revokeFamilyOnReuse revokes every token in the family of a reused refresh token and logs the event
+func revokeFamilyOnReuse(token *models.RefreshToken) {
+	utils.Warn("Refresh token reuse detected: token_id=%d user_id=%d user_type=%s family=%s",
+		token.ID, token.UserID, token.UserType, token.FamilyID)
+
+	if err := revokeRefreshTokenFamily(token.FamilyID, models.RefreshTokenReused); err != nil {
+		utils.Error("Failed to revoke refresh token family %s: %v", token.FamilyID, err)
+	}
+}
+
 // GetUserTokenVersion retrieves the token version for a user
 func GetUserTokenVersion(userID uint, userType string) (int, error) {
 	provider, err := GetUserTypeProvider(userType)
@@ -153,33 +303,140 @@ func GetUserTokenVersion(userID uint, userType string) (int, error) {
 	return provider.GetTokenVersion(userID)
 }
 
-// RevokeRefreshToken marks a refresh token as revoked
+var (
+	// ErrPrincipalNotFound is returned when the admin, user or device of a token no longer exists
+	ErrPrincipalNotFound = errors.New("account not found")
+	// ErrTokenVersionRevoked is returned when the token was issued before the principal's token version changed
+	ErrTokenVersionRevoked = errors.New("token has been revoked")
+	// ErrAccountDisabled is returned when the admin of a token has been disabled
+	ErrAccountDisabled = errors.New("account is disabled")
+	// ErrUnknownUserType is returned for tokens with a user type this service does not issue
+	ErrUnknownUserType = errors.New("unknown user type")
+)
+
+// CheckAccessToken applies the rules every access token must pass after its signature is verified:
+// the principal must exist, an admin must not be disabled, the token version must be current
+// and the session the token was issued for, if any, must not have been ended
+func CheckAccessToken(userID uint, userType string, tokenVersion int, sessionID string) error {
+	currentVersion, err := activePrincipalTokenVersion(userID, userType)
+	if err != nil {
+		return err
+	}
+
+	if tokenVersion != currentVersion {
+		return ErrTokenVersionRevoked
+	}
+
+	if sessionID == "" {
+		return nil
+	}
+
+	// A session is live while its family still has an unrevoked refresh token
+	var count int64
+	if err := db.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND user_id = ? AND user_type = ? AND is_revoked = ?", sessionID, userID, userType, false).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// CheckPrincipal reports whether the principal of a credential without a token version
+// (refresh tokens, personal access tokens) still exists and, for admins, is enabled
+func CheckPrincipal(userID uint, userType string) error {
+	_, err := activePrincipalTokenVersion(userID, userType)
+	return err
+}
+
+// activePrincipalTokenVersion returns the current token version of an existing, enabled principal
+func activePrincipalTokenVersion(userID uint, userType string) (int, error) {
+	switch userType {
+	case "admin":
+		var admin models.Admin
+		if err := db.DB.First(&admin, userID).Error; err != nil {
+			return 0, principalLookupError(err)
+		}
+		if admin.DisabledAt != nil {
+			return 0, ErrAccountDisabled
+		}
+		return admin.TokenVersion, nil
+	case "user":
+		var user models.User
+		if err := db.DB.First(&user, userID).Error; err != nil {
+			return 0, principalLookupError(err)
+		}
+		return user.TokenVersion, nil
+	case "device":
+		var device models.Device
+		if err := db.DB.First(&device, userID).Error; err != nil {
+			return 0, principalLookupError(err)
+		}
+		return device.TokenVersion, nil
+	default:
+		return 0, ErrUnknownUserType
+	}
+}
+
+// principalLookupError turns a missing record into ErrPrincipalNotFound
+func principalLookupError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrPrincipalNotFound
+	}
+	return err
+}
+
+// RevokeRefreshToken marks a refresh token as revoked, ending its session
 func RevokeRefreshToken(tokenString string) error {
 	return db.Transaction(func(tx *gorm.DB) error {
 		return tx.Model(&models.RefreshToken{}).
-			Where("token = ?", tokenString).
-			Update("is_revoked", true).Error
+			Where("token_hash = ?", utils.HashToken(tokenString)).
+			Updates(revokedColumns(models.RefreshTokenRevoked)).Error
 	})
 }
 
-// RevokeAllRefreshTokens revokes all refresh tokens for a user
-func RevokeAllRefreshTokens(userID uint, userType string) error {
+// RevokeRefreshTokenFamily revokes every refresh token that belongs to the given family, ending the session
+func RevokeRefreshTokenFamily(familyID string) error {
+	return revokeRefreshTokenFamily(familyID, models.RefreshTokenRevoked)
+}
+
+// revokeRefreshTokenFamily revokes the live refresh tokens of a family for the given reason
+func revokeRefreshTokenFamily(familyID, reason string) error {
+	if familyID == "" {
+		return errors.New("family ID is required")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		return tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND is_revoked = ?", familyID, false).
+			Updates(revokedColumns(reason)).Error
+	})
+}
+
+// RevokeAllRefreshTokens revokes all refresh tokens for a user, except those in the given token families
+func RevokeAllRefreshTokens(userID uint, userType string, keepFamilies ...string) error {
 	// Check if the user type is supported
 	if _, err := GetUserTypeProvider(userType); err != nil {
 		return err
 	}
 
 	return db.Transaction(func(tx *gorm.DB) error {
-		return tx.Model(&models.RefreshToken{}).
-			Where("user_id = ? AND user_type = ? AND is_revoked = ?", userID, userType, false).
-			Update("is_revoked", true).Error
+		query := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND user_type = ? AND is_revoked = ?", userID, userType, false)
+		if len(keepFamilies) > 0 {
+			query = query.Where("family_id NOT IN ?", keepFamilies)
+		}
+		return query.Updates(revokedColumns(models.RefreshTokenRevoked)).Error
 	})
 }
 
-// CleanupExpiredTokens removes all expired refresh tokens
+// CleanupExpiredTokens removes all expired refresh tokens.
+// Revoked tokens are kept until they expire so that reuse of a rotated token can still be detected.
 func CleanupExpiredTokens() error {
 	return db.Transaction(func(tx *gorm.DB) error {
-		return tx.Where("expires_at < ? OR is_revoked = ?", time.Now(), true).
+		return tx.Where("expires_at < ?", time.Now()).
 			Delete(&models.RefreshToken{}).Error
 	})
 }
@@ -212,8 +469,14 @@ func RotateUserTokenVersion(userID uint, userType string) error {
 			return tx.Save(&device).Error
 		})
 	case "user":
-		// Users don't have token versioning yet
-		return nil
+		return db.Transaction(func(tx *gorm.DB) error {
+			var user models.User
+			if err := tx.First(&user, userID).Error; err != nil {
+				return err
+			}
+			user.TokenVersion += 1
+			return tx.Save(&user).Error
+		})
 	default:
 		return errors.New("unsupported user type for token rotation")
 	}
@@ -0,0 +1,390 @@
+// services/two_factor_service.go
+package services
+
+import (
+	"crypto/subtle"
+	"dashboard-starter/config"
+	"dashboard-starter/db"
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	recoveryCodeCount          = 10
+	maxTwoFactorChallengeTries = 5
+)
+
+var (
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorRequired       = errors.New("two-factor authentication is required for this account type")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrChallengeExhausted      = errors.New("too many attempts, please login again")
+)
+
+// twoFactorSettingKey returns the settings key that makes 2FA mandatory for a user type
+func twoFactorSettingKey(userType string) string {
+	return "two_factor_required." + userType
+}
+
+// IsTwoFactorRequired reports whether 2FA is mandatory for the user type
+func IsTwoFactorRequired(userType string) (bool, error) {
+	return GetBoolSetting(twoFactorSettingKey(userType), false)
+}
+
+// SetTwoFactorRequired makes 2FA mandatory or optional for the user type
+func SetTwoFactorRequired(userType string, required bool) error {
+	if userType != "admin" && userType != "user" {
+		return errors.New("unsupported user type")
+	}
+	return SetSetting(twoFactorSettingKey(userType), strconv.FormatBool(required))
+}
+
+// GetTwoFactor returns the 2FA record of a principal, or nil if none exists
+func GetTwoFactor(userID uint, userType string) (*models.TwoFactor, error) {
+	var twoFactor models.TwoFactor
+	err := db.DB.Where("user_id = ? AND user_type = ?", userID, userType).First(&twoFactor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &twoFactor, nil
+}
+
+// IsTwoFactorEnabled reports whether a principal has confirmed 2FA enrollment
+func IsTwoFactorEnabled(userID uint, userType string) (bool, error) {
+	twoFactor, err := GetTwoFactor(userID, userType)
+	if err != nil {
+		return false, err
+	}
+	return twoFactor != nil && twoFactor.EnabledAt != nil, nil
+}
+
+// BeginTwoFactorEnrollment creates a new pending TOTP secret and returns it with its provisioning URI
+func BeginTwoFactorEnrollment(userID uint, userType, accountName string) (string, string, error) {
+	existing, err := GetTwoFactor(userID, userType)
+	if err != nil {
+		return "", "", err
+	}
+	if existing != nil && existing.EnabledAt != nil {
+		return "", "", ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if existing != nil {
+			existing.Secret = secret
+			existing.LastUsedStep = 0
+			return tx.Save(existing).Error
+		}
+
+		return tx.Create(&models.TwoFactor{
+			UserID:   userID,
+			UserType: userType,
+			Secret:   secret,
+		}).Error
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	uri := utils.TOTPProvisioningURI(config.Config.Security.TOTPIssuer, accountName, secret)
+	return secret, uri, nil
+}
+
+// ResumeTwoFactorEnrollment returns the pending TOTP secret of a principal, starting an enrollment
+// only if none is pending, so repeated logins do not invalidate a half-configured authenticator app
+func ResumeTwoFactorEnrollment(userID uint, userType, accountName string) (string, string, error) {
+	existing, err := GetTwoFactor(userID, userType)
+	if err != nil {
+		return "", "", err
+	}
+	if existing == nil {
+		return BeginTwoFactorEnrollment(userID, userType, accountName)
+	}
+	if existing.EnabledAt != nil {
+		return "", "", ErrTwoFactorAlreadyEnabled
+	}
+
+	uri := utils.TOTPProvisioningURI(config.Config.Security.TOTPIssuer, accountName, existing.Secret)
+	return existing.Secret, uri, nil
+}
+
+// ConfirmTwoFactorEnrollment enables a pending enrollment once the first code is valid
+// and returns a fresh set of recovery codes
+func ConfirmTwoFactorEnrollment(userID uint, userType, code string) ([]string, error) {
+	twoFactor, err := GetTwoFactor(userID, userType)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor == nil {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if twoFactor.EnabledAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	step, ok := utils.ValidateTOTPCode(twoFactor.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	var codes []string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		twoFactor.EnabledAt = &now
+		twoFactor.LastUsedStep = step
+		if err := tx.Save(twoFactor).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID, userType)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// VerifyTwoFactorCode checks a TOTP code for an enabled principal. A code can only be used once.
+func VerifyTwoFactorCode(userID uint, userType, code string) error {
+	twoFactor, err := GetTwoFactor(userID, userType)
+	if err != nil {
+		return err
+	}
+	if twoFactor == nil || twoFactor.EnabledAt == nil {
+		return ErrTwoFactorNotEnabled
+	}
+
+	step, err := checkTwoFactorCode(twoFactor, code, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// Conditional update also rejects the step if a concurrent request accepted it first
+	result := db.DB.Model(&models.TwoFactor{}).
+		Where("id = ? AND last_used_step < ?", twoFactor.ID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+// checkTwoFactorCode validates a TOTP code at the given time and rejects a code
+// whose time step is not newer than the last one accepted for the principal
+func checkTwoFactorCode(twoFactor *models.TwoFactor, code string, at time.Time) (int64, error) {
+	step, ok := utils.ValidateTOTPCode(twoFactor.Secret, code, at)
+	if !ok || step <= twoFactor.LastUsedStep {
+		return 0, ErrInvalidTwoFactorCode
+	}
+	return step, nil
+}
+
+// UseRecoveryCode consumes one of the principal's unused recovery codes
+func UseRecoveryCode(userID uint, userType, code string) error {
+	enabled, err := IsTwoFactorEnabled(userID, userType)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	var codes []models.RecoveryCode
+	if err := db.DB.Where("user_id = ? AND user_type = ? AND used_at IS NULL", userID, userType).
+		Find(&codes).Error; err != nil {
+		return err
+	}
+	recoveryCode := findRecoveryCode(codes, code)
+	if recoveryCode == nil {
+		return ErrInvalidTwoFactorCode
+	}
+
+	// Conditional update so a code used by a concurrent request cannot be used again
+	result := db.DB.Model(&models.RecoveryCode{}).
+		Where("id = ? AND used_at IS NULL", recoveryCode.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of a principal
+func RegenerateRecoveryCodes(userID uint, userType string) ([]string, error) {
+	enabled, err := IsTwoFactorEnabled(userID, userType)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	var codes []string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID, userType)
+		return err
+	})
+	return codes, err
+}
+
+// DisableTwoFactor removes the TOTP secret and recovery codes of a principal
+func DisableTwoFactor(userID uint, userType string) error {
+	required, err := IsTwoFactorRequired(userType)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrTwoFactorRequired
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND user_type = ?", userID, userType).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND user_type = ?", userID, userType).Delete(&models.TwoFactor{}).Error
+	})
+}
+
+// CheckPassword verifies the password of an admin or user
+func CheckPassword(userID uint, userType, password string) error {
+	var hash string
+	switch userType {
+	case "admin":
+		var admin models.Admin
+		if err := db.DB.Select("password").First(&admin, userID).Error; err != nil {
+			return err
+		}
+		hash = admin.Password
+	case "user":
+		var user models.User
+		if err := db.DB.Select("password").First(&user, userID).Error; err != nil {
+			return err
+		}
+		hash = user.Password
+	default:
+		return errors.New("unsupported user type")
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return errors.New("password is incorrect")
+	}
+	return nil
+}
+
+// TrackChallengeAttempt records an attempt made with a challenge token and
+// rejects tokens that were already used or have had too many attempts
+func TrackChallengeAttempt(challengeID string, expiresAt time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Drop expired challenges while we are here
+		if err := tx.Where("expires_at < ?", time.Now()).Delete(&models.TwoFactorChallenge{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.TwoFactorChallenge{
+			ChallengeID: challengeID,
+			ExpiresAt:   expiresAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Conditional increment so concurrent attempts on any instance share the same limit
+		result := tx.Model(&models.TwoFactorChallenge{}).
+			Where("challenge_id = ? AND consumed_at IS NULL AND attempts < ?", challengeID, maxTwoFactorChallengeTries).
+			Update("attempts", gorm.Expr("attempts + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrChallengeExhausted
+		}
+		return nil
+	})
+}
+
+// ConsumeChallenge marks a challenge token as used so it cannot complete another login
+func ConsumeChallenge(challengeID string) error {
+	result := db.DB.Model(&models.TwoFactorChallenge{}).
+		Where("challenge_id = ? AND consumed_at IS NULL", challengeID).
+		Update("consumed_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrChallengeExhausted
+	}
+	return nil
+}
+
+// replaceRecoveryCodes deletes existing recovery codes and stores new ones, returning the plaintext codes
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, userType string) ([]string, error) {
+	if err := tx.Where("user_id = ? AND user_type = ?", userID, userType).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := utils.GenerateSecureToken(8)
+		if err != nil {
+			return nil, err
+		}
+
+		code := raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+		if err := tx.Create(&models.RecoveryCode{
+			UserID:   userID,
+			UserType: userType,
+			CodeHash: utils.HashToken(normalizeRecoveryCode(code)),
+		}).Error; err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// findRecoveryCode returns the unused recovery code matching code, or nil if there is none
+func findRecoveryCode(codes []models.RecoveryCode, code string) *models.RecoveryCode {
+	hash := []byte(utils.HashToken(normalizeRecoveryCode(code)))
+	for i := range codes {
+		if codes[i].UsedAt == nil && subtle.ConstantTimeCompare([]byte(codes[i].CodeHash), hash) == 1 {
+			return &codes[i]
+		}
+	}
+	return nil
+}
+
+// normalizeRecoveryCode strips separators so codes can be typed with or without dashes
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
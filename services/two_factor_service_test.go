@@ -0,0 +1,69 @@
+package services
+
+import (
+	"dashboard-starter/models"
+	"dashboard-starter/utils"
+	"errors"
+	"testing"
+	"time"
+)
+
+// RFC 6238 Appendix B seed and its code at T=1111111109 (step 37037036), truncated to 6 digits
+const (
+	testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	testTOTPCode   = "081804"
+	testTOTPStep   = 37037036
+)
+
+func TestCheckTwoFactorCodeRejectsReusedStep(t *testing.T) {
+	at := time.Unix(1111111109, 0)
+	twoFactor := &models.TwoFactor{Secret: testTOTPSecret}
+
+	step, err := checkTwoFactorCode(twoFactor, testTOTPCode, at)
+	if err != nil || step != testTOTPStep {
+		t.Fatalf("expected the code to be accepted at step %d, got %d, %v", testTOTPStep, step, err)
+	}
+
+	// The same code stays inside the ±1 step window for the next 30 seconds but must not be accepted again
+	twoFactor.LastUsedStep = step
+	if _, err := checkTwoFactorCode(twoFactor, testTOTPCode, at.Add(30*time.Second)); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("expected a reused code to be rejected, got %v", err)
+	}
+
+	// A code from a step before the last accepted one is rejected too
+	twoFactor.LastUsedStep = step + 1
+	if _, err := checkTwoFactorCode(twoFactor, testTOTPCode, at); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("expected an older code to be rejected, got %v", err)
+	}
+
+	if _, err := checkTwoFactorCode(&models.TwoFactor{Secret: testTOTPSecret}, "000000", at); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("expected a wrong code to be rejected, got %v", err)
+	}
+}
+
+func TestFindRecoveryCodeIsSingleUse(t *testing.T) {
+	codes := []models.RecoveryCode{
+		{ID: 1, CodeHash: utils.HashToken("aaaabbbbccccdddd")},
+		{ID: 2, CodeHash: utils.HashToken("1111222233334444")},
+	}
+
+	found := findRecoveryCode(codes, "1111-2222-3333-4444")
+	if found == nil || found.ID != 2 {
+		t.Fatalf("expected the second code to match, got %+v", found)
+	}
+	if again := findRecoveryCode(codes, " 1111 2222 3333 4444 "); again == nil || again.ID != 2 {
+		t.Fatalf("expected the code to match without dashes, got %+v", again)
+	}
+
+	usedAt := time.Now()
+	found.UsedAt = &usedAt
+	if again := findRecoveryCode(codes, "1111-2222-3333-4444"); again != nil {
+		t.Fatalf("expected a used code to be rejected, got %+v", again)
+	}
+	if other := findRecoveryCode(codes, "AAAA-BBBB-CCCC-DDDD"); other == nil || other.ID != 1 {
+		t.Fatalf("expected the other code to stay usable, got %+v", other)
+	}
+	if unknown := findRecoveryCode(codes, "9999-9999-9999-9999"); unknown != nil {
+		t.Fatalf("expected an unknown code to be rejected, got %+v", unknown)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"dashboard-starter/utils"
 	"errors"
 	"strconv"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -77,13 +78,15 @@ func (s *UserService) CreateUser(input *models.UserInput, adminID uint) (*models
 		return nil, err
 	}
 
-	// Create new user
+	// Create new user (accounts created by an admin are trusted as verified)
+	now := time.Now()
 	user := &models.User{
-		Name:         input.Name,
-		Email:        input.Email,
-		Password:     string(hashedPassword),
-		TokenVersion: 1,
-		AdminID:      adminID, // Set the admin ID who created this user
+		Name:            input.Name,
+		Email:           input.Email,
+		Password:        string(hashedPassword),
+		TokenVersion:    1,
+		AdminID:         adminID, // Set the admin ID who created this user
+		EmailVerifiedAt: &now,
 	}
 
 	// Save to database
@@ -206,9 +209,15 @@ func (s *UserService) UpdateUserProfile(userID uint, input *models.UserInput) (*
 		}
 	}
 
+	// A new email address has to be verified again
+	emailChanged := input.Email != user.Email
+
 	// Update user data
 	user.Name = input.Name
 	user.Email = input.Email
+	if emailChanged {
+		user.EmailVerifiedAt = nil
+	}
 
 	// Save changes
 	err = db.Transaction(func(tx *gorm.DB) error {
@@ -219,11 +228,18 @@ func (s *UserService) UpdateUserProfile(userID uint, input *models.UserInput) (*
 		return nil, err
 	}
 
+	if emailChanged {
+		if err := s.SendVerificationEmail(user); err != nil {
+			utils.Error("Failed to send verification email to user %d: %v", user.ID, err)
+		}
+	}
+
 	return user, nil
 }
 
-// ChangeUserPassword changes a user's password
-func (s *UserService) ChangeUserPassword(userID uint, currentPassword, newPassword string) error {
+// ChangeUserPassword changes a user's password and signs out their other sessions.
+// The session keepSessionID (the caller's token family) stays signed in if it is set.
+func (s *UserService) ChangeUserPassword(userID uint, currentPassword, newPassword, keepSessionID string) error {
 	// Find user
 	user, err := s.repo.FindByID(userID)
 	if err != nil {
@@ -232,7 +248,7 @@ func (s *UserService) ChangeUserPassword(userID uint, currentPassword, newPasswo
 
 	// Verify current password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(currentPassword)); err != nil {
-		return errors.New("current password is incorrect")
+		return ErrIncorrectPassword
 	}
 
 	// Hash new password
@@ -242,11 +258,24 @@ func (s *UserService) ChangeUserPassword(userID uint, currentPassword, newPasswo
 	}
 
 	// Update password and token version
-	return db.Transaction(func(tx *gorm.DB) error {
+	err = db.Transaction(func(tx *gorm.DB) error {
 		user.Password = string(hashedPassword)
 		user.TokenVersion += 1 // Invalidate existing tokens
-		return s.repo.Update(user)
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+		return revokeAllPersonalAccessTokens(tx, user.ID, "user")
 	})
+	if err != nil {
+		return err
+	}
+
+	// Sessions started with the old password must not survive the change
+	var keepFamilies []string
+	if keepSessionID != "" {
+		keepFamilies = append(keepFamilies, keepSessionID)
+	}
+	return RevokeAllRefreshTokens(user.ID, "user", keepFamilies...)
 }
 
 // ResetUserPassword resets a user's password (admin only)
@@ -279,13 +308,21 @@ func (s *UserService) ResetUserPassword(id string, adminID uint) (string, error)
 	err = db.Transaction(func(tx *gorm.DB) error {
 		user.Password = string(hashedPassword)
 		user.TokenVersion += 1 // Invalidate existing tokens
-		return s.repo.Update(user)
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+		return revokeAllPersonalAccessTokens(tx, user.ID, "user")
 	})
 
 	if err != nil {
 		return "", err
 	}
 
+	// Sessions started with the old password must not survive the reset
+	if err := RevokeAllRefreshTokens(user.ID, "user"); err != nil {
+		return "", err
+	}
+
 	return newPassword, nil
 }
 
@@ -308,13 +345,17 @@ func (s *UserService) DeleteUser(id string, adminID uint) error {
 		return errors.New("you don't have permission to delete this user")
 	}
 
-	// Delete user and their refresh tokens in a transaction
+	// Delete user, their refresh tokens and personal access tokens in a transaction
 	return db.Transaction(func(tx *gorm.DB) error {
 		// First revoke all refresh tokens
 		if err := tx.Where("user_id = ? AND user_type = ?", user.ID, "user").Delete(&models.RefreshToken{}).Error; err != nil {
 			return err
 		}
 
+		if err := tx.Where("user_id = ? AND user_type = ?", user.ID, "user").Delete(&models.PersonalAccessToken{}).Error; err != nil {
+			return err
+		}
+
 		// Then delete the user
 		return s.repo.Delete(uint(idUint))
 	})
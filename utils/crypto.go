@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+// GenerateSecureToken returns a hex encoded random string built from byteLength random bytes
+func GenerateSecureToken(byteLength int) (string, error) {
+	bytes := make([]byte, byteLength)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// HashToken returns the hex encoded SHA-256 digest of a token
+// ใช้สำหรับเก็บ token ลงฐานข้อมูลโดยไม่ต้องเก็บค่าจริง
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CompareTokenHash checks a raw token against a stored digest in constant time
+func CompareTokenHash(token, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashToken(token)), []byte(hash)) == 1
+}
+
+// HashSecret returns a salted SHA-256 digest of a random secret such as an API key,
+// encoded as "<salt>$<digest>" in hex. Not suitable for user chosen passwords, use bcrypt for those.
+func HashSecret(secret string) (string, error) {
+	salt, err := GenerateSecureToken(16)
+	if err != nil {
+		return "", err
+	}
+	return salt + "$" + saltedDigest(salt, secret), nil
+}
+
+// CompareSecretHash checks a secret against a HashSecret digest in constant time
+func CompareSecretHash(secret, stored string) bool {
+	salt, digest, ok := strings.Cut(stored, "$")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(saltedDigest(salt, secret)), []byte(digest)) == 1
+}
+
+// saltedDigest returns the hex encoded SHA-256 digest of salt and secret
+func saltedDigest(salt, secret string) string {
+	sum := sha256.Sum256([]byte(salt + secret))
+	return hex.EncodeToString(sum[:])
+}
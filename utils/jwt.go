@@ -3,27 +3,75 @@ package utils
 import (
 	"dashboard-starter/config"
 	"errors"
+	"fmt"
+	"log"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtKey []byte
-
-// InitJWT initializes the JWT signing key
+// InitJWT initializes the JWT signing and verification keys
 func InitJWT() error {
-	// Get JWT secret from config
-	secret := config.Config.JWT.Secret
-	if secret == "" {
-		return errors.New("JWT_SECRET not set in environment")
+	cfg := config.Config.JWT
+
+	var hmacKey []byte
+	if cfg.Secret != "" {
+		hmacKey = []byte(cfg.Secret)
+	}
+
+	// HS256 only needs the shared secret
+	if cfg.Algorithm == "" || cfg.Algorithm == "HS256" {
+		if hmacKey == nil {
+			return errors.New("JWT_SECRET not set in environment")
+		}
+
+		jwtKeys.mu.Lock()
+		jwtKeys.hmacKey = hmacKey
+		jwtKeys.signingKey = nil
+		jwtKeys.keys = map[string]*jwtSigningKey{}
+		jwtKeys.mu.Unlock()
+		return nil
+	}
+
+	if cfg.Algorithm != "RS256" && cfg.Algorithm != "EdDSA" {
+		return fmt.Errorf("unsupported JWT_ALGORITHM %q", cfg.Algorithm)
 	}
 
-	jwtKey = []byte(secret)
+	keys, err := loadJWTKeys(cfg.KeyDir)
+	if err != nil {
+		return err
+	}
+
+	signingKey, err := selectSigningKey(keys, cfg.Algorithm, cfg.SigningKeyID)
+	if err != nil {
+		return err
+	}
+
+	// The shared secret is kept for verification only, and only until JWT_LEGACY_HS256_UNTIL,
+	// so HS256 tokens issued before switching algorithms stay valid until they expire
+	// without letting holders of the old secret mint new tokens indefinitely
+	if !cfg.LegacyHS256Until.After(time.Now()) {
+		hmacKey = nil
+	} else if hmacKey == nil {
+		return errors.New("JWT_LEGACY_HS256_UNTIL requires JWT_SECRET")
+	} else {
+		log.Printf("Accepting legacy HS256 tokens until %s", cfg.LegacyHS256Until.Format(time.RFC3339))
+	}
+
+	jwtKeys.mu.Lock()
+	jwtKeys.hmacKey = hmacKey
+	jwtKeys.hmacUntil = cfg.LegacyHS256Until
+	jwtKeys.signingKey = signingKey
+	jwtKeys.keys = keys
+	jwtKeys.mu.Unlock()
+
+	log.Printf("JWT signing with %s key %q, %d key(s) loaded for verification", cfg.Algorithm, signingKey.ID, len(keys))
 	return nil
 }
 
-// GenerateToken creates a new JWT token for the specified admin
-func GenerateToken(userID uint, userType string, version int) (string, time.Time, error) {
+// GenerateToken creates a new JWT token for the specified admin.
+// The session ID (the refresh token family) is stored as the sid claim so ending the session revokes the token.
+func GenerateToken(userID uint, userType string, version int, sessionID string) (string, time.Time, error) {
 	// Calculate expiration time
 	expiryMinutes := config.Config.JWT.ExpiryMinutes
 	expiryTime := time.Now().Add(time.Duration(expiryMinutes) * time.Minute)
@@ -37,12 +85,40 @@ func GenerateToken(userID uint, userType string, version int) (string, time.Time
 		"exp":           expiryTime.Unix(),
 		"iat":           time.Now().Unix(),
 	}
+	if sessionID != "" {
+		claims["sid"] = sessionID
+	}
+
+	// Sign the token with the current signing key
+	tokenString, err := signClaims(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
 
-	// Create token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return tokenString, expiryTime, nil
+}
 
-	// Sign the token
-	tokenString, err := token.SignedString(jwtKey)
+// GenerateImpersonationToken creates a short-lived access token for a user with an act (actor)
+// claim naming the admin acting on their behalf (RFC 8693 section 4.1). The session ID is stored
+// as the jti so the impersonation can be ended before the token expires.
+func GenerateImpersonationToken(userID uint, version int, actor TokenActor, sessionID string, ttl time.Duration) (string, time.Time, error) {
+	expiryTime := time.Now().Add(ttl)
+
+	claims := jwt.MapClaims{
+		"user_id":       userID,
+		"user_type":     "user",
+		"token_version": version,
+		"token_type":    "access",
+		"jti":           sessionID,
+		"act": map[string]interface{}{
+			"user_id":   actor.UserID,
+			"user_type": actor.UserType,
+		},
+		"exp": expiryTime.Unix(),
+		"iat": time.Now().Unix(),
+	}
+
+	tokenString, err := signClaims(claims)
 	if err != nil {
 		return "", time.Time{}, err
 	}
@@ -50,19 +126,40 @@ func GenerateToken(userID uint, userType string, version int) (string, time.Time
 	return tokenString, expiryTime, nil
 }
 
+// TokenActor identifies the principal acting on behalf of the token's subject
+type TokenActor struct {
+	UserID   uint
+	UserType string
+}
+
+// AccessTokenClaims are the claims of a validated access token
+type AccessTokenClaims struct {
+	UserID       uint
+	UserType     string
+	TokenVersion int
+	ExpiresAt    time.Time
+	IssuedAt     time.Time
+	TokenID      string      // jti, only set on impersonation tokens
+	SessionID    string      // sid, the refresh token family the token was issued with
+	Actor        *TokenActor // Set when an admin is impersonating the user
+}
+
 // ParseToken validates a JWT token and returns the admin ID and token version
 func ParseToken(tokenStr string) (uint, string, int, error) {
+	claims, err := ParseAccessToken(tokenStr)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	return claims.UserID, claims.UserType, claims.TokenVersion, nil
+}
+
+// ParseAccessToken validates an access token and returns its claims
+func ParseAccessToken(tokenStr string) (*AccessTokenClaims, error) {
 	// Parse the token
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtKey, nil
-	})
+	token, err := jwt.Parse(tokenStr, verificationKey)
 
 	if err != nil {
-		return 0, "", 0, err
+		return nil, err
 	}
 
 	// Validate token and extract claims
@@ -70,77 +167,110 @@ func ParseToken(tokenStr string) (uint, string, int, error) {
 		// Check token expiration
 		exp, ok := claims["exp"].(float64)
 		if !ok {
-			return 0, "", 0, errors.New("missing expiration time")
+			return nil, errors.New("missing expiration time")
 		}
 
 		if time.Now().Unix() > int64(exp) {
-			return 0, "", 0, errors.New("token expired")
+			return nil, errors.New("token expired")
+		}
+
+		// Only access tokens may be used to authenticate requests
+		if tokenType, ok := claims["token_type"].(string); ok && tokenType != "access" {
+			return nil, errors.New("invalid token type")
 		}
 
 		// Extract user ID
 		id, ok1 := claims["user_id"].(float64)
 		if !ok1 {
-			return 0, "", 0, errors.New("invalid user ID")
+			return nil, errors.New("invalid user ID")
 		}
 
 		// Extract user type
 		userType, ok2 := claims["user_type"].(string)
 		if !ok2 {
-			return 0, "", 0, errors.New("invalid user type")
+			return nil, errors.New("invalid user type")
 		}
 
 		// Extract token version
 		ver, ok3 := claims["token_version"].(float64)
 		if !ok3 {
-			return 0, "", 0, errors.New("invalid token version")
+			return nil, errors.New("invalid token version")
 		}
 
-		return uint(id), userType, int(ver), nil
+		iat, _ := claims["iat"].(float64)
+		jti, _ := claims["jti"].(string)
+		sid, _ := claims["sid"].(string)
+
+		// Extract the actor of an impersonation token
+		var actor *TokenActor
+		if act, ok := claims["act"]; ok {
+			actClaims, ok := act.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("invalid actor claim")
+			}
+			actorID, ok1 := actClaims["user_id"].(float64)
+			actorType, ok2 := actClaims["user_type"].(string)
+			if !ok1 || !ok2 || jti == "" {
+				return nil, errors.New("invalid actor claim")
+			}
+			actor = &TokenActor{UserID: uint(actorID), UserType: actorType}
+		}
+
+		return &AccessTokenClaims{
+			UserID:       uint(id),
+			UserType:     userType,
+			TokenVersion: int(ver),
+			ExpiresAt:    time.Unix(int64(exp), 0),
+			IssuedAt:     time.Unix(int64(iat), 0),
+			TokenID:      jti,
+			SessionID:    sid,
+			Actor:        actor,
+		}, nil
 	}
 
-	return 0, "", 0, errors.New("invalid token")
+	return nil, errors.New("invalid token")
 }
 
 // GenerateRefreshToken creates a refresh token for the specified user
-func GenerateRefreshToken(userID uint, userType string) (string, time.Time, error) {
+// and returns the token together with its token ID (jti)
+func GenerateRefreshToken(userID uint, userType string) (string, string, time.Time, error) {
 	// Calculate expiration time (1 year)
 	expiryDays := 365 // 1 year
 	expiryTime := time.Now().Add(time.Duration(expiryDays) * 24 * time.Hour)
 
+	// Unique token ID so that tokens issued within the same second never collide
+	jti, err := GenerateSecureToken(16)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
 	// Create claims
 	claims := jwt.MapClaims{
 		"user_id":    userID,
 		"user_type":  userType,  // Add user type
 		"token_type": "refresh", // Specify it's a refresh token
+		"jti":        jti,
 		"exp":        expiryTime.Unix(),
 		"iat":        time.Now().Unix(),
 	}
 
-	// Create token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Sign the token
-	tokenString, err := token.SignedString(jwtKey)
+	// Sign the token with the current signing key
+	tokenString, err := signClaims(claims)
 	if err != nil {
-		return "", time.Time{}, err
+		return "", "", time.Time{}, err
 	}
 
-	return tokenString, expiryTime, nil
+	return tokenString, jti, expiryTime, nil
 }
 
 // ParseRefreshToken validates a refresh token and returns the user information
-func ParseRefreshToken(tokenStr string) (uint, string, error) {
+// together with the token ID used to look the token up in the database
+func ParseRefreshToken(tokenStr string) (uint, string, string, error) {
 	// Parse the token
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtKey, nil
-	})
+	token, err := jwt.Parse(tokenStr, verificationKey)
 
 	if err != nil {
-		return 0, "", err
+		return 0, "", "", err
 	}
 
 	// Validate token and extract claims
@@ -148,33 +278,156 @@ func ParseRefreshToken(tokenStr string) (uint, string, error) {
 		// Check token expiration
 		exp, ok := claims["exp"].(float64)
 		if !ok {
-			return 0, "", errors.New("missing expiration time")
+			return 0, "", "", errors.New("missing expiration time")
 		}
 
 		if time.Now().Unix() > int64(exp) {
-			return 0, "", errors.New("token expired")
+			return 0, "", "", errors.New("token expired")
 		}
 
 		// Extract user ID
 		id, ok := claims["user_id"].(float64)
 		if !ok {
-			return 0, "", errors.New("invalid user ID")
+			return 0, "", "", errors.New("invalid user ID")
 		}
 
 		// Extract user type
 		userType, ok := claims["user_type"].(string)
 		if !ok {
-			return 0, "", errors.New("invalid user type")
+			return 0, "", "", errors.New("invalid user type")
 		}
 
 		// Verify token type
 		tokenType, ok := claims["token_type"].(string)
 		if !ok || tokenType != "refresh" {
-			return 0, "", errors.New("invalid token type")
+			return 0, "", "", errors.New("invalid token type")
 		}
 
-		return uint(id), userType, nil
+		return uint(id), userType, refreshTokenID(claims, tokenStr), nil
+	}
+
+	return 0, "", "", errors.New("invalid token")
+}
+
+// RefreshTokenID extracts the lookup ID of a refresh token without verifying its signature.
+// Only use this on tokens that come from a trusted source such as our own database.
+func RefreshTokenID(tokenStr string) string {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenStr, claims); err != nil {
+		return HashToken(tokenStr)
+	}
+	return refreshTokenID(claims, tokenStr)
+}
+
+// refreshTokenID returns the jti claim, falling back to the token digest for tokens issued without one
+func refreshTokenID(claims jwt.MapClaims, tokenStr string) string {
+	if jti, ok := claims["jti"].(string); ok && jti != "" {
+		return jti
+	}
+	return HashToken(tokenStr)
+}
+
+// GenerateChallengeToken creates a short-lived token that proves the password step of a
+// two-factor login succeeded. It cannot be used as an access token.
+func GenerateChallengeToken(userID uint, userType string, ttl time.Duration) (string, time.Time, error) {
+	expiryTime := time.Now().Add(ttl)
+
+	jti, err := GenerateSecureToken(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	claims := jwt.MapClaims{
+		"user_id":    userID,
+		"user_type":  userType,
+		"token_type": "2fa_challenge",
+		"jti":        jti,
+		"exp":        expiryTime.Unix(),
+		"iat":        time.Now().Unix(),
+	}
+
+	tokenString, err := signClaims(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expiryTime, nil
+}
+
+// ParseChallengeToken validates a two-factor challenge token and returns the user information and token ID
+func ParseChallengeToken(tokenStr string) (uint, string, string, error) {
+	claims, err := parseTypedToken(tokenStr, "2fa_challenge")
+	if err != nil {
+		return 0, "", "", err
+	}
+
+	id, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, "", "", errors.New("invalid user ID")
+	}
+
+	userType, ok := claims["user_type"].(string)
+	if !ok {
+		return 0, "", "", errors.New("invalid user type")
+	}
+
+	jti, ok := claims["jti"].(string)
+	if !ok || jti == "" {
+		return 0, "", "", errors.New("invalid token ID")
+	}
+
+	return uint(id), userType, jti, nil
+}
+
+// GenerateEmailVerificationToken creates a signed token for an email verification link.
+// The email is included so the link stops working if the user changes their address.
+func GenerateEmailVerificationToken(userID uint, email string, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id":    userID,
+		"email":      email,
+		"token_type": "email_verification",
+		"exp":        time.Now().Add(ttl).Unix(),
+		"iat":        time.Now().Unix(),
+	}
+
+	return signClaims(claims)
+}
+
+// ParseEmailVerificationToken validates an email verification token and returns the user ID and email
+func ParseEmailVerificationToken(tokenStr string) (uint, string, error) {
+	claims, err := parseTypedToken(tokenStr, "email_verification")
+	if err != nil {
+		return 0, "", err
+	}
+
+	id, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, "", errors.New("invalid user ID")
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok || email == "" {
+		return 0, "", errors.New("invalid email")
+	}
+
+	return uint(id), email, nil
+}
+
+// parseTypedToken verifies a token and checks that its token_type claim matches
+func parseTypedToken(tokenStr, tokenType string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenStr, verificationKey)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	if claimType, ok := claims["token_type"].(string); !ok || claimType != tokenType {
+		return nil, errors.New("invalid token type")
 	}
 
-	return 0, "", errors.New("invalid token")
+	return claims, nil
 }
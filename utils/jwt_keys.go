@@ -0,0 +1,308 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwtSigningKey is a key loaded from the key directory.
+// Keys without a private part can only be used to verify tokens (retired keys).
+type jwtSigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.PrivateKey
+	PublicKey  crypto.PublicKey
+}
+
+// jwtKeySet holds every key the application can verify with and the key currently used for signing
+type jwtKeySet struct {
+	mu         sync.RWMutex
+	hmacKey    []byte
+	signingKey *jwtSigningKey
+	keys       map[string]*jwtSigningKey
+	// hmacUntil ends verification with hmacKey after switching to an asymmetric signing key
+	hmacUntil time.Time
+}
+
+var jwtKeys = &jwtKeySet{keys: map[string]*jwtSigningKey{}}
+
+// JWK represents a single public key in a JSON Web Key Set (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet represents a JSON Web Key Set
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// loadJWTKeys loads every *.pem file in dir. The file name without extension is used as kid,
+// so "2025-06.pem" and "2025-06.pub.pem" both describe the key "2025-06".
+func loadJWTKeys(dir string) (map[string]*jwtSigningKey, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*jwtSigningKey{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		kid := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(file), ".pem"), ".pub")
+		key, err := parseJWTKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load JWT key %s: %w", file, err)
+		}
+
+		// A private key always wins over a public-only file with the same kid
+		if existing, ok := keys[kid]; ok && existing.PrivateKey != nil {
+			continue
+		}
+		keys[kid] = key
+	}
+
+	return keys, nil
+}
+
+// parseJWTKey parses a PEM encoded RSA or Ed25519 private or public key
+func parseJWTKey(kid string, data []byte) (*jwtSigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &jwtSigningKey{ID: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.PrivateKey, key.PublicKey = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.PublicKey = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.PrivateKey, key.PublicKey = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.PublicKey = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, errors.New("unsupported key type, only RSA and Ed25519 keys are supported")
+	}
+
+	return key, nil
+}
+
+// selectSigningKey picks the key used for signing new tokens
+func selectSigningKey(keys map[string]*jwtSigningKey, algorithm, kid string) (*jwtSigningKey, error) {
+	if kid != "" {
+		key, ok := keys[kid]
+		if !ok || key.PrivateKey == nil {
+			return nil, fmt.Errorf("signing key %q not found or has no private key", kid)
+		}
+		if key.Method.Alg() != algorithm {
+			return nil, fmt.Errorf("signing key %q is not a %s key", kid, algorithm)
+		}
+		return key, nil
+	}
+
+	// Without an explicit kid use the last private key by name, e.g. the newest dated key
+	var candidates []string
+	for id, key := range keys {
+		if key.PrivateKey != nil && key.Method.Alg() == algorithm {
+			candidates = append(candidates, id)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no %s private key found", algorithm)
+	}
+	sort.Strings(candidates)
+
+	return keys[candidates[len(candidates)-1]], nil
+}
+
+// signClaims signs claims with the current signing key and sets the kid header for asymmetric keys
+func signClaims(claims jwt.Claims) (string, error) {
+	jwtKeys.mu.RLock()
+	defer jwtKeys.mu.RUnlock()
+
+	if jwtKeys.signingKey == nil {
+		if jwtKeys.hmacKey == nil {
+			return "", errors.New("JWT signing key not initialized")
+		}
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtKeys.hmacKey)
+	}
+
+	token := jwt.NewWithClaims(jwtKeys.signingKey.Method, claims)
+	token.Header["kid"] = jwtKeys.signingKey.ID
+	return token.SignedString(jwtKeys.signingKey.PrivateKey)
+}
+
+// verificationKey is the jwt.Keyfunc used for every token we parse.
+// Tokens with a kid are checked against the key set, including retired keys,
+// and tokens without a kid use the HS256 shared secret. After switching to RS256/EdDSA
+// the shared secret is only accepted until JWT_LEGACY_HS256_UNTIL.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	jwtKeys.mu.RLock()
+	defer jwtKeys.mu.RUnlock()
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || jwtKeys.hmacKey == nil {
+			return nil, errors.New("unexpected signing method")
+		}
+		if jwtKeys.signingKey != nil {
+			if !time.Now().Before(jwtKeys.hmacUntil) {
+				return nil, errors.New("legacy HS256 tokens are no longer accepted")
+			}
+			claims, _ := token.Claims.(jwt.MapClaims)
+			Warn("Accepted legacy HS256 token: user_id=%v user_type=%v", claims["user_id"], claims["user_type"])
+		}
+		return jwtKeys.hmacKey, nil
+	}
+
+	key, ok := jwtKeys.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+
+	return key.PublicKey, nil
+}
+
+// GetJWKS returns the public keys that tokens may be verified with
+func GetJWKS() JWKSet {
+	jwtKeys.mu.RLock()
+	defer jwtKeys.mu.RUnlock()
+
+	ids := make([]string, 0, len(jwtKeys.keys))
+	for id := range jwtKeys.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, id := range ids {
+		key := jwtKeys.keys[id]
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+
+		switch pub := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+// PublicKey decodes an RSA, EC or Ed25519 key published by another issuer's JWKS endpoint
+func (jwk JWK) PublicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported EC curve %q", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return key, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve %q", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key length")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
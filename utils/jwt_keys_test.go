@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"dashboard-starter/config"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// useAsymmetricKeys signs with a fresh Ed25519 key and accepts HS256 tokens signed with secret until hmacUntil
+func useAsymmetricKeys(t *testing.T, secret []byte, hmacUntil time.Time) {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	key := &jwtSigningKey{ID: "test", Method: jwt.SigningMethodEdDSA, PrivateKey: private, PublicKey: public}
+
+	jwtKeys.mu.Lock()
+	previousHMAC, previousUntil := jwtKeys.hmacKey, jwtKeys.hmacUntil
+	previousSigning, previousKeys := jwtKeys.signingKey, jwtKeys.keys
+	jwtKeys.hmacKey = secret
+	jwtKeys.hmacUntil = hmacUntil
+	jwtKeys.signingKey = key
+	jwtKeys.keys = map[string]*jwtSigningKey{key.ID: key}
+	jwtKeys.mu.Unlock()
+
+	t.Cleanup(func() {
+		jwtKeys.mu.Lock()
+		jwtKeys.hmacKey, jwtKeys.hmacUntil = previousHMAC, previousUntil
+		jwtKeys.signingKey, jwtKeys.keys = previousSigning, previousKeys
+		jwtKeys.mu.Unlock()
+	})
+}
+
+func legacyHS256Token(t *testing.T, secret []byte) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":       1,
+		"user_type":     "admin",
+		"token_version": 0,
+		"token_type":    "access",
+		"exp":           time.Now().Add(time.Hour).Unix(),
+		"iat":           time.Now().Unix(),
+	}).SignedString(secret)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return token
+}
+
+func TestLegacyHS256TokensAcceptedUntilCutoff(t *testing.T) {
+	secret := []byte("legacy-secret")
+	useAsymmetricKeys(t, secret, time.Now().Add(time.Hour))
+
+	if _, err := ParseAccessToken(legacyHS256Token(t, secret)); err != nil {
+		t.Fatalf("expected a legacy token to be accepted before the cutoff, got %v", err)
+	}
+
+	previous := config.Config.JWT
+	t.Cleanup(func() { config.Config.JWT = previous })
+	config.Config.JWT.ExpiryMinutes = 5
+
+	current, _, err := GenerateToken(1, "admin", 0, "")
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if _, err := ParseAccessToken(current); err != nil {
+		t.Fatalf("expected a token signed with the current key to be accepted, got %v", err)
+	}
+}
+
+func TestLegacyHS256TokensRejectedAfterCutoff(t *testing.T) {
+	secret := []byte("legacy-secret")
+	useAsymmetricKeys(t, secret, time.Now().Add(-time.Second))
+
+	if _, err := ParseAccessToken(legacyHS256Token(t, secret)); err == nil {
+		t.Fatal("expected a legacy token to be rejected after the cutoff")
+	}
+}
+
+func TestLegacyHS256TokensRejectedWithoutCutoff(t *testing.T) {
+	useAsymmetricKeys(t, nil, time.Time{})
+
+	if _, err := ParseAccessToken(legacyHS256Token(t, []byte("legacy-secret"))); err == nil {
+		t.Fatal("expected a legacy token to be rejected when no cutoff is configured")
+	}
+}
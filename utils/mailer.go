@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"dashboard-starter/config"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MailMessage is a plain text email
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email messages
+type Mailer interface {
+	Send(msg MailMessage) error
+}
+
+// SMTPMailer sends mail through an SMTP server
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers the message with net/smtp, using PLAIN auth when a username is configured
+func (m *SMTPMailer) Send(msg MailMessage) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := fmt.Sprintf("%s:%d", m.Host, m.Port)
+	return smtp.SendMail(addr, auth, m.From, []string{msg.To}, buildMailData(m.From, msg))
+}
+
+// LogMailer writes messages to the application log instead of sending them (development)
+type LogMailer struct{}
+
+// Send logs the message
+func (m *LogMailer) Send(msg MailMessage) error {
+	log.Printf("[mail] To: %s | Subject: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer writes each message as an .eml file into a directory (development and testing)
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+// Send writes the message to a new file in Dir
+func (m *FileMailer) Send(msg MailMessage) error {
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return err
+	}
+
+	suffix, err := GenerateSecureToken(4)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s_%s.eml", time.Now().Format("20060102T150405"), suffix)
+	return os.WriteFile(filepath.Join(m.Dir, name), buildMailData(m.From, msg), 0644)
+}
+
+var (
+	mailer      Mailer = &LogMailer{}
+	mailerMutex sync.RWMutex
+)
+
+// InitMailer creates the mailer selected by MAIL_DRIVER
+func InitMailer() error {
+	cfg := config.Config.Mail
+
+	var m Mailer
+	switch cfg.Driver {
+	case "", "log":
+		m = &LogMailer{}
+	case "smtp":
+		m = &SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+		}
+	case "file":
+		m = &FileMailer{Dir: cfg.FileDir, From: cfg.From}
+	default:
+		return fmt.Errorf("unsupported MAIL_DRIVER %q", cfg.Driver)
+	}
+
+	SetMailer(m)
+	return nil
+}
+
+// SetMailer replaces the mailer used by SendMail, e.g. with a fake in tests
+func SetMailer(m Mailer) {
+	mailerMutex.Lock()
+	defer mailerMutex.Unlock()
+	mailer = m
+}
+
+// SendMail sends a message with the configured mailer
+func SendMail(msg MailMessage) error {
+	mailerMutex.RLock()
+	m := mailer
+	mailerMutex.RUnlock()
+
+	return m.Send(msg)
+}
+
+// SendMailAsync sends a message in the background and logs failures.
+// Used where the response must not depend on whether a message was sent.
+func SendMailAsync(msg MailMessage) {
+	go func() {
+		if err := SendMail(msg); err != nil {
+			Error("Failed to send mail to %s: %v", msg.To, err)
+		}
+	}()
+}
+
+// buildMailData renders the RFC 5322 message with headers
+func buildMailData(from string, msg MailMessage) []byte {
+	// ป้องกัน header injection
+	clean := func(value string) string {
+		return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+	}
+
+	var b strings.Builder
+	b.WriteString("From: " + clean(from) + "\r\n")
+	b.WriteString("To: " + clean(msg.To) + "\r\n")
+	b.WriteString("Subject: " + clean(msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return []byte(b.String())
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailerWritesMessage(t *testing.T) {
+	dir := t.TempDir()
+	m := &FileMailer{Dir: dir, From: "noreply@example.com"}
+
+	err := m.Send(MailMessage{
+		To:      "user@example.com",
+		Subject: "Hello\r\nBcc: attacker@example.com",
+		Body:    "line one\nline two",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one .eml file, got %v (%v)", files, err)
+	}
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	content := string(data)
+
+	if !strings.Contains(content, "To: user@example.com\r\n") {
+		t.Errorf("missing To header:\n%s", content)
+	}
+	if strings.Contains(content, "\r\nBcc:") {
+		t.Errorf("header injection was not stripped:\n%s", content)
+	}
+	if !strings.HasSuffix(content, "\r\n\r\nline one\r\nline two") {
+		t.Errorf("body not written with CRLF line endings:\n%q", content)
+	}
+}
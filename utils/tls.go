@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"dashboard-starter/config"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// clientCAPool holds the CAs client certificates are verified against, nil when mTLS is disabled
+var clientCAPool *x509.CertPool
+
+// InitTLS builds the server TLS configuration from SERVER_TLS_* settings.
+// Returns nil when TLS is not configured and the server should listen on plain HTTP.
+func InitTLS() (*tls.Config, error) {
+	cfg := config.Config.Server
+	clientCAPool = nil
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		if cfg.TLSClientCAFile != "" {
+			return nil, errors.New("SERVER_TLS_CLIENT_CA_FILE requires SERVER_TLS_CERT_FILE and SERVER_TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		data, err := os.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.TLSClientCAFile)
+		}
+
+		// Client certificates are optional so browsers and API clients without one can still connect;
+		// a certificate that is presented must chain to the bundle
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		clientCAPool = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// ClientCAPool returns the CAs used to verify client certificates, or nil when mTLS is disabled
+func ClientCAPool() *x509.CertPool {
+	return clientCAPool
+}
+
+// ParseCertificatePEM parses the first certificate in a PEM document
+func ParseCertificatePEM(data string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// CertificateFingerprint returns the hex encoded SHA-256 of a certificate's DER encoding
+func CertificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"dashboard-starter/config"
+	"dashboard-starter/internal/testca"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// useServerTLS points the SERVER_TLS_* settings at certificates from ca and clientCA
+// and forgets the client CA pool when the test ends
+func useServerTLS(t *testing.T, ca, clientCA *testca.CA) {
+	t.Helper()
+
+	t.Cleanup(func() { clientCAPool = nil })
+	testca.UseServerTLS(t, ca, clientCA)
+}
+
+func TestInitTLSDisabledWithoutCertificates(t *testing.T) {
+	previous := config.Config.Server
+	t.Cleanup(func() { config.Config.Server = previous })
+
+	config.Config.Server.TLSCertFile = ""
+	config.Config.Server.TLSKeyFile = ""
+	config.Config.Server.TLSClientCAFile = ""
+	tlsConfig, err := InitTLS()
+	if err != nil || tlsConfig != nil {
+		t.Fatalf("expected plain HTTP, got %v, %v", tlsConfig, err)
+	}
+	if ClientCAPool() != nil {
+		t.Fatal("expected no client CA pool")
+	}
+
+	config.Config.Server.TLSClientCAFile = "ca.pem"
+	if _, err := InitTLS(); err == nil {
+		t.Fatal("expected a client CA without a server certificate to be rejected")
+	}
+}
+
+func TestInitTLSWithoutClientCA(t *testing.T) {
+	useServerTLS(t, testca.New(t, "Server CA"), nil)
+
+	tlsConfig, err := InitTLS()
+	if err != nil {
+		t.Fatalf("InitTLS: %v", err)
+	}
+	if tlsConfig.ClientAuth != tls.NoClientCert || ClientCAPool() != nil {
+		t.Fatalf("expected client certificates to be ignored, got %v", tlsConfig.ClientAuth)
+	}
+}
+
+func TestInitTLSVerifiesClientCertificatesIfGiven(t *testing.T) {
+	serverCA := testca.New(t, "Server CA")
+	clientCA := testca.New(t, "Device CA")
+	unknownCA := testca.New(t, "Unknown CA")
+	useServerTLS(t, serverCA, clientCA)
+
+	tlsConfig, err := InitTLS()
+	if err != nil {
+		t.Fatalf("InitTLS: %v", err)
+	}
+	if tlsConfig.ClientAuth != tls.VerifyClientCertIfGiven {
+		t.Fatalf("expected VerifyClientCertIfGiven, got %v", tlsConfig.ClientAuth)
+	}
+	if ClientCAPool() == nil {
+		t.Fatal("expected the client CA pool to be set")
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.VerifiedChains) == 0 {
+			io.WriteString(w, "anonymous")
+			return
+		}
+		io.WriteString(w, r.TLS.VerifiedChains[0][0].Subject.CommonName)
+	}))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCA.Cert)
+	get := func(certificate *tls.Certificate) (string, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs: roots,
+			// Always send the certificate, even when its issuer is not one the server asked for
+			GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				if certificate == nil {
+					return &tls.Certificate{}, nil
+				}
+				return certificate, nil
+			},
+		}}}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	// Clients without a certificate can still connect
+	if body, err := get(nil); err != nil || body != "anonymous" {
+		t.Fatalf("expected an anonymous connection, got %q, %v", body, err)
+	}
+
+	device := clientCA.Leaf("sensor-001", x509.ExtKeyUsageClientAuth)
+	if body, err := get(&device); err != nil || body != "sensor-001" {
+		t.Fatalf("expected the device certificate to be verified, got %q, %v", body, err)
+	}
+
+	unknown := unknownCA.Leaf("sensor-001", x509.ExtKeyUsageClientAuth)
+	if _, err := get(&unknown); err == nil {
+		t.Fatal("expected a certificate from an unknown CA to fail the handshake")
+	}
+}
+
+func TestCertificateFingerprint(t *testing.T) {
+	ca := testca.New(t, "Device CA")
+	first := ca.Leaf("sensor-001", x509.ExtKeyUsageClientAuth)
+	second := ca.Leaf("sensor-001", x509.ExtKeyUsageClientAuth)
+
+	parsed, err := ParseCertificatePEM(testca.CertificatePEM(first.Leaf))
+	if err != nil {
+		t.Fatalf("ParseCertificatePEM: %v", err)
+	}
+
+	fingerprint := CertificateFingerprint(first.Leaf)
+	if len(fingerprint) != 64 || CertificateFingerprint(parsed) != fingerprint {
+		t.Fatalf("expected a stable hex SHA-256 fingerprint, got %q", fingerprint)
+	}
+	if CertificateFingerprint(second.Leaf) == fingerprint {
+		t.Fatal("expected another certificate for the same device to have a different fingerprint")
+	}
+
+	if _, err := ParseCertificatePEM("not a certificate"); err == nil {
+		t.Fatal("expected non-PEM input to be rejected")
+	}
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of RFC 6238 Appendix B, "12345678901234567890" in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 6238 Appendix B publishes 8-digit SHA-1 codes; a 6-digit code is the same value modulo 10^6
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "94287082"},
+	{1111111109, "07081804"},
+	{1111111111, "14050471"},
+	{1234567890, "89005924"},
+	{2000000000, "69279037"},
+	{20000000000, "65353130"},
+}
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	for _, v := range rfc6238Vectors {
+		want := v.code[len(v.code)-totpDigits:]
+		if got := totpCode(key, v.unix/totpPeriod); got != want {
+			t.Errorf("T=%d: got %s, want %s", v.unix, got, want)
+		}
+
+		step, ok := ValidateTOTPCode(rfc6238Secret, want, time.Unix(v.unix, 0))
+		if !ok || step != v.unix/totpPeriod {
+			t.Errorf("T=%d: expected %s to validate at step %d, got %d, %v", v.unix, want, v.unix/totpPeriod, step, ok)
+		}
+	}
+}
+
+func TestValidateTOTPCodeWindow(t *testing.T) {
+	key := []byte("12345678901234567890")
+	at := time.Unix(1111111111, 0)
+	current := at.Unix() / totpPeriod
+
+	for offset := int64(-3); offset <= 3; offset++ {
+		code := totpCode(key, current+offset)
+		step, ok := ValidateTOTPCode(rfc6238Secret, code, at)
+
+		inWindow := offset >= -totpSkew && offset <= totpSkew
+		if ok != inWindow {
+			t.Errorf("offset %d: accepted = %v, want %v", offset, ok, inWindow)
+		}
+		if ok && step != current+offset {
+			t.Errorf("offset %d: matched step %d, want %d", offset, step, current+offset)
+		}
+	}
+}
+
+func TestValidateTOTPCodeRejectsMalformedInput(t *testing.T) {
+	at := time.Unix(59, 0)
+
+	if _, ok := ValidateTOTPCode(rfc6238Secret, " 287082 ", at); !ok {
+		t.Error("expected surrounding whitespace to be ignored")
+	}
+	if _, ok := ValidateTOTPCode(strings.ToLower(rfc6238Secret), "287082", at); !ok {
+		t.Error("expected a lower case secret to be accepted")
+	}
+	if _, ok := ValidateTOTPCode(rfc6238Secret, "94287082", at); ok {
+		t.Error("expected an 8-digit code to be rejected")
+	}
+	if _, ok := ValidateTOTPCode("not base32!", "287082", at); ok {
+		t.Error("expected an invalid secret to be rejected")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret: %v", err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("expected a base32 encoded 160-bit secret, got %q (%d bytes, %v)", secret, len(key), err)
+	}
+}